@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
-	_ "github.com/lib/pq"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/golang-migrate/migrate/v4"
@@ -15,6 +19,7 @@ import (
 	"github.com/prbllm/go-loyalty-service/internal/accrual/handler"
 	"github.com/prbllm/go-loyalty-service/internal/accrual/repository"
 	"github.com/prbllm/go-loyalty-service/internal/accrual/service"
+	"github.com/prbllm/go-loyalty-service/internal/accrual/testmode"
 	"github.com/prbllm/go-loyalty-service/internal/config"
 	"github.com/prbllm/go-loyalty-service/internal/logger"
 )
@@ -31,45 +36,127 @@ func main() {
 		appLogger.Fatal(err)
 	}
 
-	// Подключаемся к БД
-	db, err := sql.Open("pgx", config.GetConfig().DatabaseURI)
-	if err != nil {
-		appLogger.Fatal(err)
-	}
-	defer db.Close()
+	// Контекст жизни сервиса отменяется при остановке
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Применяем миграции
-	driver, err := postgres.WithInstance(db, &postgres.Config{MigrationsTable: "schema_migrations_accrual"})
-	if err != nil {
+	// Применяем миграции до создания пула: пул подготавливает выражения на актуальной схеме
+	if err := runMigrations(config.GetConfig().DatabaseURI); err != nil {
 		appLogger.Fatal(err)
 	}
 
-	migration, err := migrate.NewWithDatabaseInstance(
-		"file://./migrations/accrual",
-		"postgres", driver)
+	// Подключаемся к БД
+	pool, err := repository.NewPostgresPool(context.Background(), config.GetConfig().DatabaseURI,
+		config.GetConfig().DatabaseMaxConns, config.GetConfig().DatabaseMinConns)
 	if err != nil {
 		appLogger.Fatal(err)
 	}
-
-	if err := migration.Up(); err != nil && err != migrate.ErrNoChange {
-		appLogger.Fatal(err)
-	}
+	defer pool.Close()
 
 	// Создаём репозитории(уже на актуальной схеме!)
-	orderRepo := repository.NewPostgresOrderRepo(db)
-	rewardRepo := repository.NewPostgresRewardRepo(db)
+	orderRepo := repository.NewPostgresOrderRepo(pool)
+	rewardRepo := repository.NewPostgresRewardRepo(pool)
+
+	// В тестовом режиме имитируем медленную и нестабильную систему расчёта
+	var injector *testmode.Injector
+	orderOpts := []service.OrderServiceOption{service.WithLifetime(ctx)}
+	if config.GetConfig().TestMode {
+		injector = testmode.New(testmode.Settings{
+			ProcessingDelay: config.GetConfig().ProcessingDelay,
+			InvalidRate:     config.GetConfig().InvalidRate,
+			ErrorRate:       config.GetConfig().ErrorRate,
+			RateLimit:       config.GetConfig().RateLimit,
+		})
+		orderOpts = append(orderOpts, service.WithProcessingFaults(injector))
+		appLogger.Warn("accrual: test mode enabled, fault injection is active")
+	}
 
 	// Инициализируем сервисы
-	orderService := service.NewOrderService(orderRepo, rewardRepo, appLogger)
+	orderService := service.NewOrderService(orderRepo, rewardRepo, appLogger, orderOpts...)
 	rewardService := service.NewRewardService(rewardRepo, appLogger)
 
 	// Инициализируем обработчик
 	h := handler.New(orderService, rewardService, appLogger)
 
+	// Программа лояльности определяется по API-ключу или заголовку запроса
+	programKeys, err := config.ParseProgramKeys(config.GetConfig().ProgramKeys)
+	if err != nil {
+		appLogger.Fatal(err)
+	}
+
 	r := chi.NewRouter()
-	r.Get("/api/orders/{number}", h.GetOrderInfo)
-	r.Post("/api/orders", h.RegisterOrder)
-	r.Post("/api/goods", h.RegisterReward)
+	r.Group(func(r chi.Router) {
+		r.Use(handler.ProgramMiddleware(programKeys))
+		if injector != nil {
+			r.With(injector.Middleware).Get("/api/orders/{number}", h.GetOrderInfo)
+		} else {
+			r.Get("/api/orders/{number}", h.GetOrderInfo)
+		}
+		r.Post("/api/orders", h.RegisterOrder)
+		r.Post("/api/orders/{number}/returns", h.ReturnGoods)
+		r.Post("/api/goods", h.RegisterReward)
+		r.Get("/api/stats", h.GetStats)
+	})
 
-	appLogger.Fatal(http.ListenAndServe(config.GetConfig().RunAddress, r))
+	// Настройки тестового режима общие для всех программ и доступны только по токену администратора
+	if injector != nil {
+		testModeHandler := handler.NewTestModeHandler(injector, appLogger)
+		adminOnly := handler.AdminTokenMiddleware(config.GetConfig().AdminToken)
+		r.With(adminOnly).Get("/api/admin/test-mode", testModeHandler.GetSettings)
+		r.With(adminOnly).Put("/api/admin/test-mode", testModeHandler.UpdateSettings)
+	}
+
+	srv := &http.Server{
+		Addr:         config.GetConfig().RunAddress,
+		Handler:      r,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+		IdleTimeout:  config.IdleTimeout,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case <-ctx.Done():
+		appLogger.Info("accrual: shutting down")
+	case err := <-errCh:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			appLogger.Fatal(err)
+		}
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer shutdownCancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		appLogger.Error(err)
+	}
+}
+
+// runMigrations применяет миграции через отдельное соединение, которое закрывается сразу после них
+func runMigrations(dsn string) error {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	driver, err := postgres.WithInstance(db, &postgres.Config{MigrationsTable: "schema_migrations_accrual"})
+	if err != nil {
+		return err
+	}
+
+	migration, err := migrate.NewWithDatabaseInstance(
+		"file://./migrations/accrual",
+		"postgres", driver)
+	if err != nil {
+		return err
+	}
+
+	if err := migration.Up(); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
+	return nil
 }
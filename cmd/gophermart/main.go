@@ -15,6 +15,7 @@ import (
 	"github.com/prbllm/go-loyalty-service/internal/config"
 	"github.com/prbllm/go-loyalty-service/internal/gophermart/handler"
 	"github.com/prbllm/go-loyalty-service/internal/gophermart/middleware"
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
 	"github.com/prbllm/go-loyalty-service/internal/gophermart/repository"
 	"github.com/prbllm/go-loyalty-service/internal/gophermart/service/accrual"
 	authservice "github.com/prbllm/go-loyalty-service/internal/gophermart/service/auth"
@@ -46,29 +47,92 @@ func main() {
 		os.Exit(1)
 	}
 
-	accrualClient := accrual.NewClient(config.GetConfig().AccrualSystemAddress, nil)
-	poller := accrual.NewWorkerPool(repo, accrualClient, appLogger, 0, accrual.DefaultWorkers)
+	accrualClient := accrual.NewClient(config.GetConfig().AccrualSystemAddress, nil,
+		accrual.WithClientRetry(accrual.ClientRetryPolicy{
+			MaxAttempts: config.GetConfig().AccrualMaxAttempts,
+			BackoffBase: config.GetConfig().AccrualRetryBackoff,
+		}),
+		accrual.WithRateLimiter(accrual.NewRateLimiter(config.GetConfig().AccrualRateLimit)),
+		accrual.WithAttemptHook(func(a accrual.Attempt) {
+			if a.Err != nil && a.Retrying {
+				appLogger.Debugf("accrual: order %s attempt %d failed in %s, retrying: %v", a.Number, a.Attempt, a.Duration, a.Err)
+			}
+		}))
+	accrualBreaker := accrual.NewCircuitBreaker(accrualClient, accrual.BreakerSettings{
+		FailureThreshold: config.GetConfig().BreakerFailureThreshold,
+		OpenTimeout:      config.GetConfig().BreakerOpenTimeout,
+	}, appLogger)
+	pollerOptions := []accrual.WorkerPoolOption{
+		accrual.WithRetryPolicy(accrual.RetryPolicy{
+			MaxAttempts: config.GetConfig().PollMaxAttempts,
+			MaxAge:      config.GetConfig().PollMaxAge,
+			BackoffBase: config.GetConfig().PollBackoffBase,
+			BackoffMax:  config.GetConfig().PollBackoffMax,
+		}),
+		accrual.WithLease(config.GetConfig().PollLease),
+		accrual.WithCircuitBreaker(accrualBreaker),
+		accrual.WithWorkerBounds(config.GetConfig().PollMinWorkers, config.GetConfig().PollMaxWorkers),
+		accrual.WithRecheck(model.RecheckPolicy{
+			Window:   config.GetConfig().RecheckWindow,
+			Interval: config.GetConfig().RecheckInterval,
+		}, model.NegativeBalancePolicy(config.GetConfig().NegativeBalance)),
+	}
+	if config.GetConfig().PollMode == config.PollModeLeader {
+		elector := repository.NewLeaderElector(config.GetConfig().DatabaseURI, repository.PollerLockName, appLogger)
+		pollerOptions = append(pollerOptions, accrual.WithLeaderElection(elector))
+	}
+	poller := accrual.NewWorkerPool(repo, accrualBreaker, appLogger,
+		config.GetConfig().PollInterval, config.GetConfig().PollMinWorkers, pollerOptions...)
 	go poller.Run(ctx)
 
+	// Uploads wake the poller up right away; the ticker only catches retries and lost notifications
+	orderListener := repository.NewOrderListener(config.GetConfig().DatabaseURI, appLogger)
+	go orderListener.Listen(ctx, func(string) { poller.Wake() })
+
 	authSvc := authservice.New(repo, appLogger)
 	authHandler := handler.NewAuthHandler(authSvc, appLogger)
 	orderSvc := order.New(repo, appLogger)
 	orderHandler := handler.NewOrderHandler(orderSvc, appLogger)
-	balanceSvc := balance.New(repo, appLogger)
+	balanceSvc := balance.New(repo, appLogger,
+		balance.WithHoldTTL(config.GetConfig().HoldTTL),
+		balance.WithPointsExpiry(config.GetConfig().PointsValidityMonths, config.GetConfig().PointsExpiringSoon),
+		balance.WithTransferLimits(model.TransferLimits{
+			DailySum:   model.FromFloat64(config.GetConfig().TransferDailyLimit),
+			DailyCount: config.GetConfig().TransferDailyCount,
+		}))
 	balanceHandler := handler.NewBalanceHandler(balanceSvc, appLogger)
+	holdSweeper := balance.NewHoldSweeper(balanceSvc, config.GetConfig().HoldSweepInterval, appLogger)
+	go holdSweeper.Run(ctx)
+	pointsExpirer := balance.NewPointsExpirer(balanceSvc, config.GetConfig().PointsExpiryInterval, appLogger)
+	go pointsExpirer.Run(ctx)
+	adminHandler := handler.NewAdminHandler(orderSvc, balanceSvc, poller, appLogger)
+	healthHandler := handler.NewHealthHandler(accrualBreaker, appLogger)
 
 	router := chi.NewRouter()
 	router.Use(
 		chimiddleware.Compress(config.CompressionLevel),
 		middleware.Logging(appLogger),
 	)
+	router.Get(config.PathHealth, healthHandler.Health)
 	router.Post(config.PathUserRegister, authHandler.Register)
 	router.Post(config.PathUserLogin, authHandler.Login)
 	router.With(middleware.Auth).Post(config.PathUserOrders, orderHandler.Upload)
 	router.With(middleware.Auth).Get(config.PathUserOrders, orderHandler.List)
 	router.With(middleware.Auth).Get(config.PathUserBalance, balanceHandler.Balance)
-	router.With(middleware.Auth).Post(config.PathUserWithdraw, balanceHandler.Withdraw)
+	router.With(middleware.Auth, middleware.Idempotency(repo, appLogger)).Post(config.PathUserWithdraw, balanceHandler.Withdraw)
 	router.With(middleware.Auth).Get(config.PathWithdrawals, balanceHandler.Withdrawals)
+	router.With(middleware.Auth).Get(config.PathTransactions, balanceHandler.Transactions)
+	router.With(middleware.Auth, middleware.Idempotency(repo, appLogger)).Post(config.PathUserHolds, balanceHandler.Hold)
+	router.With(middleware.Auth).Post(config.PathUserHoldCapture, balanceHandler.CaptureHold)
+	router.With(middleware.Auth).Post(config.PathUserHoldRelease, balanceHandler.ReleaseHold)
+	router.With(middleware.Auth, middleware.Idempotency(repo, appLogger)).Post(config.PathUserTransfer, balanceHandler.Transfer)
+
+	adminOnly := middleware.AdminToken(config.GetConfig().AdminToken)
+	router.With(adminOnly).Get(config.PathAdminParkedOrders, adminHandler.ParkedOrders)
+	router.With(adminOnly).Post(config.PathAdminRedriveOrder, adminHandler.RedriveOrder)
+	router.With(adminOnly).Get(config.PathAdminPoller, adminHandler.PollerStatus)
+	router.With(adminOnly).Post(config.PathAdminPollOrder, adminHandler.PollOrder)
+	router.With(adminOnly).Post(config.PathAdminReverseWithdrawal, adminHandler.ReverseWithdrawal)
 
 	srv := &http.Server{
 		Addr:         config.GetConfig().RunAddress,
@@ -104,6 +168,8 @@ func main() {
 	appLogger.Info("Waiting for worker pool to finish...")
 	poller.Wait()
 	appLogger.Info("Worker pool stopped")
+	holdSweeper.Wait()
+	pointsExpirer.Wait()
 
 	if closeErr := repo.Close(); closeErr != nil {
 		appLogger.Errorf("Error closing repository: %v", closeErr)
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/prbllm/go-loyalty-service/internal/config"
+)
+
+// AdminTokenMiddleware защищает служебные эндпоинты общим токеном из заголовка X-Admin-Token.
+// С пустым токеном эндпоинты отключены
+func AdminTokenMiddleware(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				http.NotFound(w, r)
+				return
+			}
+
+			provided := r.Header.Get(config.HeaderAdminToken)
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
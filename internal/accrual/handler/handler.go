@@ -7,6 +7,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/prbllm/go-loyalty-service/internal/accrual/model"
+	"github.com/prbllm/go-loyalty-service/internal/accrual/repository"
 	"github.com/prbllm/go-loyalty-service/internal/accrual/service"
 	"github.com/prbllm/go-loyalty-service/internal/logger"
 	"github.com/prbllm/go-loyalty-service/pkg/luhn"
@@ -34,7 +35,7 @@ func (h *Handler) GetOrderInfo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Запрашиваем заказ у сервиса
-	order, err := h.orderService.GetOrder(r.Context(), number)
+	order, err := h.orderService.GetOrder(r.Context(), programFromContext(r.Context()), number)
 	if err != nil {
 		if errors.Is(err, service.ErrOrderNotFound) {
 			w.WriteHeader(http.StatusNoContent)
@@ -45,6 +46,61 @@ func (h *Handler) GetOrderInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.writeOrder(w, order)
+}
+
+// POST /api/orders/{number}/returns — возврат товаров и пересчёт начисления
+func (h *Handler) ReturnGoods(w http.ResponseWriter, r *http.Request) {
+	number := chi.URLParam(r, "number")
+
+	if !luhn.IsValidOrderNumber(number) {
+		http.Error(w, "invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	if r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusBadRequest)
+		return
+	}
+
+	var req model.ReturnGoodsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Goods) == 0 {
+		http.Error(w, "invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	for _, item := range req.Goods {
+		if item.Description == "" || item.Price <= 0 {
+			http.Error(w, "invalid request format", http.StatusBadRequest)
+			return
+		}
+	}
+
+	order, err := h.orderService.ReturnGoods(r.Context(), programFromContext(r.Context()), number, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrOrderNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, service.ErrGoodsNotInOrder):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		case errors.Is(err, service.ErrOrderNotReturnable), errors.Is(err, repository.ErrOrderModified):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			h.logger.Error(err)
+			http.Error(w, "", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.writeOrder(w, order)
+}
+
+func (h *Handler) writeOrder(w http.ResponseWriter, order *model.Order) {
 	orderResponse := model.GetOrderResponse{
 		Number: order.Number,
 		Status: string(order.Status),
@@ -56,6 +112,11 @@ func (h *Handler) GetOrderInfo(w http.ResponseWriter, r *http.Request) {
 		orderResponse.Accrual = &accrualResutl
 	}
 
+	if order.Adjustment != nil {
+		adjustment := float64(*order.Adjustment) / 100
+		orderResponse.Adjustment = &adjustment
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(orderResponse); err != nil {
 		h.logger.Error(err)
@@ -96,7 +157,7 @@ func (h *Handler) RegisterOrder(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	err := h.orderService.RegisterOrder(r.Context(), order)
+	err := h.orderService.RegisterOrder(r.Context(), programFromContext(r.Context()), order)
 	if err != nil {
 		if errors.Is(err, service.ErrOrderAlreadyExists) {
 			http.Error(w, err.Error(), http.StatusConflict)
@@ -139,7 +200,7 @@ func (h *Handler) RegisterReward(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.rewardService.RegisterReward(r.Context(), rewardRule)
+	err := h.rewardService.RegisterReward(r.Context(), programFromContext(r.Context()), rewardRule)
 	if err != nil {
 		if errors.Is(err, service.ErrMatchAlreadyExists) {
 			http.Error(w, err.Error(), http.StatusConflict)
@@ -152,3 +213,19 @@ func (h *Handler) RegisterReward(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// GET /api/stats — статистика программы лояльности
+func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.orderService.GetStats(r.Context(), programFromContext(r.Context()))
+	if err != nil {
+		h.logger.Error(err)
+		http.Error(w, "", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		h.logger.Error(err)
+		http.Error(w, "", http.StatusInternalServerError)
+	}
+}
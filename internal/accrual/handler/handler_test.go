@@ -7,11 +7,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/prbllm/go-loyalty-service/internal/accrual/handler"
 	"github.com/prbllm/go-loyalty-service/internal/accrual/model"
 	"github.com/prbllm/go-loyalty-service/internal/accrual/service"
+	"github.com/prbllm/go-loyalty-service/internal/accrual/testmode"
+	"github.com/prbllm/go-loyalty-service/internal/config"
 	"github.com/prbllm/go-loyalty-service/internal/logger"
 	mocks "github.com/prbllm/go-loyalty-service/internal/mocks/accrual"
 	"github.com/stretchr/testify/require"
@@ -37,7 +40,7 @@ func TestHandler_GetOrderInfo(t *testing.T) {
 			orderNumber:    "5354354162584",
 			expectedStatus: http.StatusNoContent,
 			mockSetup: func(m *mocks.MockOrderService) {
-				m.EXPECT().GetOrder(gomock.Any(), "5354354162584").Return(nil, service.ErrOrderNotFound)
+				m.EXPECT().GetOrder(gomock.Any(), model.DefaultProgram, "5354354162584").Return(nil, service.ErrOrderNotFound)
 			},
 		},
 		{
@@ -45,7 +48,7 @@ func TestHandler_GetOrderInfo(t *testing.T) {
 			orderNumber:    "5354354162584",
 			expectedStatus: http.StatusInternalServerError,
 			mockSetup: func(m *mocks.MockOrderService) {
-				m.EXPECT().GetOrder(gomock.Any(), "5354354162584").Return(nil, errors.New("service error"))
+				m.EXPECT().GetOrder(gomock.Any(), model.DefaultProgram, "5354354162584").Return(nil, errors.New("service error"))
 			},
 		},
 		{
@@ -54,7 +57,7 @@ func TestHandler_GetOrderInfo(t *testing.T) {
 			expectedStatus: http.StatusOK,
 			mockSetup: func(m *mocks.MockOrderService) {
 				accrual := int64(500)
-				m.EXPECT().GetOrder(gomock.Any(), "5354354162584").Return(&model.Order{Number: "5354354162584", Status: model.Processed, Accrual: &accrual}, nil)
+				m.EXPECT().GetOrder(gomock.Any(), model.DefaultProgram, "5354354162584").Return(&model.Order{Number: "5354354162584", Status: model.Processed, Accrual: &accrual}, nil)
 			},
 			expectedBody: `{"order":"5354354162584","status":"PROCESSED","accrual":5}`,
 		},
@@ -63,7 +66,7 @@ func TestHandler_GetOrderInfo(t *testing.T) {
 			orderNumber:    "5354354162584",
 			expectedStatus: http.StatusOK,
 			mockSetup: func(m *mocks.MockOrderService) {
-				m.EXPECT().GetOrder(gomock.Any(), "5354354162584").Return(&model.Order{Number: "5354354162584", Status: model.Processing}, nil)
+				m.EXPECT().GetOrder(gomock.Any(), model.DefaultProgram, "5354354162584").Return(&model.Order{Number: "5354354162584", Status: model.Processing}, nil)
 			},
 			expectedBody: `{"order":"5354354162584","status":"PROCESSING"}`,
 		},
@@ -155,14 +158,13 @@ func TestHandler_RegisterOrder(t *testing.T) {
 			body:           `{"order": "5354354162584", "goods": [ {"description": "Чайник Bork", "price": 7000}]}`,
 			expectedStatus: http.StatusConflict,
 			mockSetup: func(m *mocks.MockOrderService) {
-				expectedOrder := model.Order{
+				expectedOrder := model.RegisterOrderRequest{
 					Number: "5354354162584",
-					Goods: []model.Good{
-						{Description: "Чайник Bork", Price: 700000},
+					Goods: []model.GoodRequest{
+						{Description: "Чайник Bork", Price: 7000},
 					},
-					Status: model.Registered,
 				}
-				m.EXPECT().RegisterOrder(gomock.Any(), gomock.Eq(expectedOrder)).Return(service.ErrOrderAlreadyExists)
+				m.EXPECT().RegisterOrder(gomock.Any(), model.DefaultProgram, gomock.Eq(expectedOrder)).Return(service.ErrOrderAlreadyExists)
 			},
 		},
 		{
@@ -171,14 +173,29 @@ func TestHandler_RegisterOrder(t *testing.T) {
 			body:           `{"order": "5354354162584", "goods": [ {"description": "Чайник Bork", "price": 7000}]}`,
 			expectedStatus: http.StatusInternalServerError,
 			mockSetup: func(m *mocks.MockOrderService) {
-				expectedOrder := model.Order{
+				expectedOrder := model.RegisterOrderRequest{
 					Number: "5354354162584",
-					Goods: []model.Good{
-						{Description: "Чайник Bork", Price: 700000},
+					Goods: []model.GoodRequest{
+						{Description: "Чайник Bork", Price: 7000},
 					},
-					Status: model.Registered,
 				}
-				m.EXPECT().RegisterOrder(gomock.Any(), gomock.Eq(expectedOrder)).Return(errors.New("service error"))
+				m.EXPECT().RegisterOrder(gomock.Any(), model.DefaultProgram, gomock.Eq(expectedOrder)).Return(errors.New("service error"))
+			},
+		},
+		{
+			name:           "order with customer segment",
+			contentType:    "application/json",
+			body:           `{"order": "5354354162584", "goods": [ {"description": "Чайник Bork", "price": 7000}], "customer_segment": "vip"}`,
+			expectedStatus: http.StatusAccepted,
+			mockSetup: func(m *mocks.MockOrderService) {
+				expectedOrder := model.RegisterOrderRequest{
+					Number: "5354354162584",
+					Goods: []model.GoodRequest{
+						{Description: "Чайник Bork", Price: 7000},
+					},
+					Segment: "vip",
+				}
+				m.EXPECT().RegisterOrder(gomock.Any(), model.DefaultProgram, gomock.Eq(expectedOrder)).Return(nil)
 			},
 		},
 		{
@@ -187,14 +204,13 @@ func TestHandler_RegisterOrder(t *testing.T) {
 			body:           `{"order": "5354354162584", "goods": [ {"description": "Чайник Bork", "price": 7000}]}`,
 			expectedStatus: http.StatusAccepted,
 			mockSetup: func(m *mocks.MockOrderService) {
-				expectedOrder := model.Order{
+				expectedOrder := model.RegisterOrderRequest{
 					Number: "5354354162584",
-					Goods: []model.Good{
-						{Description: "Чайник Bork", Price: 700000},
+					Goods: []model.GoodRequest{
+						{Description: "Чайник Bork", Price: 7000},
 					},
-					Status: model.Registered,
 				}
-				m.EXPECT().RegisterOrder(gomock.Any(), gomock.Eq(expectedOrder)).Return(nil)
+				m.EXPECT().RegisterOrder(gomock.Any(), model.DefaultProgram, gomock.Eq(expectedOrder)).Return(nil)
 			},
 		},
 	}
@@ -275,7 +291,7 @@ func TestHandler_RegisterReward(t *testing.T) {
 			expectedStatus: http.StatusConflict,
 			mockSetup: func(m *mocks.MockRewardService) {
 				expectedRewardRule := model.RewardRule{Match: "Bork", Reward: 10, RewardType: model.RewardTypePercent}
-				m.EXPECT().RegisterReward(gomock.Any(), gomock.Eq(expectedRewardRule)).Return(service.ErrMatchAlreadyExists)
+				m.EXPECT().RegisterReward(gomock.Any(), model.DefaultProgram, gomock.Eq(expectedRewardRule)).Return(service.ErrMatchAlreadyExists)
 			},
 		},
 		{
@@ -285,7 +301,7 @@ func TestHandler_RegisterReward(t *testing.T) {
 			expectedStatus: http.StatusInternalServerError,
 			mockSetup: func(m *mocks.MockRewardService) {
 				expectedRewardRule := model.RewardRule{Match: "Bork", Reward: 10, RewardType: model.RewardTypePercent}
-				m.EXPECT().RegisterReward(gomock.Any(), gomock.Eq(expectedRewardRule)).Return(errors.New("db error"))
+				m.EXPECT().RegisterReward(gomock.Any(), model.DefaultProgram, gomock.Eq(expectedRewardRule)).Return(errors.New("db error"))
 			},
 		},
 		{
@@ -295,7 +311,7 @@ func TestHandler_RegisterReward(t *testing.T) {
 			expectedStatus: http.StatusOK,
 			mockSetup: func(m *mocks.MockRewardService) {
 				expectedRewardRule := model.RewardRule{Match: "Bork", Reward: 10, RewardType: model.RewardTypePercent}
-				m.EXPECT().RegisterReward(gomock.Any(), gomock.Eq(expectedRewardRule)).Return(nil)
+				m.EXPECT().RegisterReward(gomock.Any(), model.DefaultProgram, gomock.Eq(expectedRewardRule)).Return(nil)
 			},
 		},
 	}
@@ -325,3 +341,269 @@ func TestHandler_RegisterReward(t *testing.T) {
 		})
 	}
 }
+
+func TestTestModeHandler_UpdateSettings(t *testing.T) {
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedStatus int
+		expected       testmode.Settings
+	}{
+		{
+			name:           "invalid content-type",
+			contentType:    "text/plain",
+			body:           `{"error_rate": 0.5}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid delay",
+			contentType:    "application/json",
+			body:           `{"processing_delay": "soon"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid rate",
+			contentType:    "application/json",
+			body:           `{"invalid_rate": 1.5}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "settings updated",
+			contentType:    "application/json",
+			body:           `{"processing_delay": "2s", "invalid_rate": 0.1, "error_rate": 0.2, "rate_limit": 10}`,
+			expectedStatus: http.StatusOK,
+			expected: testmode.Settings{
+				ProcessingDelay: 2 * time.Second,
+				InvalidRate:     0.1,
+				ErrorRate:       0.2,
+				RateLimit:       10,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			injector := testmode.New(testmode.Settings{})
+			h := handler.NewTestModeHandler(injector, logger.NewNop())
+
+			req := httptest.NewRequest(http.MethodPut, "/api/admin/test-mode", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+
+			h.UpdateSettings(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.Equal(t, tt.expected, injector.Settings())
+		})
+	}
+}
+
+func TestAdminTokenMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          string
+		header         string
+		expectedStatus int
+	}{
+		{name: "disabled without token", header: "secret", expectedStatus: http.StatusNotFound},
+		{name: "missing header", token: "secret", expectedStatus: http.StatusForbidden},
+		{name: "wrong token", token: "secret", header: "guess", expectedStatus: http.StatusForbidden},
+		{name: "valid token", token: "secret", header: "secret", expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			injector := testmode.New(testmode.Settings{})
+			h := handler.NewTestModeHandler(injector, logger.NewNop())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/test-mode", nil)
+			if tt.header != "" {
+				req.Header.Set(config.HeaderAdminToken, tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			handler.AdminTokenMiddleware(tt.token)(http.HandlerFunc(h.GetSettings)).ServeHTTP(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestHandler_ReturnGoods(t *testing.T) {
+	tests := []struct {
+		name           string
+		orderNumber    string
+		body           string
+		expectedStatus int
+		expectedBody   string
+		mockSetup      func(*mocks.MockOrderService)
+	}{
+		{
+			name:           "invalid order number",
+			orderNumber:    "123",
+			body:           `{"goods": [{"description": "Чайник Bork", "price": 7000}]}`,
+			expectedStatus: http.StatusBadRequest,
+			mockSetup:      func(m *mocks.MockOrderService) {},
+		},
+		{
+			name:           "empty goods",
+			orderNumber:    "5354354162584",
+			body:           `{"goods": []}`,
+			expectedStatus: http.StatusBadRequest,
+			mockSetup:      func(m *mocks.MockOrderService) {},
+		},
+		{
+			name:           "order not found",
+			orderNumber:    "5354354162584",
+			body:           `{"goods": [{"description": "Чайник Bork", "price": 7000}]}`,
+			expectedStatus: http.StatusNotFound,
+			mockSetup: func(m *mocks.MockOrderService) {
+				m.EXPECT().ReturnGoods(gomock.Any(), model.DefaultProgram, "5354354162584", gomock.Any()).Return(nil, service.ErrOrderNotFound)
+			},
+		},
+		{
+			name:           "goods not in order",
+			orderNumber:    "5354354162584",
+			body:           `{"goods": [{"description": "Чайник Bork", "price": 7000}]}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			mockSetup: func(m *mocks.MockOrderService) {
+				m.EXPECT().ReturnGoods(gomock.Any(), model.DefaultProgram, "5354354162584", gomock.Any()).Return(nil, service.ErrGoodsNotInOrder)
+			},
+		},
+		{
+			name:           "order not returnable",
+			orderNumber:    "5354354162584",
+			body:           `{"goods": [{"description": "Чайник Bork", "price": 7000}]}`,
+			expectedStatus: http.StatusConflict,
+			mockSetup: func(m *mocks.MockOrderService) {
+				m.EXPECT().ReturnGoods(gomock.Any(), model.DefaultProgram, "5354354162584", gomock.Any()).Return(nil, service.ErrOrderNotReturnable)
+			},
+		},
+		{
+			name:           "order reversed",
+			orderNumber:    "5354354162584",
+			body:           `{"goods": [{"description": "Чайник Bork", "price": 7000}]}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"order":"5354354162584","status":"REVERSED","accrual":0,"adjustment":-700}`,
+			mockSetup: func(m *mocks.MockOrderService) {
+				accrual, adjustment := int64(0), int64(-70000)
+				m.EXPECT().ReturnGoods(gomock.Any(), model.DefaultProgram, "5354354162584", gomock.Any()).Return(&model.Order{
+					Number: "5354354162584", Status: model.Reversed, Accrual: &accrual, Adjustment: &adjustment,
+				}, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockOrder := mocks.NewMockOrderService(ctrl)
+			mockReward := mocks.NewMockRewardService(ctrl)
+			tt.mockSetup(mockOrder)
+
+			h := handler.New(mockOrder, mockReward, logger.NewNop())
+
+			req := httptest.NewRequest(http.MethodPost, "/api/orders/"+tt.orderNumber+"/returns", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("number", tt.orderNumber)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			h.ReturnGoods(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedBody != "" {
+				require.JSONEq(t, tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_GetStats_ProgramSelection(t *testing.T) {
+	keys := map[string]string{"key-a": "brand-a"}
+
+	tests := []struct {
+		name           string
+		keys           map[string]string
+		headers        map[string]string
+		expectedStatus int
+		program        string
+	}{
+		{
+			name:           "default program",
+			expectedStatus: http.StatusOK,
+			program:        model.DefaultProgram,
+		},
+		{
+			name:           "program by header",
+			headers:        map[string]string{handler.HeaderProgram: "brand-b"},
+			expectedStatus: http.StatusOK,
+			program:        "brand-b",
+		},
+		{
+			name:           "invalid program name",
+			headers:        map[string]string{handler.HeaderProgram: "Brand B"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "program by API key",
+			keys:           keys,
+			headers:        map[string]string{handler.HeaderAPIKey: "key-a", handler.HeaderProgram: "brand-b"},
+			expectedStatus: http.StatusOK,
+			program:        "brand-a",
+		},
+		{
+			name:           "unknown API key",
+			keys:           keys,
+			headers:        map[string]string{handler.HeaderAPIKey: "key-x"},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "header without API key when keys are configured",
+			keys:           keys,
+			headers:        map[string]string{handler.HeaderProgram: "brand-a"},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "no API key when keys are configured",
+			keys:           keys,
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockOrder := mocks.NewMockOrderService(ctrl)
+			mockReward := mocks.NewMockRewardService(ctrl)
+			if tt.program != "" {
+				mockOrder.EXPECT().GetStats(gomock.Any(), tt.program).Return(&model.ProgramStats{
+					Program: tt.program,
+					Orders:  map[model.OrderStatus]int64{model.Processed: 2},
+					Accrual: 12.5,
+					Rules:   3,
+				}, nil)
+			}
+
+			h := handler.New(mockOrder, mockReward, logger.NewNop())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ProgramMiddleware(tt.keys)(http.HandlerFunc(h.GetStats)).ServeHTTP(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			if tt.program != "" {
+				require.JSONEq(t, `{"program":"`+tt.program+`","orders":{"PROCESSED":2},"accrual":12.5,"rules":3}`, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/prbllm/go-loyalty-service/internal/accrual/model"
+)
+
+const (
+	// HeaderAPIKey — API-ключ программы лояльности
+	HeaderAPIKey = "X-Api-Key"
+	// HeaderProgram — явное указание программы лояльности
+	HeaderProgram = "X-Loyalty-Program"
+)
+
+type programContextKey struct{}
+
+// programNamePattern — допустимые имена программ лояльности
+var programNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// ProgramMiddleware определяет программу лояльности запроса и кладёт её в контекст.
+// Если ключи заданы, программа определяется только по API-ключу: без ключа или с неизвестным
+// ключом — 401, иначе заголовок X-Loyalty-Program открывал бы данные чужой программы.
+// Без ключей программа берётся из заголовка X-Loyalty-Program, а без него — программа по умолчанию
+func ProgramMiddleware(keys map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			program := model.DefaultProgram
+
+			if len(keys) > 0 {
+				apiKey := r.Header.Get(HeaderAPIKey)
+				if apiKey == "" {
+					http.Error(w, "API key required", http.StatusUnauthorized)
+					return
+				}
+				var ok bool
+				program, ok = keys[apiKey]
+				if !ok {
+					http.Error(w, "unknown API key", http.StatusUnauthorized)
+					return
+				}
+			} else if header := r.Header.Get(HeaderProgram); header != "" {
+				program = header
+			}
+
+			if !programNamePattern.MatchString(program) {
+				http.Error(w, "invalid loyalty program", http.StatusBadRequest)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), programContextKey{}, program)))
+		})
+	}
+}
+
+// programFromContext возвращает программу лояльности запроса
+func programFromContext(ctx context.Context) string {
+	if program, ok := ctx.Value(programContextKey{}).(string); ok {
+		return program
+	}
+	return model.DefaultProgram
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/prbllm/go-loyalty-service/internal/accrual/model"
+	"github.com/prbllm/go-loyalty-service/internal/accrual/testmode"
+	"github.com/prbllm/go-loyalty-service/internal/logger"
+)
+
+// TestModeHandler управляет настройками тестового режима во время работы сервиса
+type TestModeHandler struct {
+	injector *testmode.Injector
+	logger   logger.Logger
+}
+
+func NewTestModeHandler(injector *testmode.Injector, logger logger.Logger) *TestModeHandler {
+	return &TestModeHandler{injector: injector, logger: logger}
+}
+
+// GET /api/admin/test-mode — получение текущих настроек тестового режима
+func (h *TestModeHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	settings := h.injector.Settings()
+
+	resp := model.TestModeSettings{
+		ProcessingDelay: settings.ProcessingDelay.String(),
+		InvalidRate:     settings.InvalidRate,
+		ErrorRate:       settings.ErrorRate,
+		RateLimit:       settings.RateLimit,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.logger.Error(err)
+		http.Error(w, "", http.StatusInternalServerError)
+	}
+}
+
+// PUT /api/admin/test-mode — замена настроек тестового режима
+func (h *TestModeHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusBadRequest)
+		return
+	}
+
+	var req model.TestModeSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	var delay time.Duration
+	if req.ProcessingDelay != "" {
+		var err error
+		delay, err = time.ParseDuration(req.ProcessingDelay)
+		if err != nil || delay < 0 {
+			http.Error(w, "invalid request format", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.InvalidRate < 0 || req.InvalidRate > 1 || req.ErrorRate < 0 || req.ErrorRate > 1 || req.RateLimit < 0 {
+		http.Error(w, "invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	h.injector.Update(testmode.Settings{
+		ProcessingDelay: delay,
+		InvalidRate:     req.InvalidRate,
+		ErrorRate:       req.ErrorRate,
+		RateLimit:       req.RateLimit,
+	})
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -1,10 +1,18 @@
 package model
 
+// DefaultProgram — программа лояльности, если клиент не указал свою
+const DefaultProgram = "default"
+
 type Order struct {
-	Number  string      // номер заказа
-	Goods   []Good      // список купленных товаров
-	Status  OrderStatus // статус расчета начисления
-	Accrual *int64      // рассчитанные баллы к начислению(в копейках), nil = нет начисления
+	Program    string        // программа лояльности (бренд магазина)
+	Number     string        // номер заказа
+	Goods      []Good        // список купленных товаров
+	Status     OrderStatus   // статус расчета начисления
+	Accrual    *int64        // рассчитанные баллы к начислению(в копейках), nil = нет начисления
+	Returned   []Good        // возвращённые товары
+	Adjustment *int64        // изменение начисления после возвратов(в копейках), nil = возвратов не было
+	Segment    string        // сегмент покупателя, "" = без сегмента
+	Breakdown  []AccrualItem // расшифровка начисления по товарам
 }
 
 type Good struct {
@@ -12,6 +20,14 @@ type Good struct {
 	Price       int64  // цена оплаченного товара(в копейках)
 }
 
+// AccrualItem — начисление за один товар заказа
+type AccrualItem struct {
+	Description string // наименование товара
+	Match       string // ключ сработавшего правила, "" = правило не найдено
+	Segment     string // сегмент сработавшего правила, "" = общее правило
+	Accrual     int64  // начисление за товар(в копейках)
+}
+
 type OrderStatus string
 
 const (
@@ -19,27 +35,39 @@ const (
 	Processing OrderStatus = "PROCESSING" // расчёт начисления в процессе
 	Processed  OrderStatus = "PROCESSED"  // расчёт начисления окончен
 	Invalid    OrderStatus = "INVALID"    // заказ не принят к расчёту и вознаграждение не будет начислено
+	Adjusted   OrderStatus = "ADJUSTED"   // часть товаров возвращена, начисление уменьшено
+	Reversed   OrderStatus = "REVERSED"   // все товары возвращены, начисление отменено
 )
 
 type RegisterOrderRequest struct {
-	Number string `json:"order"` // номер заказа
-	Goods  []struct {
-		Description string  `json:"description"` // наименование товара
-		Price       float64 `json:"price"`       // цена оплаченного товара(в рублях)
-	} `json:"goods"` // список купленных товаров
+	Number  string        `json:"order"`                      // номер заказа
+	Goods   []GoodRequest `json:"goods"`                      // список купленных товаров
+	Segment string        `json:"customer_segment,omitempty"` // сегмент покупателя
+}
+
+type GoodRequest struct {
+	Description string  `json:"description"` // наименование товара
+	Price       float64 `json:"price"`       // цена оплаченного товара(в рублях)
 }
 
 type GetOrderResponse struct {
-	Number  string   `json:"order"`             // номер заказа
-	Status  string   `json:"status"`            // статус расчета начисления
-	Accrual *float64 `json:"accrual,omitempty"` // рассчитанные баллы к начислению(в рублях), nil = нет начисления
+	Number     string   `json:"order"`                // номер заказа
+	Status     string   `json:"status"`               // статус расчета начисления
+	Accrual    *float64 `json:"accrual,omitempty"`    // рассчитанные баллы к начислению(в рублях), nil = нет начисления
+	Adjustment *float64 `json:"adjustment,omitempty"` // изменение начисления после возвратов(в рублях), отрицательное
+}
+
+type ReturnGoodsRequest struct {
+	Goods []GoodRequest `json:"goods"` // список возвращённых товаров
 }
 
 // RewardRule — правило начисления за товар
 type RewardRule struct {
-	Match      string     `json:"match"`       // ключ поиска
-	Reward     float64    `json:"reward"`      // размер вознаграждения
-	RewardType RewardType `json:"reward_type"` // тип вознаграждения
+	Program    string     `json:"-"`                          // программа лояльности (бренд магазина)
+	Match      string     `json:"match"`                      // ключ поиска
+	Reward     float64    `json:"reward"`                     // размер вознаграждения
+	RewardType RewardType `json:"reward_type"`                // тип вознаграждения
+	Segment    string     `json:"customer_segment,omitempty"` // сегмент покупателей, "" = правило для всех
 }
 
 type RewardType string
@@ -48,3 +76,19 @@ const (
 	RewardTypePercent RewardType = "%"  // процент от стоимости товара
 	RewardTypePoints  RewardType = "pt" // точное количество баллов
 )
+
+// TestModeSettings — настройки тестового режима для административного API
+type TestModeSettings struct {
+	ProcessingDelay string  `json:"processing_delay"` // задержка расчёта, например "2s"
+	InvalidRate     float64 `json:"invalid_rate"`     // доля заказов, помечаемых INVALID (0..1)
+	ErrorRate       float64 `json:"error_rate"`       // доля запросов, завершающихся 500 (0..1)
+	RateLimit       int     `json:"rate_limit"`       // максимум запросов в минуту, 0 = без ограничения
+}
+
+// ProgramStats — статистика программы лояльности
+type ProgramStats struct {
+	Program string                `json:"program"` // программа лояльности
+	Orders  map[OrderStatus]int64 `json:"orders"`  // количество заказов по статусам
+	Accrual float64               `json:"accrual"` // сумма начислений(в рублях)
+	Rules   int64                 `json:"rules"`   // количество правил вознаграждений
+}
@@ -2,23 +2,43 @@ package repository
 
 import (
 	"context"
+	"errors"
 
 	"github.com/prbllm/go-loyalty-service/internal/accrual/model"
 )
 
+var (
+	// ErrOrderExists — заказ с таким номером уже зарегистрирован
+	ErrOrderExists = errors.New("order already exists")
+	// ErrOrderNotFound — заказ с таким номером не найден
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrOrderModified — заказ изменился между чтением и записью
+	ErrOrderModified = errors.New("order was modified concurrently")
+)
+
 //go:generate mockgen -source=order.go -destination=../../mocks/accrual/order_repository.go -package=mocks
 
-// OrderRepository отвечает за операции с заказами
+// OrderRepository отвечает за операции с заказами.
+// Заказы хранятся в рамках программы лояльности: один номер может встречаться в разных программах
 type OrderRepository interface {
-	// Create создаёт новый заказ со статусом REGISTERED
+	// Create создаёт новый заказ со статусом REGISTERED в программе order.Program.
+	// Если заказ с таким номером в программе уже есть, возвращает ErrOrderExists
 	Create(ctx context.Context, order model.Order) error
 
-	// IsOrderExists проверяет, существует ли заказ с указанным номером
-	IsOrderExists(ctx context.Context, number string) (bool, error)
-
-	// GetByNumber возвращает заказ по номеру, если существует
-	GetByNumber(ctx context.Context, number string) (*model.Order, error)
+	// GetByNumber возвращает заказ программы по номеру или ErrOrderNotFound
+	GetByNumber(ctx context.Context, program, number string) (*model.Order, error)
 
 	// UpdateStatusAndAccrual обновляет статус и сумму начисления для заказа
-	UpdateStatusAndAccrual(ctx context.Context, number string, status model.OrderStatus, accrual *int64) error
+	UpdateStatusAndAccrual(ctx context.Context, program, number string, status model.OrderStatus, accrual *int64) error
+
+	// SetProcessed переводит заказ в PROCESSED и сохраняет начисление вместе с расшифровкой
+	SetProcessed(ctx context.Context, program, number string, accrual int64, breakdown []model.AccrualItem) error
+
+	// GetStats возвращает количество заказов программы по статусам и сумму начислений(в копейках)
+	GetStats(ctx context.Context, program string) (map[model.OrderStatus]int64, int64, error)
+
+	// ApplyReturn сохраняет возврат товаров: статус, начисление, возвращённые товары и корректировку.
+	// Запись выполняется, только если список возвращённых товаров всё ещё равен prevReturned,
+	// иначе возвращается ErrOrderModified
+	ApplyReturn(ctx context.Context, order model.Order, prevReturned []model.Good) error
 }
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Имена подготовленных выражений; pgx узнаёт их по имени вместо текста запроса
+const (
+	stmtCreateOrder            = "accrual_create_order"
+	stmtGetOrderByNumber       = "accrual_get_order_by_number"
+	stmtUpdateStatusAndAccrual = "accrual_update_status_and_accrual"
+	stmtSetProcessed           = "accrual_set_processed"
+	stmtApplyReturn            = "accrual_apply_return"
+	stmtGetOrderStats          = "accrual_get_order_stats"
+
+	stmtCreateRule        = "accrual_create_rule"
+	stmtGetRulesBySegment = "accrual_get_rules_by_segment"
+	stmtExistsRuleByMatch = "accrual_exists_rule_by_match"
+	stmtCountRules        = "accrual_count_rules"
+)
+
+// statements — подготавливаются на каждом соединении пула.
+// Каждый запрос ограничен программой лояльности, данные программ не пересекаются
+var statements = map[string]string{
+	stmtCreateOrder: `INSERT INTO accrual.orders (program, number, status, accrual, goods, customer_segment)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (program, number) DO NOTHING`,
+	stmtGetOrderByNumber: `SELECT status, accrual, goods, returned, adjustment, customer_segment, breakdown
+		FROM accrual.orders WHERE program = $1 AND number = $2`,
+	stmtUpdateStatusAndAccrual: `UPDATE accrual.orders SET status = $3, accrual = $4 WHERE program = $1 AND number = $2`,
+	stmtSetProcessed:           `UPDATE accrual.orders SET status = $3, accrual = $4, breakdown = $5 WHERE program = $1 AND number = $2`,
+	stmtApplyReturn: `UPDATE accrual.orders
+		SET status = $3, accrual = $4, returned = $5, adjustment = $6, breakdown = $7
+		WHERE program = $1 AND number = $2 AND returned = $8`,
+	stmtGetOrderStats: `SELECT status, COUNT(*), COALESCE(SUM(accrual), 0)
+		FROM accrual.orders WHERE program = $1
+		GROUP BY status`,
+
+	stmtCreateRule: `INSERT INTO accrual.reward_rules (program, match, reward, reward_type, customer_segment)
+		VALUES ($1, $2, $3, $4, $5)`,
+	stmtGetRulesBySegment: `SELECT match, reward, reward_type, customer_segment
+		FROM accrual.reward_rules
+		WHERE program = $1 AND customer_segment IN ('', $2)
+		ORDER BY customer_segment = '' ASC`,
+	stmtExistsRuleByMatch: `SELECT EXISTS (SELECT 1 FROM accrual.reward_rules
+		WHERE program = $1 AND match = $2 AND customer_segment = $3)`,
+	stmtCountRules: `SELECT COUNT(*) FROM accrual.reward_rules WHERE program = $1`,
+}
+
+// NewPostgresPool создаёт пул соединений и подготавливает выражения на каждом новом соединении.
+// Схема БД должна быть актуальной: миграции применяются до создания пула
+func NewPostgresPool(ctx context.Context, dsn string, maxConns, minConns int) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DSN: %w", err)
+	}
+
+	if maxConns > 0 {
+		poolConfig.MaxConns = int32(maxConns)
+	}
+	poolConfig.MinConns = int32(minConns)
+
+	poolConfig.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		for name, sql := range statements {
+			if _, err := conn.Prepare(ctx, name, sql); err != nil {
+				return fmt.Errorf("failed to prepare %s statement: %w", name, err)
+			}
+		}
+		return nil
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pool: %w", err)
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return pool, nil
+}
@@ -2,23 +2,21 @@ package repository
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
 	"errors"
 
-	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/prbllm/go-loyalty-service/internal/accrual/model"
 )
 
-var psql = squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
-
 // PostgresOrderRepo реализует OrderRepository с использованием PostgreSQL
 type PostgresOrderRepo struct {
-	db *sql.DB
+	pool *pgxpool.Pool
 }
 
-func NewPostgresOrderRepo(db *sql.DB) *PostgresOrderRepo {
-	return &PostgresOrderRepo{db: db}
+func NewPostgresOrderRepo(pool *pgxpool.Pool) *PostgresOrderRepo {
+	return &PostgresOrderRepo{pool: pool}
 }
 
 func (r *PostgresOrderRepo) Create(ctx context.Context, order model.Order) error {
@@ -27,78 +25,121 @@ func (r *PostgresOrderRepo) Create(ctx context.Context, order model.Order) error
 		return err
 	}
 
-	query, args, err := psql.
-		Insert("accrual.orders").
-		Columns("number", "status", "accrual", "goods").
-		Values(order.Number, string(order.Status), order.Accrual, goodsData).
-		ToSql()
+	// Проверка существования и вставка — один запрос, без гонки между ними
+	tag, err := r.pool.Exec(ctx, stmtCreateOrder, order.Program, order.Number, string(order.Status), order.Accrual, goodsData, order.Segment)
 	if err != nil {
 		return err
 	}
-
-	_, err = r.db.ExecContext(ctx, query, args...)
-	return err
+	if tag.RowsAffected() == 0 {
+		return ErrOrderExists
+	}
+	return nil
 }
 
-func (r *PostgresOrderRepo) IsOrderExists(ctx context.Context, number string) (bool, error) {
-	query, args, err := psql.
-		Select("1").
-		From("accrual.orders").
-		Where(squirrel.Eq{"number": number}).
-		Limit(1).
-		ToSql()
-	if err != nil {
-		return false, err
-	}
-	var dummy int
-	err = r.db.QueryRowContext(ctx, query, args...).Scan(&dummy)
+func (r *PostgresOrderRepo) GetByNumber(ctx context.Context, program, number string) (*model.Order, error) {
+	var goodsData, returnedData, breakdownData []byte
+	order := model.Order{Program: program, Number: number}
+	err := r.pool.QueryRow(ctx, stmtGetOrderByNumber, program, number).
+		Scan(&order.Status, &order.Accrual, &goodsData, &returnedData, &order.Adjustment, &order.Segment, &breakdownData)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return false, nil
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOrderNotFound
 		}
-		return false, err
+		return nil, err
 	}
-	return true, nil
-}
 
-func (r *PostgresOrderRepo) GetByNumber(ctx context.Context, number string) (*model.Order, error) {
-	query, args, err := psql.
-		Select("status", "accrual", "goods").
-		From("accrual.orders").
-		Where(squirrel.Eq{"number": number}).
-		ToSql()
+	err = json.Unmarshal(goodsData, &order.Goods)
 	if err != nil {
 		return nil, err
 	}
 
-	row := r.db.QueryRowContext(ctx, query, args...)
-
-	var goodsData []byte
-	order := model.Order{Number: number}
-	err = row.Scan(&order.Status, &order.Accrual, &goodsData)
+	err = json.Unmarshal(returnedData, &order.Returned)
 	if err != nil {
 		return nil, err
 	}
 
-	err = json.Unmarshal(goodsData, &order.Goods)
-	if err != nil {
-		return nil, err
+	if breakdownData != nil {
+		err = json.Unmarshal(breakdownData, &order.Breakdown)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &order, nil
 }
 
-func (r *PostgresOrderRepo) UpdateStatusAndAccrual(ctx context.Context, number string, status model.OrderStatus, accrual *int64) error {
-	query, args, err := psql.
-		Update("accrual.orders").
-		Set("status", string(status)).
-		Set("accrual", accrual).
-		Where(squirrel.Eq{"number": number}).
-		ToSql()
+func (r *PostgresOrderRepo) UpdateStatusAndAccrual(ctx context.Context, program, number string, status model.OrderStatus, accrual *int64) error {
+	_, err := r.pool.Exec(ctx, stmtUpdateStatusAndAccrual, program, number, string(status), accrual)
+	return err
+}
+
+func (r *PostgresOrderRepo) SetProcessed(ctx context.Context, program, number string, accrual int64, breakdown []model.AccrualItem) error {
+	breakdownData, err := json.Marshal(breakdown)
 	if err != nil {
 		return err
 	}
 
-	_, err = r.db.ExecContext(ctx, query, args...)
+	_, err = r.pool.Exec(ctx, stmtSetProcessed, program, number, string(model.Processed), accrual, breakdownData)
 	return err
 }
+
+func (r *PostgresOrderRepo) ApplyReturn(ctx context.Context, order model.Order, prevReturned []model.Good) error {
+	returnedData, err := marshalGoods(order.Returned)
+	if err != nil {
+		return err
+	}
+
+	prevReturnedData, err := marshalGoods(prevReturned)
+	if err != nil {
+		return err
+	}
+
+	breakdownData, err := json.Marshal(order.Breakdown)
+	if err != nil {
+		return err
+	}
+
+	tag, err := r.pool.Exec(ctx, stmtApplyReturn,
+		order.Program, order.Number, string(order.Status), order.Accrual, returnedData, order.Adjustment, breakdownData, prevReturnedData)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrOrderModified
+	}
+	return nil
+}
+
+func (r *PostgresOrderRepo) GetStats(ctx context.Context, program string) (map[model.OrderStatus]int64, int64, error) {
+	rows, err := r.pool.Query(ctx, stmtGetOrderStats, program)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	counts := make(map[model.OrderStatus]int64)
+	var total int64
+	for rows.Next() {
+		var status model.OrderStatus
+		var count, accrual int64
+		if err := rows.Scan(&status, &count, &accrual); err != nil {
+			return nil, 0, err
+		}
+		counts[status] = count
+		total += accrual
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return counts, total, nil
+}
+
+// marshalGoods сериализует список товаров, nil сохраняется как пустой массив
+func marshalGoods(goods []model.Good) ([]byte, error) {
+	if goods == nil {
+		goods = []model.Good{}
+	}
+	return json.Marshal(goods)
+}
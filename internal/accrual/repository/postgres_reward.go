@@ -2,46 +2,27 @@ package repository
 
 import (
 	"context"
-	"database/sql"
-	"errors"
 
-	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/prbllm/go-loyalty-service/internal/accrual/model"
 )
 
 // PostgresRewardRepo реализует RewardRepository с использованием PostgreSQL
 type PostgresRewardRepo struct {
-	db *sql.DB
+	pool *pgxpool.Pool
 }
 
-func NewPostgresRewardRepo(db *sql.DB) *PostgresRewardRepo {
-	return &PostgresRewardRepo{db: db}
+func NewPostgresRewardRepo(pool *pgxpool.Pool) *PostgresRewardRepo {
+	return &PostgresRewardRepo{pool: pool}
 }
 
 func (r *PostgresRewardRepo) Create(ctx context.Context, rule model.RewardRule) error {
-	query, args, err := psql.
-		Insert("accrual.reward_rules").
-		Columns("match", "reward", "reward_type").
-		Values(rule.Match, rule.Reward, string(rule.RewardType)).
-		ToSql()
-	if err != nil {
-		return err
-	}
-
-	_, err = r.db.ExecContext(ctx, query, args...)
+	_, err := r.pool.Exec(ctx, stmtCreateRule, rule.Program, rule.Match, rule.Reward, string(rule.RewardType), rule.Segment)
 	return err
 }
 
-func (r *PostgresRewardRepo) GetAll(ctx context.Context) ([]model.RewardRule, error) {
-	query, args, err := psql.
-		Select("match", "reward", "reward_type").
-		From("accrual.reward_rules").
-		ToSql()
-	if err != nil {
-		return nil, err
-	}
-
-	rows, err := r.db.QueryContext(ctx, query, args...)
+func (r *PostgresRewardRepo) GetBySegment(ctx context.Context, program, segment string) ([]model.RewardRule, error) {
+	rows, err := r.pool.Query(ctx, stmtGetRulesBySegment, program, segment)
 	if err != nil {
 		return nil, err
 	}
@@ -49,8 +30,8 @@ func (r *PostgresRewardRepo) GetAll(ctx context.Context) ([]model.RewardRule, er
 
 	var rules []model.RewardRule
 	for rows.Next() {
-		var rule model.RewardRule
-		err := rows.Scan(&rule.Match, &rule.Reward, &rule.RewardType)
+		rule := model.RewardRule{Program: program}
+		err := rows.Scan(&rule.Match, &rule.Reward, &rule.RewardType, &rule.Segment)
 		if err != nil {
 			return nil, err
 		}
@@ -64,24 +45,20 @@ func (r *PostgresRewardRepo) GetAll(ctx context.Context) ([]model.RewardRule, er
 	return rules, nil
 }
 
-func (r *PostgresRewardRepo) ExistsByMatch(ctx context.Context, match string) (bool, error) {
-	query, args, err := psql.
-		Select("1").
-		From("accrual.reward_rules").
-		Where(squirrel.Eq{"match": match}).
-		Limit(1).
-		ToSql()
+func (r *PostgresRewardRepo) ExistsByMatch(ctx context.Context, program, match, segment string) (bool, error) {
+	var exists bool
+	err := r.pool.QueryRow(ctx, stmtExistsRuleByMatch, program, match, segment).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
+	return exists, nil
+}
 
-	var dummy int
-	err = r.db.QueryRowContext(ctx, query, args...).Scan(&dummy)
+func (r *PostgresRewardRepo) Count(ctx context.Context, program string) (int64, error) {
+	var count int64
+	err := r.pool.QueryRow(ctx, stmtCountRules, program).Scan(&count)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return false, nil
-		}
-		return false, err
+		return 0, err
 	}
-	return true, nil
+	return count, nil
 }
@@ -10,12 +10,16 @@ import (
 
 // RewardRepository отвечает за операции с правилами вознаграждений
 type RewardRepository interface {
-	// Create создаёт новое правило начисления
+	// Create создаёт новое правило начисления в программе rule.Program
 	Create(ctx context.Context, rule model.RewardRule) error
 
-	// GetAll возвращает все активные правила
-	GetAll(ctx context.Context) ([]model.RewardRule, error)
+	// GetBySegment возвращает правила программы для сегмента и общие правила программы,
+	// правила сегмента идут первыми
+	GetBySegment(ctx context.Context, program, segment string) ([]model.RewardRule, error)
 
-	// ExistsByMatch проверяет, существует ли правило с указанным match-ключом
-	ExistsByMatch(ctx context.Context, match string) (bool, error)
+	// ExistsByMatch проверяет, существует ли в программе правило с указанным match-ключом в сегменте
+	ExistsByMatch(ctx context.Context, program, match, segment string) (bool, error)
+
+	// Count возвращает количество правил программы
+	Count(ctx context.Context, program string) (int64, error)
 }
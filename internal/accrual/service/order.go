@@ -5,6 +5,7 @@ import (
 	"errors"
 	"math"
 	"strings"
+	"time"
 
 	"github.com/prbllm/go-loyalty-service/internal/accrual/model"
 	"github.com/prbllm/go-loyalty-service/internal/accrual/repository"
@@ -13,10 +14,21 @@ import (
 
 //go:generate mockgen -source=order.go -destination=../../mocks/accrual/order_service.go -package=mocks
 
-// OrderService отвечает за бизнес-логику, связанную с заказами
+// OrderService отвечает за бизнес-логику, связанную с заказами.
+// Все операции выполняются в рамках программы лояльности program
 type OrderService interface {
-	RegisterOrder(ctx context.Context, reqOrder model.RegisterOrderRequest) error
-	GetOrder(ctx context.Context, number string) (*model.Order, error)
+	RegisterOrder(ctx context.Context, program string, reqOrder model.RegisterOrderRequest) error
+	GetOrder(ctx context.Context, program, number string) (*model.Order, error)
+	ReturnGoods(ctx context.Context, program, number string, reqReturn model.ReturnGoodsRequest) (*model.Order, error)
+	GetStats(ctx context.Context, program string) (*model.ProgramStats, error)
+}
+
+// ProcessingFaults позволяет имитировать медленный и нестабильный расчёт начислений
+type ProcessingFaults interface {
+	// ProcessingDelay возвращает задержку перед расчётом начисления
+	ProcessingDelay() time.Duration
+	// ShouldInvalidate решает, нужно ли пометить заказ как INVALID
+	ShouldInvalidate() bool
 }
 
 // orderService — реализация OrderService
@@ -24,104 +36,241 @@ type orderService struct {
 	orderRepo  repository.OrderRepository
 	rewardRepo repository.RewardRepository
 	logger     logger.Logger
+	faults     ProcessingFaults
+	// lifetime — контекст жизни сервиса; его отмена прерывает фоновый расчёт начислений
+	lifetime context.Context
+}
+
+// OrderServiceOption настраивает необязательные параметры OrderService
+type OrderServiceOption func(*orderService)
+
+// WithProcessingFaults включает имитацию сбоев при расчёте начислений
+func WithProcessingFaults(faults ProcessingFaults) OrderServiceOption {
+	return func(s *orderService) {
+		s.faults = faults
+	}
+}
+
+// WithLifetime задаёт контекст жизни сервиса, который отменяется при остановке.
+// Фоновый расчёт начислений переживает запрос, но не остановку сервиса
+func WithLifetime(ctx context.Context) OrderServiceOption {
+	return func(s *orderService) {
+		s.lifetime = ctx
+	}
 }
 
 // NewOrderService создаёт новый экземпляр OrderService
-func NewOrderService(orderRepo repository.OrderRepository, rewardRepo repository.RewardRepository, logger logger.Logger) OrderService {
-	return &orderService{
+func NewOrderService(orderRepo repository.OrderRepository, rewardRepo repository.RewardRepository, logger logger.Logger, opts ...OrderServiceOption) OrderService {
+	s := &orderService{
 		orderRepo:  orderRepo,
 		rewardRepo: rewardRepo,
 		logger:     logger,
+		lifetime:   context.Background(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 var ErrOrderAlreadyExists = errors.New("order already exists")
 
-func (s *orderService) RegisterOrder(ctx context.Context, reqOrder model.RegisterOrderRequest) error {
-	// Проверяем, существует ли заказ с таким номером
-	exists, err := s.orderRepo.IsOrderExists(ctx, reqOrder.Number)
-	// Другая ошибка БД
-	if err != nil {
-		return err
-	}
-	// Заказ найден → дубликат
-	if exists {
-		return ErrOrderAlreadyExists
-	}
-
+func (s *orderService) RegisterOrder(ctx context.Context, program string, reqOrder model.RegisterOrderRequest) error {
 	var goods []model.Good
 	for _, item := range reqOrder.Goods {
-		// Переводим рубли в копейки: 47399.99 → 4739999
-		// Округляем до ближайшего целого копейки (используем 2 знака)
-		priceInCents := int64(math.Round(item.Price * 100))
-
 		goods = append(goods, model.Good{
 			Description: item.Description,
-			Price:       priceInCents,
+			Price:       rublesToCents(item.Price),
 		})
 	}
 
 	order := model.Order{
-		Number: reqOrder.Number,
-		Goods:  goods,
-		Status: model.Registered,
+		Program: program,
+		Number:  reqOrder.Number,
+		Goods:   goods,
+		Status:  model.Registered,
+		Segment: reqOrder.Segment,
 	}
 
-	err = s.orderRepo.Create(ctx, order)
-
+	err := s.orderRepo.Create(ctx, order)
+	// Заказ найден → дубликат
+	if errors.Is(err, repository.ErrOrderExists) {
+		return ErrOrderAlreadyExists
+	}
 	if err != nil {
 		return err
 	}
 
 	go func(ctx context.Context) {
-		s.setOrderProcessing(ctx, order.Number)
-		accrual, err := s.processOrder(ctx, &order)
+		s.setOrderProcessing(ctx, &order)
+		if s.faults != nil {
+			if !sleepContext(ctx, s.faults.ProcessingDelay()) {
+				return
+			}
+			if s.faults.ShouldInvalidate() {
+				s.setOrderInvalid(ctx, &order)
+				return
+			}
+		}
+		accrual, breakdown, err := s.processOrder(ctx, &order)
 		if err != nil {
 			s.logger.Error(err)
-			s.setOrderInvalid(ctx, order.Number)
+			s.setOrderInvalid(ctx, &order)
 		} else {
-			s.setOrderProcessed(ctx, order.Number, &accrual)
+			s.setOrderProcessed(ctx, &order, accrual, breakdown)
 		}
-	}(context.WithoutCancel(ctx))
+	}(s.lifetime)
 
 	return nil
 }
 
 var ErrOrderNotFound = errors.New("order not found")
 
-func (s *orderService) GetOrder(ctx context.Context, number string) (*model.Order, error) {
-	// Проверяем, существует ли заказ с таким номером
-	exists, err := s.orderRepo.IsOrderExists(ctx, number)
-	// Другая ошибка БД
+func (s *orderService) GetOrder(ctx context.Context, program, number string) (*model.Order, error) {
+	order, err := s.orderRepo.GetByNumber(ctx, program, number)
+	// Заказ не найден
+	if errors.Is(err, repository.ErrOrderNotFound) {
+		return nil, ErrOrderNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
-	// Заказ не найден
-	if !exists {
-		return nil, ErrOrderNotFound
+
+	return order, nil
+}
+
+var (
+	ErrOrderNotReturnable = errors.New("order accrual is not calculated yet")
+	ErrGoodsNotInOrder    = errors.New("returned goods are not in the order")
+)
+
+func (s *orderService) ReturnGoods(ctx context.Context, program, number string, reqReturn model.ReturnGoodsRequest) (*model.Order, error) {
+	order, err := s.GetOrder(ctx, program, number)
+	if err != nil {
+		return nil, err
 	}
 
-	order, err := s.orderRepo.GetByNumber(ctx, number)
+	// Возврат возможен только для заказов с рассчитанным начислением
+	if order.Status != model.Processed && order.Status != model.Adjusted {
+		return nil, ErrOrderNotReturnable
+	}
 
+	// Каждый возвращённый товар должен быть среди ещё не возвращённых товаров заказа
+	remaining := subtractGoods(order.Goods, order.Returned)
+	returned := append([]model.Good{}, order.Returned...)
+	for _, item := range reqReturn.Goods {
+		good := model.Good{Description: item.Description, Price: rublesToCents(item.Price)}
+		var ok bool
+		remaining, ok = removeGood(remaining, good)
+		if !ok {
+			return nil, ErrGoodsNotInOrder
+		}
+		returned = append(returned, good)
+	}
+
+	// Пересчитываем начисление по оставшимся товарам
+	var current, adjustment int64
+	if order.Accrual != nil {
+		current = *order.Accrual
+	}
+	if order.Adjustment != nil {
+		adjustment = *order.Adjustment
+	}
+	original := current - adjustment
+
+	accrual, breakdown, err := s.processOrder(ctx, &model.Order{Program: program, Number: number, Goods: remaining, Segment: order.Segment})
 	if err != nil {
 		return nil, err
 	}
+	// Возврат не может увеличить начисление, даже если правила изменились
+	if accrual > current {
+		accrual = current
+	}
+	adjustment = accrual - original
 
-	return order, nil
+	status := model.Adjusted
+	if len(remaining) == 0 || accrual == 0 {
+		status = model.Reversed
+	}
+
+	updated := model.Order{
+		Program:    program,
+		Number:     number,
+		Goods:      order.Goods,
+		Status:     status,
+		Accrual:    &accrual,
+		Returned:   returned,
+		Adjustment: &adjustment,
+		Segment:    order.Segment,
+		Breakdown:  breakdown,
+	}
+
+	if err := s.orderRepo.ApplyReturn(ctx, updated, order.Returned); err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+func (s *orderService) GetStats(ctx context.Context, program string) (*model.ProgramStats, error) {
+	orders, accrual, err := s.orderRepo.GetStats(ctx, program)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := s.rewardRepo.Count(ctx, program)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.ProgramStats{
+		Program: program,
+		Orders:  orders,
+		Accrual: float64(accrual) / 100,
+		Rules:   rules,
+	}, nil
+}
+
+// rublesToCents переводит рубли в копейки: 47399.99 → 4739999
+// Округляем до ближайшего целого копейки (используем 2 знака)
+func rublesToCents(price float64) int64 {
+	return int64(math.Round(price * 100))
 }
 
-func (s *orderService) processOrder(ctx context.Context, order *model.Order) (int64, error) {
-	// Получаем все правила начисления
-	rules, err := s.rewardRepo.GetAll(ctx)
+// subtractGoods возвращает товары заказа без уже возвращённых
+func subtractGoods(goods, returned []model.Good) []model.Good {
+	remaining := append([]model.Good{}, goods...)
+	for _, good := range returned {
+		remaining, _ = removeGood(remaining, good)
+	}
+	return remaining
+}
+
+// removeGood удаляет из списка первое вхождение товара
+func removeGood(goods []model.Good, good model.Good) ([]model.Good, bool) {
+	for i, g := range goods {
+		if g == good {
+			return append(goods[:i:i], goods[i+1:]...), true
+		}
+	}
+	return goods, false
+}
+
+func (s *orderService) processOrder(ctx context.Context, order *model.Order) (int64, []model.AccrualItem, error) {
+	// Получаем правила программы заказа: правила сегмента покупателя и общие правила
+	rules, err := s.rewardRepo.GetBySegment(ctx, order.Program, order.Segment)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
 	var totalAccrualRub float64 // накапливаем в рублях (дробно)
+	breakdown := make([]model.AccrualItem, 0, len(order.Goods))
 
 	// Проходим по каждому товару в заказе
 	for _, good := range order.Goods {
-		// Ищем первое правило, match которого содержится в описании товара
+		item := model.AccrualItem{Description: good.Description}
+		// Ищем первое правило, match которого содержится в описании товара.
+		// Правила сегмента идут раньше общих, поэтому имеют приоритет
 		for _, rule := range rules {
 			if strings.Contains(good.Description, rule.Match) {
 				var accrualRub float64
@@ -133,24 +282,41 @@ func (s *orderService) processOrder(ctx context.Context, order *model.Order) (in
 					accrualRub = rule.Reward
 				}
 				totalAccrualRub += accrualRub
+				item.Match = rule.Match
+				item.Segment = rule.Segment
+				item.Accrual = int64(accrualRub * 100)
 				break // одно правило на товар
 			}
 		}
+		breakdown = append(breakdown, item)
 	}
 
 	// Возвращаем в копейках
-	return int64(totalAccrualRub * 100), nil
+	return int64(totalAccrualRub * 100), breakdown, nil
 
 }
 
-func (s *orderService) setOrderProcessing(ctx context.Context, number string) error {
-	return s.orderRepo.UpdateStatusAndAccrual(ctx, number, model.Processing, nil)
+// sleepContext ждёт d или отмены ctx; возвращает false, если ctx отменён раньше
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (s *orderService) setOrderProcessing(ctx context.Context, order *model.Order) error {
+	return s.orderRepo.UpdateStatusAndAccrual(ctx, order.Program, order.Number, model.Processing, nil)
 }
 
-func (s *orderService) setOrderInvalid(ctx context.Context, number string) error {
-	return s.orderRepo.UpdateStatusAndAccrual(ctx, number, model.Invalid, nil)
+func (s *orderService) setOrderInvalid(ctx context.Context, order *model.Order) error {
+	return s.orderRepo.UpdateStatusAndAccrual(ctx, order.Program, order.Number, model.Invalid, nil)
 }
 
-func (s *orderService) setOrderProcessed(ctx context.Context, number string, accrual *int64) error {
-	return s.orderRepo.UpdateStatusAndAccrual(ctx, number, model.Processed, accrual)
+func (s *orderService) setOrderProcessed(ctx context.Context, order *model.Order, accrual int64, breakdown []model.AccrualItem) error {
+	return s.orderRepo.SetProcessed(ctx, order.Program, order.Number, accrual, breakdown)
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/prbllm/go-loyalty-service/internal/accrual/model"
+	"github.com/prbllm/go-loyalty-service/internal/accrual/repository"
 	"github.com/prbllm/go-loyalty-service/internal/logger"
 	mocks "github.com/prbllm/go-loyalty-service/internal/mocks/accrual"
 	"github.com/stretchr/testify/require"
@@ -18,23 +19,13 @@ func Test_orderService_RegisterOrder(t *testing.T) {
 		mockSetup   func(*mocks.MockOrderRepository, *mocks.MockRewardRepository)
 		expectedErr error
 	}{
-		{
-			name: "order exists error",
-			order: model.RegisterOrderRequest{
-				Number: "1234567890",
-			},
-			mockSetup: func(m *mocks.MockOrderRepository, r *mocks.MockRewardRepository) {
-				m.EXPECT().IsOrderExists(gomock.Any(), "1234567890").Return(false, errors.New("db error"))
-			},
-			expectedErr: errors.New("db error"),
-		},
 		{
 			name: "order already exists",
 			order: model.RegisterOrderRequest{
 				Number: "1234567890",
 			},
 			mockSetup: func(m *mocks.MockOrderRepository, r *mocks.MockRewardRepository) {
-				m.EXPECT().IsOrderExists(gomock.Any(), "1234567890").Return(true, nil)
+				m.EXPECT().Create(gomock.Any(), model.Order{Program: model.DefaultProgram, Number: "1234567890", Status: model.Registered, Accrual: nil}).Return(repository.ErrOrderExists)
 			},
 			expectedErr: ErrOrderAlreadyExists,
 		},
@@ -44,8 +35,7 @@ func Test_orderService_RegisterOrder(t *testing.T) {
 				Number: "1234567890",
 			},
 			mockSetup: func(m *mocks.MockOrderRepository, r *mocks.MockRewardRepository) {
-				m.EXPECT().IsOrderExists(gomock.Any(), "1234567890").Return(false, nil)
-				m.EXPECT().Create(gomock.Any(), model.Order{Number: "1234567890", Status: model.Registered, Accrual: nil}).Return(errors.New("db error"))
+				m.EXPECT().Create(gomock.Any(), model.Order{Program: model.DefaultProgram, Number: "1234567890", Status: model.Registered, Accrual: nil}).Return(errors.New("db error"))
 			},
 			expectedErr: errors.New("db error"),
 		},
@@ -62,7 +52,7 @@ func Test_orderService_RegisterOrder(t *testing.T) {
 			logger := logger.NewNop()
 			orderService := NewOrderService(mockOrderRepo, mockRewardRepo, logger)
 
-			err := orderService.RegisterOrder(t.Context(), tt.order)
+			err := orderService.RegisterOrder(t.Context(), model.DefaultProgram, tt.order)
 			require.Equal(t, err, tt.expectedErr)
 		})
 	}
@@ -80,7 +70,7 @@ func Test_orderService_GetOrder(t *testing.T) {
 			name:   "internal db error",
 			number: "1234567890",
 			mockSetup: func(m *mocks.MockOrderRepository) {
-				m.EXPECT().IsOrderExists(gomock.Any(), "1234567890").Return(false, errors.New("db error"))
+				m.EXPECT().GetByNumber(gomock.Any(), model.DefaultProgram, "1234567890").Return(nil, errors.New("db error"))
 			},
 			want:        nil,
 			expectedErr: errors.New("db error"),
@@ -89,27 +79,16 @@ func Test_orderService_GetOrder(t *testing.T) {
 			name:   "order not found",
 			number: "1234567890",
 			mockSetup: func(m *mocks.MockOrderRepository) {
-				m.EXPECT().IsOrderExists(gomock.Any(), "1234567890").Return(false, nil)
+				m.EXPECT().GetByNumber(gomock.Any(), model.DefaultProgram, "1234567890").Return(nil, repository.ErrOrderNotFound)
 			},
 			want:        nil,
 			expectedErr: ErrOrderNotFound,
 		},
-		{
-			name:   "getbynumber internal db error",
-			number: "1234567890",
-			mockSetup: func(m *mocks.MockOrderRepository) {
-				m.EXPECT().IsOrderExists(gomock.Any(), "1234567890").Return(true, nil)
-				m.EXPECT().GetByNumber(gomock.Any(), "1234567890").Return(nil, errors.New("db error"))
-			},
-			want:        nil,
-			expectedErr: errors.New("db error"),
-		},
 		{
 			name:   "order found",
 			number: "1234567890",
 			mockSetup: func(m *mocks.MockOrderRepository) {
-				m.EXPECT().IsOrderExists(gomock.Any(), "1234567890").Return(true, nil)
-				m.EXPECT().GetByNumber(gomock.Any(), "1234567890").Return(&model.Order{Number: "12345678900"}, nil)
+				m.EXPECT().GetByNumber(gomock.Any(), model.DefaultProgram, "1234567890").Return(&model.Order{Number: "12345678900"}, nil)
 			},
 			want:        &model.Order{Number: "12345678900"},
 			expectedErr: nil,
@@ -127,9 +106,158 @@ func Test_orderService_GetOrder(t *testing.T) {
 			logger := logger.NewNop()
 			orderService := NewOrderService(mockOrderRepo, mockRewardRepo, logger)
 
-			order, err := orderService.GetOrder(t.Context(), tt.number)
+			order, err := orderService.GetOrder(t.Context(), model.DefaultProgram, tt.number)
 			require.Equal(t, order, tt.want)
 			require.Equal(t, err, tt.expectedErr)
 		})
 	}
 }
+
+func Test_orderService_ReturnGoods(t *testing.T) {
+	ptr := func(v int64) *int64 { return &v }
+
+	kettle := model.Good{Description: "Чайник Bork", Price: 700000}
+	iron := model.Good{Description: "Утюг Philips", Price: 300000}
+	rules := []model.RewardRule{
+		{Match: "Bork", Reward: 10, RewardType: model.RewardTypePercent},
+		{Match: "Philips", Reward: 100, RewardType: model.RewardTypePoints},
+	}
+
+	returnOf := func(goods ...model.Good) model.ReturnGoodsRequest {
+		var req model.ReturnGoodsRequest
+		for _, g := range goods {
+			req.Goods = append(req.Goods, model.GoodRequest{Description: g.Description, Price: float64(g.Price) / 100})
+		}
+		return req
+	}
+
+	tests := []struct {
+		name        string
+		request     model.ReturnGoodsRequest
+		mockSetup   func(*mocks.MockOrderRepository, *mocks.MockRewardRepository)
+		want        *model.Order
+		expectedErr error
+	}{
+		{
+			name:    "order not found",
+			request: returnOf(kettle),
+			mockSetup: func(m *mocks.MockOrderRepository, r *mocks.MockRewardRepository) {
+				m.EXPECT().GetByNumber(gomock.Any(), model.DefaultProgram, "5354354162584").Return(nil, repository.ErrOrderNotFound)
+			},
+			expectedErr: ErrOrderNotFound,
+		},
+		{
+			name:    "order not processed yet",
+			request: returnOf(kettle),
+			mockSetup: func(m *mocks.MockOrderRepository, r *mocks.MockRewardRepository) {
+				m.EXPECT().GetByNumber(gomock.Any(), model.DefaultProgram, "5354354162584").Return(&model.Order{
+					Number: "5354354162584", Goods: []model.Good{kettle}, Status: model.Processing,
+				}, nil)
+			},
+			expectedErr: ErrOrderNotReturnable,
+		},
+		{
+			name:    "goods not in order",
+			request: returnOf(iron),
+			mockSetup: func(m *mocks.MockOrderRepository, r *mocks.MockRewardRepository) {
+				m.EXPECT().GetByNumber(gomock.Any(), model.DefaultProgram, "5354354162584").Return(&model.Order{
+					Number: "5354354162584", Goods: []model.Good{kettle}, Status: model.Processed, Accrual: ptr(70000),
+				}, nil)
+			},
+			expectedErr: ErrGoodsNotInOrder,
+		},
+		{
+			name:    "partial return",
+			request: returnOf(kettle),
+			mockSetup: func(m *mocks.MockOrderRepository, r *mocks.MockRewardRepository) {
+				m.EXPECT().GetByNumber(gomock.Any(), model.DefaultProgram, "5354354162584").Return(&model.Order{
+					Number: "5354354162584", Goods: []model.Good{kettle, iron}, Status: model.Processed, Accrual: ptr(80000),
+				}, nil)
+				r.EXPECT().GetBySegment(gomock.Any(), model.DefaultProgram, "").Return(rules, nil)
+				m.EXPECT().ApplyReturn(gomock.Any(), gomock.Any(), nil).Return(nil)
+			},
+			want: &model.Order{
+				Program:    model.DefaultProgram,
+				Number:     "5354354162584",
+				Goods:      []model.Good{kettle, iron},
+				Status:     model.Adjusted,
+				Accrual:    ptr(10000),
+				Returned:   []model.Good{kettle},
+				Adjustment: ptr(-70000),
+				Breakdown:  []model.AccrualItem{{Description: "Утюг Philips", Match: "Philips", Accrual: 10000}},
+			},
+		},
+		{
+			name:    "full return after partial",
+			request: returnOf(iron),
+			mockSetup: func(m *mocks.MockOrderRepository, r *mocks.MockRewardRepository) {
+				m.EXPECT().GetByNumber(gomock.Any(), model.DefaultProgram, "5354354162584").Return(&model.Order{
+					Number: "5354354162584", Goods: []model.Good{kettle, iron}, Status: model.Adjusted,
+					Accrual: ptr(10000), Returned: []model.Good{kettle}, Adjustment: ptr(-70000),
+				}, nil)
+				r.EXPECT().GetBySegment(gomock.Any(), model.DefaultProgram, "").Return(rules, nil)
+				m.EXPECT().ApplyReturn(gomock.Any(), gomock.Any(), []model.Good{kettle}).Return(nil)
+			},
+			want: &model.Order{
+				Program:    model.DefaultProgram,
+				Number:     "5354354162584",
+				Goods:      []model.Good{kettle, iron},
+				Status:     model.Reversed,
+				Accrual:    ptr(0),
+				Returned:   []model.Good{kettle, iron},
+				Adjustment: ptr(-80000),
+				Breakdown:  []model.AccrualItem{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockOrderRepo := mocks.NewMockOrderRepository(ctrl)
+			mockRewardRepo := mocks.NewMockRewardRepository(ctrl)
+			tt.mockSetup(mockOrderRepo, mockRewardRepo)
+
+			orderService := NewOrderService(mockOrderRepo, mockRewardRepo, logger.NewNop())
+
+			order, err := orderService.ReturnGoods(t.Context(), model.DefaultProgram, "5354354162584", tt.request)
+			require.Equal(t, tt.want, order)
+			require.Equal(t, tt.expectedErr, err)
+		})
+	}
+}
+
+func Test_orderService_processOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockOrderRepo := mocks.NewMockOrderRepository(ctrl)
+	mockRewardRepo := mocks.NewMockRewardRepository(ctrl)
+	// Правила берутся только из программы заказа
+	mockRewardRepo.EXPECT().GetBySegment(gomock.Any(), "brand-a", "vip").Return([]model.RewardRule{
+		{Match: "Bork", Reward: 20, RewardType: model.RewardTypePercent, Segment: "vip"},
+		{Match: "Bork", Reward: 10, RewardType: model.RewardTypePercent},
+		{Match: "Philips", Reward: 100, RewardType: model.RewardTypePoints},
+	}, nil)
+
+	s := &orderService{orderRepo: mockOrderRepo, rewardRepo: mockRewardRepo, logger: logger.NewNop()}
+
+	accrual, breakdown, err := s.processOrder(t.Context(), &model.Order{
+		Program: "brand-a",
+		Number:  "5354354162584",
+		Segment: "vip",
+		Goods: []model.Good{
+			{Description: "Чайник Bork", Price: 700000},
+			{Description: "Утюг Philips", Price: 300000},
+			{Description: "Кружка", Price: 50000},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(150000), accrual)
+	require.Equal(t, []model.AccrualItem{
+		{Description: "Чайник Bork", Match: "Bork", Segment: "vip", Accrual: 140000},
+		{Description: "Утюг Philips", Match: "Philips", Accrual: 10000},
+		{Description: "Кружка"},
+	}, breakdown)
+}
@@ -13,7 +13,7 @@ import (
 
 // RewardService отвечает за бизнес-логику, связанную с правилами вознаграждений
 type RewardService interface {
-	RegisterReward(ctx context.Context, reward model.RewardRule) error
+	RegisterReward(ctx context.Context, program string, reward model.RewardRule) error
 }
 
 // rewardService — реализация RewardService
@@ -32,9 +32,11 @@ func NewRewardService(rewardRepo repository.RewardRepository, logger logger.Logg
 
 var ErrMatchAlreadyExists = errors.New("match already exists")
 
-func (s *rewardService) RegisterReward(ctx context.Context, reward model.RewardRule) error {
-	// Проверяем, существует ли правило с таким match
-	exists, err := s.rewardRepo.ExistsByMatch(ctx, reward.Match)
+func (s *rewardService) RegisterReward(ctx context.Context, program string, reward model.RewardRule) error {
+	reward.Program = program
+
+	// Проверяем, существует ли правило с таким match в том же сегменте программы
+	exists, err := s.rewardRepo.ExistsByMatch(ctx, program, reward.Match, reward.Segment)
 	if err != nil {
 		s.logger.Errorf("accrual: %w", err)
 		return err
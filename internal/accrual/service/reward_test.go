@@ -26,7 +26,7 @@ func Test_rewardService_RegisterReward(t *testing.T) {
 				RewardType: model.RewardTypePercent,
 			},
 			mockSetup: func(m *mocks.MockRewardRepository) {
-				m.EXPECT().ExistsByMatch(gomock.Any(), "Bork").Return(false, errors.New("db error"))
+				m.EXPECT().ExistsByMatch(gomock.Any(), model.DefaultProgram, "Bork", "").Return(false, errors.New("db error"))
 			},
 			expectedErr: errors.New("db error"),
 		},
@@ -38,7 +38,7 @@ func Test_rewardService_RegisterReward(t *testing.T) {
 				RewardType: model.RewardTypePercent,
 			},
 			mockSetup: func(m *mocks.MockRewardRepository) {
-				m.EXPECT().ExistsByMatch(gomock.Any(), "Bork").Return(true, nil)
+				m.EXPECT().ExistsByMatch(gomock.Any(), model.DefaultProgram, "Bork", "").Return(true, nil)
 			},
 			expectedErr: ErrMatchAlreadyExists,
 		},
@@ -50,8 +50,8 @@ func Test_rewardService_RegisterReward(t *testing.T) {
 				RewardType: model.RewardTypePercent,
 			},
 			mockSetup: func(m *mocks.MockRewardRepository) {
-				m.EXPECT().ExistsByMatch(gomock.Any(), "Bork").Return(false, nil)
-				m.EXPECT().Create(gomock.Any(), gomock.Eq(model.RewardRule{Match: "Bork", Reward: 10, RewardType: model.RewardTypePercent})).Return(errors.New("db error"))
+				m.EXPECT().ExistsByMatch(gomock.Any(), model.DefaultProgram, "Bork", "").Return(false, nil)
+				m.EXPECT().Create(gomock.Any(), gomock.Eq(model.RewardRule{Program: model.DefaultProgram, Match: "Bork", Reward: 10, RewardType: model.RewardTypePercent})).Return(errors.New("db error"))
 			},
 			expectedErr: errors.New("db error"),
 		},
@@ -63,8 +63,8 @@ func Test_rewardService_RegisterReward(t *testing.T) {
 				RewardType: model.RewardTypePercent,
 			},
 			mockSetup: func(m *mocks.MockRewardRepository) {
-				m.EXPECT().ExistsByMatch(gomock.Any(), "Bork").Return(false, nil)
-				m.EXPECT().Create(gomock.Any(), gomock.Eq(model.RewardRule{Match: "Bork", Reward: 10, RewardType: model.RewardTypePercent})).Return(nil)
+				m.EXPECT().ExistsByMatch(gomock.Any(), model.DefaultProgram, "Bork", "").Return(false, nil)
+				m.EXPECT().Create(gomock.Any(), gomock.Eq(model.RewardRule{Program: model.DefaultProgram, Match: "Bork", Reward: 10, RewardType: model.RewardTypePercent})).Return(nil)
 			},
 			expectedErr: nil,
 		},
@@ -80,7 +80,7 @@ func Test_rewardService_RegisterReward(t *testing.T) {
 			log := zaptest.NewLogger(t).Sugar()
 			rewardService := NewRewardService(mockRepo, log)
 
-			err := rewardService.RegisterReward(t.Context(), tt.reward)
+			err := rewardService.RegisterReward(t.Context(), model.DefaultProgram, tt.reward)
 			require.Equal(t, err, tt.expectedErr)
 		})
 	}
@@ -0,0 +1,118 @@
+package testmode
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimitWindow — окно, в пределах которого считается лимит запросов
+const rateLimitWindow = time.Minute
+
+// Settings — параметры имитации медленной и нестабильной системы расчёта
+type Settings struct {
+	ProcessingDelay time.Duration // задержка перед расчётом начисления
+	InvalidRate     float64       // доля заказов, случайно помечаемых INVALID (0..1)
+	ErrorRate       float64       // доля запросов GET /api/orders/{number}, завершающихся 500 (0..1)
+	RateLimit       int           // максимум запросов в минуту, 0 = без ограничения
+}
+
+// Injector хранит текущие настройки тестового режима и внедряет сбои
+type Injector struct {
+	mu          sync.RWMutex
+	settings    Settings
+	random      func() float64
+	now         func() time.Time
+	windowStart time.Time
+	requests    int
+}
+
+// New создаёт Injector с начальными настройками
+func New(settings Settings) *Injector {
+	return &Injector{
+		settings: settings,
+		random:   rand.Float64,
+		now:      time.Now,
+	}
+}
+
+// Settings возвращает текущие настройки
+func (i *Injector) Settings() Settings {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.settings
+}
+
+// Update заменяет настройки и сбрасывает счётчик лимита запросов
+func (i *Injector) Update(settings Settings) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.settings = settings
+	i.windowStart = time.Time{}
+	i.requests = 0
+}
+
+// ProcessingDelay возвращает задержку перед расчётом начисления
+func (i *Injector) ProcessingDelay() time.Duration {
+	return i.Settings().ProcessingDelay
+}
+
+// ShouldInvalidate решает, нужно ли пометить заказ как INVALID
+func (i *Injector) ShouldInvalidate() bool {
+	return i.hit(i.Settings().InvalidRate)
+}
+
+// Middleware внедряет ответы 429 и 500 в запросы GET /api/orders/{number}
+func (i *Injector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limit, retryAfter, ok := i.allow(); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+			http.Error(w, fmt.Sprintf("No more than %d requests per minute allowed", limit), http.StatusTooManyRequests)
+			return
+		}
+
+		if i.hit(i.Settings().ErrorRate) {
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allow учитывает запрос в текущем окне и сообщает, укладывается ли он в лимит
+func (i *Injector) allow() (int, time.Duration, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	limit := i.settings.RateLimit
+	if limit <= 0 {
+		return 0, 0, true
+	}
+
+	now := i.now()
+	if now.Sub(i.windowStart) >= rateLimitWindow {
+		i.windowStart = now
+		i.requests = 0
+	}
+
+	i.requests++
+	if i.requests <= limit {
+		return limit, 0, true
+	}
+
+	// Округляем вверх, чтобы клиент не вернулся раньше конца окна
+	retryAfter := i.windowStart.Add(rateLimitWindow).Sub(now)
+	retryAfter = (retryAfter + time.Second - 1).Truncate(time.Second)
+	return limit, retryAfter, false
+}
+
+func (i *Injector) hit(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	return i.random() < rate
+}
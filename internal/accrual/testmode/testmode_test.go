@@ -0,0 +1,117 @@
+package testmode
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestInjector_Middleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		settings       Settings
+		random         float64
+		requests       int
+		expectedStatus int
+		expectedBody   string
+		retryAfter     string
+	}{
+		{
+			name:           "no faults",
+			settings:       Settings{},
+			requests:       3,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "injected internal error",
+			settings:       Settings{ErrorRate: 0.5},
+			random:         0.1,
+			requests:       1,
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "error rate not hit",
+			settings:       Settings{ErrorRate: 0.5},
+			random:         0.9,
+			requests:       1,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "rate limit exceeded",
+			settings:       Settings{RateLimit: 2},
+			requests:       3,
+			expectedStatus: http.StatusTooManyRequests,
+			expectedBody:   "No more than 2 requests per minute allowed\n",
+			retryAfter:     "60",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			injector := New(tt.settings)
+			injector.random = func() float64 { return tt.random }
+			injector.now = func() time.Time { return now }
+
+			handler := injector.Middleware(okHandler())
+
+			var w *httptest.ResponseRecorder
+			for i := 0; i < tt.requests; i++ {
+				w = httptest.NewRecorder()
+				handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/5354354162584", nil))
+			}
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedBody != "" {
+				require.Equal(t, tt.expectedBody, w.Body.String())
+			}
+			require.Equal(t, tt.retryAfter, w.Header().Get("Retry-After"))
+		})
+	}
+}
+
+func TestInjector_RateLimitWindowResets(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	injector := New(Settings{RateLimit: 1})
+	injector.now = func() time.Time { return now }
+
+	handler := injector.Middleware(okHandler())
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/5354354162584", nil))
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, serve().Code)
+
+	now = now.Add(45 * time.Second)
+	w := serve()
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "15", w.Header().Get("Retry-After"))
+
+	now = now.Add(15 * time.Second)
+	require.Equal(t, http.StatusOK, serve().Code)
+}
+
+func TestInjector_ShouldInvalidate(t *testing.T) {
+	injector := New(Settings{InvalidRate: 0.3})
+
+	injector.random = func() float64 { return 0.2 }
+	require.True(t, injector.ShouldInvalidate())
+
+	injector.random = func() float64 { return 0.3 }
+	require.False(t, injector.ShouldInvalidate())
+
+	injector.Update(Settings{ProcessingDelay: time.Second})
+	require.False(t, injector.ShouldInvalidate())
+	require.Equal(t, time.Second, injector.ProcessingDelay())
+}
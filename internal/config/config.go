@@ -4,6 +4,9 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -11,16 +14,82 @@ type Config struct {
 	DatabaseURI          string
 	AccrualSystemAddress string
 	JWTSecret            string
+	DatabaseMaxConns     int
+	DatabaseMinConns     int
+
+	TestMode        bool
+	ProcessingDelay time.Duration
+	InvalidRate     float64
+	ErrorRate       float64
+	RateLimit       int
+
+	ProgramKeys string
+
+	PollMaxAttempts int
+	PollMaxAge      time.Duration
+	PollBackoffBase time.Duration
+	PollBackoffMax  time.Duration
+	PollLease       time.Duration
+	AdminToken      string
+
+	BreakerFailureThreshold int
+	BreakerOpenTimeout      time.Duration
+
+	AccrualMaxAttempts  int
+	AccrualRetryBackoff time.Duration
+	PollMode            string
+	PollInterval        time.Duration
+	PollMinWorkers      int
+	PollMaxWorkers      int
+	AccrualRateLimit    int
+	HoldTTL             time.Duration
+	HoldSweepInterval   time.Duration
+
+	PointsValidityMonths int
+	PointsExpiringSoon   time.Duration
+	PointsExpiryInterval time.Duration
+
+	TransferDailyLimit float64
+	TransferDailyCount int
+
+	RecheckWindow   time.Duration
+	RecheckInterval time.Duration
+	NegativeBalance string
 }
 
 var globalConfig *Config
 
 func defaultConfig() *Config {
 	return &Config{
-		RunAddress:           DefaultRunAddress,
-		DatabaseURI:          DefaultDatabaseURI,
-		AccrualSystemAddress: DefaultAccrualSystemAddress,
-		JWTSecret:            DefaultJWTSecret,
+		RunAddress:              DefaultRunAddress,
+		DatabaseURI:             DefaultDatabaseURI,
+		AccrualSystemAddress:    DefaultAccrualSystemAddress,
+		JWTSecret:               DefaultJWTSecret,
+		DatabaseMaxConns:        DefaultDatabaseMaxConns,
+		DatabaseMinConns:        DefaultDatabaseMinConns,
+		PollMaxAttempts:         DefaultPollMaxAttempts,
+		PollMaxAge:              DefaultPollMaxAge,
+		PollBackoffBase:         DefaultPollBackoffBase,
+		PollBackoffMax:          DefaultPollBackoffMax,
+		PollLease:               DefaultPollLease,
+		BreakerFailureThreshold: DefaultBreakerFailureThreshold,
+		BreakerOpenTimeout:      DefaultBreakerOpenTimeout,
+		AccrualMaxAttempts:      DefaultAccrualMaxAttempts,
+		AccrualRetryBackoff:     DefaultAccrualRetryBackoff,
+		PollMode:                DefaultPollMode,
+		PollInterval:            DefaultPollInterval,
+		PollMinWorkers:          DefaultPollMinWorkers,
+		PollMaxWorkers:          DefaultPollMaxWorkers,
+		HoldTTL:                 DefaultHoldTTL,
+		HoldSweepInterval:       DefaultHoldSweepInterval,
+		PointsValidityMonths:    DefaultPointsValidityMonths,
+		PointsExpiringSoon:      DefaultPointsExpiringSoon,
+		PointsExpiryInterval:    DefaultPointsExpiryInterval,
+		TransferDailyLimit:      DefaultTransferDailyLimit,
+		TransferDailyCount:      DefaultTransferDailyCount,
+		RecheckWindow:           DefaultRecheckWindow,
+		RecheckInterval:         DefaultRecheckInterval,
+		NegativeBalance:         DefaultNegativeBalance,
 	}
 }
 
@@ -54,11 +123,116 @@ func (c *Config) Validate(flagsetName string) error {
 		if c.AccrualSystemAddress == "" {
 			return fmt.Errorf("accrual system address cannot be empty")
 		}
+		if c.PollMaxAttempts < 0 {
+			return fmt.Errorf("poll max attempts cannot be negative")
+		}
+		if c.PollMaxAge < 0 {
+			return fmt.Errorf("poll max age cannot be negative")
+		}
+		if c.PollBackoffBase < 0 || c.PollBackoffMax < 0 {
+			return fmt.Errorf("poll backoff cannot be negative")
+		}
+		if c.PollBackoffBase > 0 && c.PollBackoffMax > 0 && c.PollBackoffMax < c.PollBackoffBase {
+			return fmt.Errorf("poll backoff max cannot be less than poll backoff base")
+		}
+		if c.PollLease < 0 {
+			return fmt.Errorf("poll lease cannot be negative")
+		}
+		if c.BreakerFailureThreshold < 0 {
+			return fmt.Errorf("breaker failure threshold cannot be negative")
+		}
+		if c.BreakerOpenTimeout < 0 {
+			return fmt.Errorf("breaker open timeout cannot be negative")
+		}
+		if c.AccrualMaxAttempts < 0 {
+			return fmt.Errorf("accrual max attempts cannot be negative")
+		}
+		if c.AccrualRetryBackoff < 0 {
+			return fmt.Errorf("accrual retry backoff cannot be negative")
+		}
+		switch c.PollMode {
+		case "", PollModeSkipLocked, PollModeLeader:
+		default:
+			return fmt.Errorf("poll mode must be %s or %s", PollModeSkipLocked, PollModeLeader)
+		}
+		if c.PollInterval < 0 {
+			return fmt.Errorf("poll interval cannot be negative")
+		}
+		if c.PollMinWorkers < 0 || c.PollMaxWorkers < 0 {
+			return fmt.Errorf("poll workers cannot be negative")
+		}
+		if c.PollMinWorkers > 0 && c.PollMaxWorkers > 0 && c.PollMaxWorkers < c.PollMinWorkers {
+			return fmt.Errorf("poll max workers cannot be less than poll min workers")
+		}
+		if c.HoldTTL < 0 || c.HoldSweepInterval < 0 {
+			return fmt.Errorf("hold ttl and sweep interval cannot be negative")
+		}
+		if c.PointsValidityMonths < 0 || c.PointsExpiringSoon < 0 || c.PointsExpiryInterval < 0 {
+			return fmt.Errorf("points validity, expiring soon window and expiry interval cannot be negative")
+		}
+		if c.TransferDailyLimit < 0 || c.TransferDailyCount < 0 {
+			return fmt.Errorf("transfer daily limits cannot be negative")
+		}
+		if c.RecheckWindow < 0 || c.RecheckInterval < 0 {
+			return fmt.Errorf("recheck window and interval cannot be negative")
+		}
+		switch c.NegativeBalance {
+		case "", NegativeBalanceAllow, NegativeBalanceFloor, NegativeBalanceDebt:
+		default:
+			return fmt.Errorf("negative balance policy must be %s, %s or %s", NegativeBalanceAllow, NegativeBalanceFloor, NegativeBalanceDebt)
+		}
+		if c.AccrualRateLimit < 0 {
+			return fmt.Errorf("accrual rate limit cannot be negative")
+		}
+	}
+
+	if flagsetName == AccrualFlagsSet {
+		if c.DatabaseMaxConns < 0 {
+			return fmt.Errorf("database max connections cannot be negative")
+		}
+		if c.DatabaseMinConns < 0 || (c.DatabaseMaxConns > 0 && c.DatabaseMinConns > c.DatabaseMaxConns) {
+			return fmt.Errorf("database min connections must be between 0 and max connections")
+		}
+		if c.ProcessingDelay < 0 {
+			return fmt.Errorf("processing delay cannot be negative")
+		}
+		if c.InvalidRate < 0 || c.InvalidRate > 1 {
+			return fmt.Errorf("invalid rate must be between 0 and 1")
+		}
+		if c.ErrorRate < 0 || c.ErrorRate > 1 {
+			return fmt.Errorf("error rate must be between 0 and 1")
+		}
+		if c.RateLimit < 0 {
+			return fmt.Errorf("rate limit cannot be negative")
+		}
+		if _, err := ParseProgramKeys(c.ProgramKeys); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// ParseProgramKeys parses "key:program,key2:program2" into a map of API key to program
+func ParseProgramKeys(value string) (map[string]string, error) {
+	keys := make(map[string]string)
+	if strings.TrimSpace(value) == "" {
+		return keys, nil
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		key, program, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || key == "" || program == "" {
+			return nil, fmt.Errorf("invalid program key %q: expected key:program", pair)
+		}
+		if _, exists := keys[key]; exists {
+			return nil, fmt.Errorf("duplicate program key %q", key)
+		}
+		keys[key] = program
+	}
+	return keys, nil
+}
+
 func (c *Config) String() string {
 	return fmt.Sprintf("Config{RunAddress: %s, DatabaseURI: %s, AccrualSystemAddress: %s, JWTSecret: %s}",
 		c.RunAddress, c.DatabaseURI, c.AccrualSystemAddress, c.JWTSecret)
@@ -73,9 +247,201 @@ func (c *Config) loadFromEnvironment(flagsetName string) {
 		c.DatabaseURI = databaseURI
 	}
 
+	if adminToken, err := GetEnvironment(AdminTokenEnv); err == nil {
+		c.AdminToken = adminToken
+	}
+
 	if flagsetName == GophermartFlagsSet {
 		if accrualSystemAddress, err := GetEnvironment(AccrualSystemAddressEnv); err == nil {
 			c.AccrualSystemAddress = accrualSystemAddress
 		}
+
+		if maxAttempts, err := GetEnvironment(PollMaxAttemptsEnv); err == nil {
+			if value, err := strconv.Atoi(maxAttempts); err == nil {
+				c.PollMaxAttempts = value
+			}
+		}
+
+		if maxAge, err := GetEnvironment(PollMaxAgeEnv); err == nil {
+			if value, err := time.ParseDuration(maxAge); err == nil {
+				c.PollMaxAge = value
+			}
+		}
+
+		if backoffBase, err := GetEnvironment(PollBackoffBaseEnv); err == nil {
+			if value, err := time.ParseDuration(backoffBase); err == nil {
+				c.PollBackoffBase = value
+			}
+		}
+
+		if backoffMax, err := GetEnvironment(PollBackoffMaxEnv); err == nil {
+			if value, err := time.ParseDuration(backoffMax); err == nil {
+				c.PollBackoffMax = value
+			}
+		}
+
+		if lease, err := GetEnvironment(PollLeaseEnv); err == nil {
+			if value, err := time.ParseDuration(lease); err == nil {
+				c.PollLease = value
+			}
+		}
+
+		if threshold, err := GetEnvironment(BreakerFailureThresholdEnv); err == nil {
+			if value, err := strconv.Atoi(threshold); err == nil {
+				c.BreakerFailureThreshold = value
+			}
+		}
+
+		if openTimeout, err := GetEnvironment(BreakerOpenTimeoutEnv); err == nil {
+			if value, err := time.ParseDuration(openTimeout); err == nil {
+				c.BreakerOpenTimeout = value
+			}
+		}
+
+		if maxAttempts, err := GetEnvironment(AccrualMaxAttemptsEnv); err == nil {
+			if value, err := strconv.Atoi(maxAttempts); err == nil {
+				c.AccrualMaxAttempts = value
+			}
+		}
+
+		if backoff, err := GetEnvironment(AccrualRetryBackoffEnv); err == nil {
+			if value, err := time.ParseDuration(backoff); err == nil {
+				c.AccrualRetryBackoff = value
+			}
+		}
+
+		if pollMode, err := GetEnvironment(PollModeEnv); err == nil {
+			c.PollMode = pollMode
+		}
+
+		if interval, err := GetEnvironment(PollIntervalEnv); err == nil {
+			if value, err := time.ParseDuration(interval); err == nil {
+				c.PollInterval = value
+			}
+		}
+
+		if minWorkers, err := GetEnvironment(PollMinWorkersEnv); err == nil {
+			if value, err := strconv.Atoi(minWorkers); err == nil {
+				c.PollMinWorkers = value
+			}
+		}
+
+		if maxWorkers, err := GetEnvironment(PollMaxWorkersEnv); err == nil {
+			if value, err := strconv.Atoi(maxWorkers); err == nil {
+				c.PollMaxWorkers = value
+			}
+		}
+
+		if rateLimit, err := GetEnvironment(AccrualRateLimitEnv); err == nil {
+			if value, err := strconv.Atoi(rateLimit); err == nil {
+				c.AccrualRateLimit = value
+			}
+		}
+
+		if ttl, err := GetEnvironment(HoldTTLEnv); err == nil {
+			if value, err := time.ParseDuration(ttl); err == nil {
+				c.HoldTTL = value
+			}
+		}
+
+		if interval, err := GetEnvironment(HoldSweepIntervalEnv); err == nil {
+			if value, err := time.ParseDuration(interval); err == nil {
+				c.HoldSweepInterval = value
+			}
+		}
+
+		if months, err := GetEnvironment(PointsValidityMonthsEnv); err == nil {
+			if value, err := strconv.Atoi(months); err == nil {
+				c.PointsValidityMonths = value
+			}
+		}
+
+		if window, err := GetEnvironment(PointsExpiringSoonEnv); err == nil {
+			if value, err := time.ParseDuration(window); err == nil {
+				c.PointsExpiringSoon = value
+			}
+		}
+
+		if interval, err := GetEnvironment(PointsExpiryIntervalEnv); err == nil {
+			if value, err := time.ParseDuration(interval); err == nil {
+				c.PointsExpiryInterval = value
+			}
+		}
+
+		if limit, err := GetEnvironment(TransferDailyLimitEnv); err == nil {
+			if value, err := strconv.ParseFloat(limit, 64); err == nil {
+				c.TransferDailyLimit = value
+			}
+		}
+
+		if count, err := GetEnvironment(TransferDailyCountEnv); err == nil {
+			if value, err := strconv.Atoi(count); err == nil {
+				c.TransferDailyCount = value
+			}
+		}
+
+		if window, err := GetEnvironment(RecheckWindowEnv); err == nil {
+			if value, err := time.ParseDuration(window); err == nil {
+				c.RecheckWindow = value
+			}
+		}
+
+		if interval, err := GetEnvironment(RecheckIntervalEnv); err == nil {
+			if value, err := time.ParseDuration(interval); err == nil {
+				c.RecheckInterval = value
+			}
+		}
+
+		if policy, err := GetEnvironment(NegativeBalanceEnv); err == nil {
+			c.NegativeBalance = policy
+		}
+	}
+
+	if flagsetName == AccrualFlagsSet {
+		if maxConns, err := GetEnvironment(DatabaseMaxConnsEnv); err == nil {
+			if value, err := strconv.Atoi(maxConns); err == nil {
+				c.DatabaseMaxConns = value
+			}
+		}
+
+		if minConns, err := GetEnvironment(DatabaseMinConnsEnv); err == nil {
+			if value, err := strconv.Atoi(minConns); err == nil {
+				c.DatabaseMinConns = value
+			}
+		}
+
+		if testMode, err := GetEnvironment(TestModeEnv); err == nil {
+			if value, err := strconv.ParseBool(testMode); err == nil {
+				c.TestMode = value
+			}
+		}
+
+		if processingDelay, err := GetEnvironment(ProcessingDelayEnv); err == nil {
+			if value, err := time.ParseDuration(processingDelay); err == nil {
+				c.ProcessingDelay = value
+			}
+		}
+
+		if invalidRate, err := GetEnvironment(InvalidRateEnv); err == nil {
+			if value, err := strconv.ParseFloat(invalidRate, 64); err == nil {
+				c.InvalidRate = value
+			}
+		}
+
+		if errorRate, err := GetEnvironment(ErrorRateEnv); err == nil {
+			if value, err := strconv.ParseFloat(errorRate, 64); err == nil {
+				c.ErrorRate = value
+			}
+		}
+
+		if rateLimit, err := GetEnvironment(RateLimitEnv); err == nil {
+			if value, err := strconv.Atoi(rateLimit); err == nil {
+				c.RateLimit = value
+			}
+		}
+
+		if programKeys, err := GetEnvironment(ProgramKeysEnv); err == nil {
+			c.ProgramKeys = programKeys
+		}
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -147,6 +148,121 @@ func TestLoadFromEnvironment(t *testing.T) {
 	}
 }
 
+func TestParseFlags_TestMode(t *testing.T) {
+	args := []string{"-test-mode", "-processing-delay", "3s", "-invalid-rate", "0.2", "-error-rate", "0.1", "-rate-limit", "5", "-admin-token", "secret"}
+
+	config := ParseFlags(AccrualFlagsSet, args, flag.ContinueOnError)
+	assert.True(t, config.TestMode)
+	assert.Equal(t, 3*time.Second, config.ProcessingDelay)
+	assert.Equal(t, 0.2, config.InvalidRate)
+	assert.Equal(t, 0.1, config.ErrorRate)
+	assert.Equal(t, 5, config.RateLimit)
+	assert.Equal(t, "secret", config.AdminToken)
+}
+
+func TestParseFlags_DatabasePool(t *testing.T) {
+	config := ParseFlags(AccrualFlagsSet, []string{"-db-max-conns", "20", "-db-min-conns", "2"}, flag.ContinueOnError)
+	assert.Equal(t, 20, config.DatabaseMaxConns)
+	assert.Equal(t, 2, config.DatabaseMinConns)
+
+	config = ParseFlags(AccrualFlagsSet, []string{}, flag.ContinueOnError)
+	assert.Equal(t, DefaultDatabaseMaxConns, config.DatabaseMaxConns)
+	assert.Equal(t, DefaultDatabaseMinConns, config.DatabaseMinConns)
+}
+
+func TestParseFlags_Poller(t *testing.T) {
+	args := []string{"-poll-max-attempts", "7", "-poll-max-age", "2h", "-poll-backoff-base", "2s", "-poll-backoff-max", "1m", "-poll-lease", "30s", "-admin-token", "secret"}
+
+	config := ParseFlags(GophermartFlagsSet, args, flag.ContinueOnError)
+	assert.Equal(t, 7, config.PollMaxAttempts)
+	assert.Equal(t, 2*time.Hour, config.PollMaxAge)
+	assert.Equal(t, 2*time.Second, config.PollBackoffBase)
+	assert.Equal(t, time.Minute, config.PollBackoffMax)
+	assert.Equal(t, 30*time.Second, config.PollLease)
+	assert.Equal(t, "secret", config.AdminToken)
+
+	config = ParseFlags(GophermartFlagsSet, []string{}, flag.ContinueOnError)
+	assert.Equal(t, DefaultPollMaxAttempts, config.PollMaxAttempts)
+	assert.Equal(t, DefaultPollBackoffMax, config.PollBackoffMax)
+}
+
+func TestParseFlags_AccrualClient(t *testing.T) {
+	args := []string{"-breaker-failures", "3", "-breaker-open-timeout", "1m", "-accrual-max-attempts", "4", "-accrual-retry-backoff", "250ms"}
+
+	config := ParseFlags(GophermartFlagsSet, args, flag.ContinueOnError)
+	assert.Equal(t, 3, config.BreakerFailureThreshold)
+	assert.Equal(t, time.Minute, config.BreakerOpenTimeout)
+	assert.Equal(t, 4, config.AccrualMaxAttempts)
+	assert.Equal(t, 250*time.Millisecond, config.AccrualRetryBackoff)
+
+	config = ParseFlags(GophermartFlagsSet, []string{}, flag.ContinueOnError)
+	assert.Equal(t, DefaultBreakerFailureThreshold, config.BreakerFailureThreshold)
+	assert.Equal(t, DefaultAccrualMaxAttempts, config.AccrualMaxAttempts)
+}
+
+func TestParseFlags_PollerWorkers(t *testing.T) {
+	args := []string{"-poll-interval", "3s", "-poll-min-workers", "2", "-poll-max-workers", "16"}
+
+	config := ParseFlags(GophermartFlagsSet, args, flag.ContinueOnError)
+	assert.Equal(t, 3*time.Second, config.PollInterval)
+	assert.Equal(t, 2, config.PollMinWorkers)
+	assert.Equal(t, 16, config.PollMaxWorkers)
+
+	config = ParseFlags(GophermartFlagsSet, []string{}, flag.ContinueOnError)
+	assert.Equal(t, DefaultPollInterval, config.PollInterval)
+	assert.Equal(t, DefaultPollMinWorkers, config.PollMinWorkers)
+	assert.Equal(t, DefaultPollMaxWorkers, config.PollMaxWorkers)
+}
+
+func TestValidate_PollMode(t *testing.T) {
+	args := []string{"-d", "postgres://localhost/db", "-r", "http://localhost:8081", "-poll-mode", PollModeLeader}
+	config := ParseFlags(GophermartFlagsSet, args, flag.ContinueOnError)
+	assert.Equal(t, PollModeLeader, config.PollMode)
+	assert.NoError(t, config.Validate(GophermartFlagsSet))
+
+	config.PollMode = "round-robin"
+	assert.Error(t, config.Validate(GophermartFlagsSet))
+}
+
+func TestLoadFromEnvironment_TestMode(t *testing.T) {
+	envVars := map[string]string{
+		TestModeEnv:        "true",
+		ProcessingDelayEnv: "500ms",
+		InvalidRateEnv:     "0.3",
+		ErrorRateEnv:       "0.05",
+		RateLimitEnv:       "10",
+	}
+	for key, value := range envVars {
+		t.Setenv(key, value)
+	}
+
+	config := defaultConfig()
+	config.loadFromEnvironment(AccrualFlagsSet)
+
+	assert.True(t, config.TestMode)
+	assert.Equal(t, 500*time.Millisecond, config.ProcessingDelay)
+	assert.Equal(t, 0.3, config.InvalidRate)
+	assert.Equal(t, 0.05, config.ErrorRate)
+	assert.Equal(t, 10, config.RateLimit)
+
+	config = defaultConfig()
+	config.loadFromEnvironment(GophermartFlagsSet)
+	assert.False(t, config.TestMode)
+}
+
+func TestParseProgramKeys(t *testing.T) {
+	keys, err := ParseProgramKeys("key-a:brand-a, key-b:brand-b")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"key-a": "brand-a", "key-b": "brand-b"}, keys)
+
+	keys, err = ParseProgramKeys("")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	_, err = ParseProgramKeys("key-a:brand-a,key-a:brand-b")
+	assert.Error(t, err)
+}
+
 func TestValidate(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -205,6 +321,64 @@ func TestValidate(t *testing.T) {
 			wantErr:     true,
 			errMsg:      "accrual system address cannot be empty",
 		},
+		{
+			name: "invalid rate out of range",
+			config: &Config{
+				RunAddress:  ":8080",
+				DatabaseURI: "postgres://localhost/test",
+				InvalidRate: 1.5,
+			},
+			flagsetName: AccrualFlagsSet,
+			wantErr:     true,
+			errMsg:      "invalid rate must be between 0 and 1",
+		},
+		{
+			name: "min connections above max",
+			config: &Config{
+				RunAddress:       ":8080",
+				DatabaseURI:      "postgres://localhost/test",
+				DatabaseMaxConns: 2,
+				DatabaseMinConns: 5,
+			},
+			flagsetName: AccrualFlagsSet,
+			wantErr:     true,
+			errMsg:      "database min connections must be between 0 and max connections",
+		},
+		{
+			name: "negative rate limit",
+			config: &Config{
+				RunAddress:  ":8080",
+				DatabaseURI: "postgres://localhost/test",
+				RateLimit:   -1,
+			},
+			flagsetName: AccrualFlagsSet,
+			wantErr:     true,
+			errMsg:      "rate limit cannot be negative",
+		},
+		{
+			name: "poll backoff max below base",
+			config: &Config{
+				RunAddress:           ":8080",
+				DatabaseURI:          "postgres://localhost/test",
+				AccrualSystemAddress: "http://localhost:8081",
+				PollBackoffBase:      time.Minute,
+				PollBackoffMax:       time.Second,
+			},
+			flagsetName: GophermartFlagsSet,
+			wantErr:     true,
+			errMsg:      "poll backoff max cannot be less than poll backoff base",
+		},
+		{
+			name: "malformed program keys",
+			config: &Config{
+				RunAddress:  ":8080",
+				DatabaseURI: "postgres://localhost/test",
+				ProgramKeys: "key-a:brand-a,key-b",
+			},
+			flagsetName: AccrualFlagsSet,
+			wantErr:     true,
+			errMsg:      "invalid program key",
+		},
 		{
 			name: "empty accrual system address for accrual is ok",
 			config: &Config{
@@ -8,48 +8,217 @@ const (
 )
 
 const (
-	DefaultRunAddress           = ":8080"
-	DefaultDatabaseURI          = ""
-	DefaultAccrualSystemAddress = ""
-	DefaultJWTSecret            = "test-secret-key"
+	DefaultRunAddress              = ":8080"
+	DefaultDatabaseURI             = ""
+	DefaultAccrualSystemAddress    = ""
+	DefaultJWTSecret               = "test-secret-key"
+	DefaultDatabaseMaxConns        = 10
+	DefaultDatabaseMinConns        = 0
+	DefaultPollMaxAttempts         = 20
+	DefaultPollMaxAge              = 24 * time.Hour
+	DefaultPollBackoffBase         = time.Second
+	DefaultPollBackoffMax          = 10 * time.Minute
+	DefaultPollLease               = 2 * time.Minute
+	DefaultBreakerFailureThreshold = 5
+	DefaultBreakerOpenTimeout      = 30 * time.Second
+	DefaultAccrualMaxAttempts      = 3
+	DefaultAccrualRetryBackoff     = 100 * time.Millisecond
+	DefaultAccrualRetryBackoffMax  = 2 * time.Second
+	// DefaultPollInterval is enough as a safety net when uploads wake the poller up
+	DefaultPollInterval         = 10 * time.Second
+	DefaultPollMinWorkers       = 1
+	DefaultPollMaxWorkers       = 10
+	DefaultHoldTTL              = 15 * time.Minute
+	DefaultHoldSweepInterval    = time.Minute
+	DefaultPointsValidityMonths = 12
+	DefaultPointsExpiringSoon   = 30 * 24 * time.Hour
+	DefaultPointsExpiryInterval = time.Hour
+	DefaultTransferDailyLimit   = 10000.0
+	DefaultTransferDailyCount   = 10
+	DefaultRecheckWindow        = 7 * 24 * time.Hour
+	DefaultRecheckInterval      = 6 * time.Hour
 )
 
+// Negative balance policies for clawed back accruals, see model.NegativeBalancePolicy
 const (
-	PathUserRegister  = "/api/user/register"
-	PathUserLogin     = "/api/user/login"
-	PathUserOrders    = "/api/user/orders"
-	PathUserBalance   = "/api/user/balance"
-	PathUserWithdraw  = "/api/user/balance/withdraw"
-	PathWithdrawals   = "/api/user/withdrawals"
-	AccrualOrdersPath = "/api/orders"
+	NegativeBalanceAllow   = "allow"
+	NegativeBalanceFloor   = "floor"
+	NegativeBalanceDebt    = "debt"
+	DefaultNegativeBalance = NegativeBalanceDebt
 )
 
+// Poll modes: every replica claims orders with SKIP LOCKED, or only the elected leader does
 const (
-	HeaderAuthorization = "Authorization"
-	BearerPrefix        = "Bearer "
-	HeaderContentType   = "Content-Type"
-	ContentTypeJSON     = "application/json"
-	HeaderRetryAfter    = "Retry-After"
+	PollModeSkipLocked = "skip-locked"
+	PollModeLeader     = "leader"
+	DefaultPollMode    = PollModeSkipLocked
 )
 
 const (
-	RunAddressFlag           = "a"
-	DatabaseURIFlag          = "d"
-	AccrualSystemAddressFlag = "r"
+	PathUserRegister    = "/api/user/register"
+	PathUserLogin       = "/api/user/login"
+	PathUserOrders      = "/api/user/orders"
+	PathUserBalance     = "/api/user/balance"
+	PathUserWithdraw    = "/api/user/balance/withdraw"
+	PathWithdrawals     = "/api/user/withdrawals"
+	PathTransactions    = "/api/user/transactions"
+	PathUserHolds       = "/api/user/balance/holds"
+	PathUserHoldCapture = "/api/user/balance/holds/{id}/capture"
+	PathUserHoldRelease = "/api/user/balance/holds/{id}/release"
+	PathUserTransfer    = "/api/user/balance/transfer"
+	AccrualOrdersPath   = "/api/orders"
+
+	PathHealth                 = "/api/health"
+	PathAdminParkedOrders      = "/api/admin/orders/parked"
+	PathAdminRedriveOrder      = "/api/admin/orders/{number}/redrive"
+	PathAdminPollOrder         = "/api/admin/orders/{number}/poll"
+	PathAdminPoller            = "/api/admin/poller"
+	PathAdminReverseWithdrawal = "/api/admin/users/{userID}/withdrawals/{number}/reverse"
+)
+
+const (
+	HeaderAuthorization      = "Authorization"
+	BearerPrefix             = "Bearer "
+	HeaderContentType        = "Content-Type"
+	ContentTypeJSON          = "application/json"
+	HeaderRetryAfter         = "Retry-After"
+	HeaderAdminToken         = "X-Admin-Token"
+	HeaderLink               = "Link"
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+)
+
+const (
+	QueryParamType   = "type"
+	QueryParamStatus = "status"
+	QueryParamFrom   = "from"
+	QueryParamTo     = "to"
+	QueryParamLimit  = "limit"
+	QueryParamCursor = "cursor"
+
+	// MaxPageLimit caps the limit parameter of the user lists
+	MaxPageLimit = 1000
+	// MaxIdempotencyKeyLength matches the idempotency_keys.key column
+	MaxIdempotencyKeyLength = 255
+)
+
+const (
+	RunAddressFlag              = "a"
+	DatabaseURIFlag             = "d"
+	AccrualSystemAddressFlag    = "r"
+	DatabaseMaxConnsFlag        = "db-max-conns"
+	DatabaseMinConnsFlag        = "db-min-conns"
+	TestModeFlag                = "test-mode"
+	ProcessingDelayFlag         = "processing-delay"
+	InvalidRateFlag             = "invalid-rate"
+	ErrorRateFlag               = "error-rate"
+	RateLimitFlag               = "rate-limit"
+	ProgramKeysFlag             = "program-keys"
+	PollMaxAttemptsFlag         = "poll-max-attempts"
+	PollMaxAgeFlag              = "poll-max-age"
+	PollBackoffBaseFlag         = "poll-backoff-base"
+	PollBackoffMaxFlag          = "poll-backoff-max"
+	PollLeaseFlag               = "poll-lease"
+	AdminTokenFlag              = "admin-token"
+	BreakerFailureThresholdFlag = "breaker-failures"
+	BreakerOpenTimeoutFlag      = "breaker-open-timeout"
+	AccrualMaxAttemptsFlag      = "accrual-max-attempts"
+	AccrualRetryBackoffFlag     = "accrual-retry-backoff"
+	PollModeFlag                = "poll-mode"
+	PollIntervalFlag            = "poll-interval"
+	PollMinWorkersFlag          = "poll-min-workers"
+	PollMaxWorkersFlag          = "poll-max-workers"
+	AccrualRateLimitFlag        = "accrual-rate-limit"
+	HoldTTLFlag                 = "hold-ttl"
+	HoldSweepIntervalFlag       = "hold-sweep-interval"
+	PointsValidityMonthsFlag    = "points-validity-months"
+	PointsExpiringSoonFlag      = "points-expiring-soon"
+	PointsExpiryIntervalFlag    = "points-expiry-interval"
+	TransferDailyLimitFlag      = "transfer-daily-limit"
+	TransferDailyCountFlag      = "transfer-daily-count"
+	RecheckWindowFlag           = "recheck-window"
+	RecheckIntervalFlag         = "recheck-interval"
+	NegativeBalanceFlag         = "negative-balance"
 )
 
 const (
-	RunAddressEnv           = "RUN_ADDRESS"
-	DatabaseURIEnv          = "DATABASE_URI"
-	AccrualSystemAddressEnv = "ACCRUAL_SYSTEM_ADDRESS"
-	JWTSecretEnv            = "JWT_SECRET"
-	LogLevelEnv             = "LOG_LEVEL"
+	RunAddressEnv              = "RUN_ADDRESS"
+	DatabaseURIEnv             = "DATABASE_URI"
+	AccrualSystemAddressEnv    = "ACCRUAL_SYSTEM_ADDRESS"
+	JWTSecretEnv               = "JWT_SECRET"
+	LogLevelEnv                = "LOG_LEVEL"
+	DatabaseMaxConnsEnv        = "DATABASE_MAX_CONNS"
+	DatabaseMinConnsEnv        = "DATABASE_MIN_CONNS"
+	TestModeEnv                = "TEST_MODE"
+	ProcessingDelayEnv         = "PROCESSING_DELAY"
+	InvalidRateEnv             = "INVALID_RATE"
+	ErrorRateEnv               = "ERROR_RATE"
+	RateLimitEnv               = "RATE_LIMIT"
+	ProgramKeysEnv             = "PROGRAM_KEYS"
+	PollMaxAttemptsEnv         = "POLL_MAX_ATTEMPTS"
+	PollMaxAgeEnv              = "POLL_MAX_AGE"
+	PollBackoffBaseEnv         = "POLL_BACKOFF_BASE"
+	PollBackoffMaxEnv          = "POLL_BACKOFF_MAX"
+	PollLeaseEnv               = "POLL_LEASE"
+	AdminTokenEnv              = "ADMIN_TOKEN"
+	BreakerFailureThresholdEnv = "BREAKER_FAILURE_THRESHOLD"
+	BreakerOpenTimeoutEnv      = "BREAKER_OPEN_TIMEOUT"
+	AccrualMaxAttemptsEnv      = "ACCRUAL_MAX_ATTEMPTS"
+	AccrualRetryBackoffEnv     = "ACCRUAL_RETRY_BACKOFF"
+	PollModeEnv                = "POLL_MODE"
+	PollIntervalEnv            = "POLL_INTERVAL"
+	PollMinWorkersEnv          = "POLL_MIN_WORKERS"
+	PollMaxWorkersEnv          = "POLL_MAX_WORKERS"
+	AccrualRateLimitEnv        = "ACCRUAL_RATE_LIMIT"
+	HoldTTLEnv                 = "HOLD_TTL"
+	HoldSweepIntervalEnv       = "HOLD_SWEEP_INTERVAL"
+	PointsValidityMonthsEnv    = "POINTS_VALIDITY_MONTHS"
+	PointsExpiringSoonEnv      = "POINTS_EXPIRING_SOON"
+	PointsExpiryIntervalEnv    = "POINTS_EXPIRY_INTERVAL"
+	TransferDailyLimitEnv      = "TRANSFER_DAILY_LIMIT"
+	TransferDailyCountEnv      = "TRANSFER_DAILY_COUNT"
+	RecheckWindowEnv           = "RECHECK_WINDOW"
+	RecheckIntervalEnv         = "RECHECK_INTERVAL"
+	NegativeBalanceEnv         = "NEGATIVE_BALANCE_POLICY"
 )
 
 const (
-	RunAddressDescription           = "server address"
-	DatabaseURIDescription          = "database URI"
-	AccrualSystemAddressDescription = "accrual system address"
+	RunAddressDescription              = "server address"
+	DatabaseURIDescription             = "database URI"
+	AccrualSystemAddressDescription    = "accrual system address"
+	DatabaseMaxConnsDescription        = "maximum number of database pool connections"
+	DatabaseMinConnsDescription        = "minimum number of idle database pool connections"
+	TestModeDescription                = "enable test mode with fault injection"
+	ProcessingDelayDescription         = "delay before order accrual is calculated (test mode)"
+	InvalidRateDescription             = "share of orders randomly marked INVALID, 0..1 (test mode)"
+	ErrorRateDescription               = "share of order requests answered with 500, 0..1 (test mode)"
+	RateLimitDescription               = "max order requests per minute before 429, 0 = unlimited (test mode)"
+	ProgramKeysDescription             = "API keys of loyalty programs as key:program pairs separated by commas"
+	PollMaxAttemptsDescription         = "failed accrual polls of an order before it is parked, 0 = unlimited"
+	PollMaxAgeDescription              = "age of an unfinished order after which it is parked, 0 = unlimited"
+	PollBackoffBaseDescription         = "initial delay between failed accrual polls of an order"
+	PollBackoffMaxDescription          = "maximum delay between failed accrual polls of an order"
+	PollLeaseDescription               = "how long a claimed order is reserved for this instance's poller"
+	AdminTokenDescription              = "token for admin endpoints, empty = admin endpoints disabled"
+	BreakerFailureThresholdDescription = "consecutive accrual failures that open the circuit breaker"
+	BreakerOpenTimeoutDescription      = "how long the accrual circuit breaker stays open before a probe request"
+	AccrualMaxAttemptsDescription      = "attempts per accrual request on network errors and 502/503/504, 1 = no retries"
+	AccrualRetryBackoffDescription     = "initial delay between attempts of one accrual request"
+	PollModeDescription                = "accrual poller mode: skip-locked (all replicas poll) or leader (one replica elected via advisory lock)"
+	PollIntervalDescription            = "how often the accrual poller looks for due orders besides upload notifications"
+	PollMinWorkersDescription          = "minimum number of accrual poller workers"
+	PollMaxWorkersDescription          = "maximum number of accrual poller workers, scaled by backlog and 429s"
+	AccrualRateLimitDescription        = "requests per minute to the accrual system, 0 = learn the limit from its 429 answers"
+	HoldTTLDescription                 = "how long held points stay reserved before they are released automatically"
+	HoldSweepIntervalDescription       = "how often expired holds are released"
+	PointsValidityMonthsDescription    = "months earned points stay valid before the unused remainder expires"
+	PointsExpiringSoonDescription      = "window before expiry in which points are reported as expiring soon"
+	PointsExpiryIntervalDescription    = "how often expired points are debited"
+	TransferDailyLimitDescription      = "points a user may transfer to others within 24 hours, 0 = unlimited"
+	TransferDailyCountDescription      = "transfers a user may make within 24 hours, 0 = unlimited"
+	RecheckWindowDescription           = "how long after processing orders are re-polled for lowered or reversed accruals, 0 = never"
+	RecheckIntervalDescription         = "how often a processed order is re-polled within the recheck window"
+	NegativeBalanceDescription         = "what a claw back larger than the balance does: allow (negative balance), floor (write off the rest) or debt (recover it from later accruals)"
 )
 
 const (
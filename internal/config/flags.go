@@ -10,9 +10,45 @@ func ParseFlags(flagsetName string, args []string, flagErrorHandling flag.ErrorH
 
 	fs.StringVar(&config.RunAddress, RunAddressFlag, config.RunAddress, RunAddressDescription)
 	fs.StringVar(&config.DatabaseURI, DatabaseURIFlag, config.DatabaseURI, DatabaseURIDescription)
+	fs.StringVar(&config.AdminToken, AdminTokenFlag, config.AdminToken, AdminTokenDescription)
 
 	if flagsetName == GophermartFlagsSet {
 		fs.StringVar(&config.AccrualSystemAddress, AccrualSystemAddressFlag, config.AccrualSystemAddress, AccrualSystemAddressDescription)
+		fs.IntVar(&config.PollMaxAttempts, PollMaxAttemptsFlag, config.PollMaxAttempts, PollMaxAttemptsDescription)
+		fs.DurationVar(&config.PollMaxAge, PollMaxAgeFlag, config.PollMaxAge, PollMaxAgeDescription)
+		fs.DurationVar(&config.PollBackoffBase, PollBackoffBaseFlag, config.PollBackoffBase, PollBackoffBaseDescription)
+		fs.DurationVar(&config.PollBackoffMax, PollBackoffMaxFlag, config.PollBackoffMax, PollBackoffMaxDescription)
+		fs.DurationVar(&config.PollLease, PollLeaseFlag, config.PollLease, PollLeaseDescription)
+		fs.IntVar(&config.BreakerFailureThreshold, BreakerFailureThresholdFlag, config.BreakerFailureThreshold, BreakerFailureThresholdDescription)
+		fs.DurationVar(&config.BreakerOpenTimeout, BreakerOpenTimeoutFlag, config.BreakerOpenTimeout, BreakerOpenTimeoutDescription)
+		fs.IntVar(&config.AccrualMaxAttempts, AccrualMaxAttemptsFlag, config.AccrualMaxAttempts, AccrualMaxAttemptsDescription)
+		fs.DurationVar(&config.AccrualRetryBackoff, AccrualRetryBackoffFlag, config.AccrualRetryBackoff, AccrualRetryBackoffDescription)
+		fs.StringVar(&config.PollMode, PollModeFlag, config.PollMode, PollModeDescription)
+		fs.DurationVar(&config.PollInterval, PollIntervalFlag, config.PollInterval, PollIntervalDescription)
+		fs.IntVar(&config.PollMinWorkers, PollMinWorkersFlag, config.PollMinWorkers, PollMinWorkersDescription)
+		fs.IntVar(&config.PollMaxWorkers, PollMaxWorkersFlag, config.PollMaxWorkers, PollMaxWorkersDescription)
+		fs.IntVar(&config.AccrualRateLimit, AccrualRateLimitFlag, config.AccrualRateLimit, AccrualRateLimitDescription)
+		fs.DurationVar(&config.HoldTTL, HoldTTLFlag, config.HoldTTL, HoldTTLDescription)
+		fs.DurationVar(&config.HoldSweepInterval, HoldSweepIntervalFlag, config.HoldSweepInterval, HoldSweepIntervalDescription)
+		fs.IntVar(&config.PointsValidityMonths, PointsValidityMonthsFlag, config.PointsValidityMonths, PointsValidityMonthsDescription)
+		fs.DurationVar(&config.PointsExpiringSoon, PointsExpiringSoonFlag, config.PointsExpiringSoon, PointsExpiringSoonDescription)
+		fs.DurationVar(&config.PointsExpiryInterval, PointsExpiryIntervalFlag, config.PointsExpiryInterval, PointsExpiryIntervalDescription)
+		fs.Float64Var(&config.TransferDailyLimit, TransferDailyLimitFlag, config.TransferDailyLimit, TransferDailyLimitDescription)
+		fs.IntVar(&config.TransferDailyCount, TransferDailyCountFlag, config.TransferDailyCount, TransferDailyCountDescription)
+		fs.DurationVar(&config.RecheckWindow, RecheckWindowFlag, config.RecheckWindow, RecheckWindowDescription)
+		fs.DurationVar(&config.RecheckInterval, RecheckIntervalFlag, config.RecheckInterval, RecheckIntervalDescription)
+		fs.StringVar(&config.NegativeBalance, NegativeBalanceFlag, config.NegativeBalance, NegativeBalanceDescription)
+	}
+
+	if flagsetName == AccrualFlagsSet {
+		fs.IntVar(&config.DatabaseMaxConns, DatabaseMaxConnsFlag, config.DatabaseMaxConns, DatabaseMaxConnsDescription)
+		fs.IntVar(&config.DatabaseMinConns, DatabaseMinConnsFlag, config.DatabaseMinConns, DatabaseMinConnsDescription)
+		fs.BoolVar(&config.TestMode, TestModeFlag, config.TestMode, TestModeDescription)
+		fs.DurationVar(&config.ProcessingDelay, ProcessingDelayFlag, config.ProcessingDelay, ProcessingDelayDescription)
+		fs.Float64Var(&config.InvalidRate, InvalidRateFlag, config.InvalidRate, InvalidRateDescription)
+		fs.Float64Var(&config.ErrorRate, ErrorRateFlag, config.ErrorRate, ErrorRateDescription)
+		fs.IntVar(&config.RateLimit, RateLimitFlag, config.RateLimit, RateLimitDescription)
+		fs.StringVar(&config.ProgramKeys, ProgramKeysFlag, config.ProgramKeys, ProgramKeysDescription)
 	}
 	fs.Parse(args)
 	return config
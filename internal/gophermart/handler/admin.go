@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prbllm/go-loyalty-service/internal/config"
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/repository"
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/service/accrual"
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/service/balance"
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/service/order"
+	"github.com/prbllm/go-loyalty-service/internal/logger"
+)
+
+// Poller is the part of accrual.WorkerPool the admin endpoints inspect and drive
+type Poller interface {
+	Stats() accrual.PoolStats
+	PollNow(ctx context.Context, number string) (*model.Order, error)
+}
+
+type AdminHandler struct {
+	orders   order.Service
+	balances balance.Service
+	poller   Poller
+	logger   logger.Logger
+}
+
+type parkedOrderResponse struct {
+	Number        string    `json:"number"`
+	UserID        int64     `json:"user_id"`
+	Status        string    `json:"status"`
+	PollAttempts  int       `json:"poll_attempts"`
+	LastPollError string    `json:"last_poll_error,omitempty"`
+	UploadedAt    time.Time `json:"uploaded_at"`
+	ParkedAt      time.Time `json:"parked_at"`
+}
+
+type pollerStatusResponse struct {
+	Workers          int                         `json:"workers"`
+	QueueDepth       int                         `json:"queue_depth"`
+	InFlight         int                         `json:"in_flight"`
+	Leader           bool                        `json:"leader"`
+	RateLimitBackoff string                      `json:"rate_limit_backoff"`
+	LastPoll         *time.Time                  `json:"last_successful_poll"`
+	CircuitBreaker   string                      `json:"circuit_breaker,omitempty"`
+	Orders           map[model.OrderStatus]int64 `json:"orders"`
+}
+
+type polledOrderResponse struct {
+	Number        string    `json:"number"`
+	Status        string    `json:"status"`
+	Accrual       float64   `json:"accrual,omitempty"`
+	PollAttempts  int       `json:"poll_attempts"`
+	LastPollError string    `json:"last_poll_error,omitempty"`
+	NextPollAt    time.Time `json:"next_poll_at"`
+	Parked        bool      `json:"parked"`
+}
+
+type reverseWithdrawalRequest struct {
+	Reason string `json:"reason"`
+}
+
+type reversedWithdrawalResponse struct {
+	Order       string    `json:"order"`
+	Sum         float64   `json:"sum"`
+	ProcessedAt time.Time `json:"processed_at"`
+	ReversedAt  time.Time `json:"reversed_at"`
+	Reason      string    `json:"reason,omitempty"`
+}
+
+func NewAdminHandler(orders order.Service, balances balance.Service, poller Poller, logger logger.Logger) *AdminHandler {
+	return &AdminHandler{
+		orders:   orders,
+		balances: balances,
+		poller:   poller,
+		logger:   logger,
+	}
+}
+
+func (h *AdminHandler) ParkedOrders(w http.ResponseWriter, r *http.Request) {
+	orders, err := h.orders.ListParked(r.Context())
+	if err != nil {
+		h.logger.Errorf("admin: list parked orders: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]parkedOrderResponse, 0, len(orders))
+	for _, o := range orders {
+		item := parkedOrderResponse{
+			Number:        o.Number,
+			UserID:        o.UserID,
+			Status:        string(o.Status),
+			PollAttempts:  o.PollAttempts,
+			LastPollError: o.LastPollError,
+			UploadedAt:    o.UploadedAt,
+		}
+		if o.ParkedAt != nil {
+			item.ParkedAt = *o.ParkedAt
+		}
+		response = append(response, item)
+	}
+
+	w.Header().Set(config.HeaderContentType, config.ContentTypeJSON)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Errorf("admin: encode parked orders: %v", err)
+	}
+}
+
+func (h *AdminHandler) RedriveOrder(w http.ResponseWriter, r *http.Request) {
+	number := chi.URLParam(r, "number")
+
+	if err := h.orders.Redrive(r.Context(), number); err != nil {
+		if errors.Is(err, repository.ErrOrderNotParked) {
+			writeJSONError(w, http.StatusNotFound, "order is not parked")
+			return
+		}
+		h.logger.Errorf("admin: redrive order %s: %v", number, err)
+		writeJSONError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *AdminHandler) PollerStatus(w http.ResponseWriter, r *http.Request) {
+	counts, err := h.orders.CountByStatus(r.Context())
+	if err != nil {
+		h.logger.Errorf("admin: count orders by status: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	stats := h.poller.Stats()
+	response := pollerStatusResponse{
+		Workers:          stats.Workers,
+		QueueDepth:       stats.QueueDepth,
+		InFlight:         stats.InFlight,
+		Leader:           stats.Leader,
+		RateLimitBackoff: stats.RateLimitBackoff.String(),
+		CircuitBreaker:   string(stats.Breaker),
+		Orders:           counts,
+	}
+	if !stats.LastPoll.IsZero() {
+		response.LastPoll = &stats.LastPoll
+	}
+
+	w.Header().Set(config.HeaderContentType, config.ContentTypeJSON)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Errorf("admin: encode poller status: %v", err)
+	}
+}
+
+func (h *AdminHandler) PollOrder(w http.ResponseWriter, r *http.Request) {
+	number := chi.URLParam(r, "number")
+
+	o, err := h.poller.PollNow(r.Context(), number)
+	if err != nil {
+		var tmr *accrual.TooManyRequestsError
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			writeJSONError(w, http.StatusNotFound, "order not found")
+		case errors.Is(err, accrual.ErrOrderFinal), errors.Is(err, accrual.ErrOrderInFlight):
+			writeJSONError(w, http.StatusConflict, err.Error())
+		case errors.Is(err, accrual.ErrCircuitOpen), errors.As(err, &tmr):
+			writeJSONError(w, http.StatusServiceUnavailable, err.Error())
+		default:
+			h.logger.Errorf("admin: poll order %s: %v", number, err)
+			writeJSONError(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+
+	h.logger.Infof("order %s polled by operator, status %s", number, o.Status)
+
+	w.Header().Set(config.HeaderContentType, config.ContentTypeJSON)
+	if err := json.NewEncoder(w).Encode(polledOrderResponse{
+		Number:        o.Number,
+		Status:        string(o.Status),
+		Accrual:       o.Accrual.ToFloat64(),
+		PollAttempts:  o.PollAttempts,
+		LastPollError: o.LastPollError,
+		NextPollAt:    o.NextPollAt,
+		Parked:        o.ParkedAt != nil,
+	}); err != nil {
+		h.logger.Errorf("admin: encode polled order: %v", err)
+	}
+}
+
+// ReverseWithdrawal credits a withdrawal back to the user, e.g. when the purchase was cancelled.
+// The body with a reason is optional.
+func (h *AdminHandler) ReverseWithdrawal(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	number := chi.URLParam(r, "number")
+
+	var req reverseWithdrawalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSONError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	wd, err := h.balances.Reverse(r.Context(), userID, number, req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			writeJSONError(w, http.StatusNotFound, "withdrawal not found")
+		case errors.Is(err, repository.ErrWithdrawalReversed):
+			writeJSONError(w, http.StatusConflict, err.Error())
+		default:
+			h.logger.Errorf("admin: reverse withdrawal %s of user %d: %v", number, userID, err)
+			writeJSONError(w, http.StatusInternalServerError, "internal error")
+		}
+		return
+	}
+
+	response := reversedWithdrawalResponse{
+		Order:       wd.OrderNumber,
+		Sum:         wd.Sum.ToFloat64(),
+		ProcessedAt: wd.ProcessedAt,
+		Reason:      wd.ReversalReason,
+	}
+	if wd.ReversedAt != nil {
+		response.ReversedAt = *wd.ReversedAt
+	}
+
+	w.Header().Set(config.HeaderContentType, config.ContentTypeJSON)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Errorf("admin: encode reversed withdrawal: %v", err)
+	}
+}
@@ -0,0 +1,298 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prbllm/go-loyalty-service/internal/config"
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/middleware"
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/repository"
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/service/accrual"
+	ordermocks "github.com/prbllm/go-loyalty-service/internal/mocks/gophermart"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestAdminParkedOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := ordermocks.NewMockOrderService(ctrl)
+	log := zaptest.NewLogger(t).Sugar()
+	handler := NewAdminHandler(mockService, nil, nil, log)
+
+	parkedAt := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	mockService.EXPECT().ListParked(gomock.Any()).Return([]*model.Order{{
+		Number:        "79927398713",
+		UserID:        1,
+		Status:        model.OrderStatusNew,
+		PollAttempts:  20,
+		LastPollError: "order not registered in accrual system",
+		ParkedAt:      &parkedAt,
+	}}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, config.PathAdminParkedOrders, nil)
+	req.Header.Set(config.HeaderAdminToken, "secret")
+	rr := httptest.NewRecorder()
+
+	middleware.AdminToken("secret")(http.HandlerFunc(handler.ParkedOrders)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var resp []parkedOrderResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp) != 1 || resp[0].Number != "79927398713" || resp[0].PollAttempts != 20 || !resp[0].ParkedAt.Equal(parkedAt) {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestAdminTokenRequired(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		provided   string
+		wantStatus int
+	}{
+		{"admin disabled", "", "", http.StatusNotFound},
+		{"missing token", "secret", "", http.StatusForbidden},
+		{"wrong token", "secret", "guess", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := ordermocks.NewMockOrderService(ctrl)
+			log := zaptest.NewLogger(t).Sugar()
+			handler := NewAdminHandler(mockService, nil, nil, log)
+
+			req := httptest.NewRequest(http.MethodGet, config.PathAdminParkedOrders, nil)
+			if tt.provided != "" {
+				req.Header.Set(config.HeaderAdminToken, tt.provided)
+			}
+			rr := httptest.NewRecorder()
+
+			middleware.AdminToken(tt.configured)(http.HandlerFunc(handler.ParkedOrders)).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestAdminRedriveOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		serviceErr error
+		wantStatus int
+	}{
+		{"redriven", nil, http.StatusAccepted},
+		{"not parked", repository.ErrOrderNotParked, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := ordermocks.NewMockOrderService(ctrl)
+			log := zaptest.NewLogger(t).Sugar()
+			handler := NewAdminHandler(mockService, nil, nil, log)
+
+			mockService.EXPECT().Redrive(gomock.Any(), "79927398713").Return(tt.serviceErr)
+
+			path := strings.Replace(config.PathAdminRedriveOrder, "{number}", "79927398713", 1)
+			req := httptest.NewRequest(http.MethodPost, path, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("number", "79927398713")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+
+			handler.RedriveOrder(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestAdminReverseWithdrawal(t *testing.T) {
+	reversedAt := time.Now()
+	tests := []struct {
+		name       string
+		userID     string
+		body       string
+		serviceErr error
+		wantStatus int
+	}{
+		{"reversed", "7", `{"reason":"purchase cancelled"}`, nil, http.StatusOK},
+		{"without reason", "7", "", nil, http.StatusOK},
+		{"not found", "7", "", sql.ErrNoRows, http.StatusNotFound},
+		{"already reversed", "7", "", repository.ErrWithdrawalReversed, http.StatusConflict},
+		{"invalid user", "abc", "", nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := ordermocks.NewMockBalanceService(ctrl)
+			handler := NewAdminHandler(nil, mockService, nil, zaptest.NewLogger(t).Sugar())
+
+			if tt.wantStatus != http.StatusBadRequest {
+				var reason string
+				if tt.body != "" {
+					reason = "purchase cancelled"
+				}
+				var withdrawal *model.Withdrawal
+				if tt.serviceErr == nil {
+					withdrawal = &model.Withdrawal{OrderNumber: "79927398713", Sum: model.Amount(500), ReversedAt: &reversedAt, ReversalReason: reason}
+				}
+				mockService.EXPECT().Reverse(gomock.Any(), int64(7), "79927398713", reason).Return(withdrawal, tt.serviceErr)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, config.PathAdminReverseWithdrawal, strings.NewReader(tt.body))
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("userID", tt.userID)
+			rctx.URLParams.Add("number", "79927398713")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+
+			handler.ReverseWithdrawal(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if tt.wantStatus == http.StatusOK {
+				var resp reversedWithdrawalResponse
+				if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if resp.Sum != 5 || resp.ReversedAt.IsZero() {
+					t.Fatalf("unexpected response: %+v", resp)
+				}
+			}
+		})
+	}
+}
+
+type stubPoller struct {
+	stats   accrual.PoolStats
+	order   *model.Order
+	pollErr error
+}
+
+func (p *stubPoller) Stats() accrual.PoolStats {
+	return p.stats
+}
+
+func (p *stubPoller) PollNow(ctx context.Context, number string) (*model.Order, error) {
+	return p.order, p.pollErr
+}
+
+func TestAdminPollerStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	lastPoll := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	poller := &stubPoller{stats: accrual.PoolStats{
+		Workers:          5,
+		QueueDepth:       3,
+		InFlight:         4,
+		Leader:           true,
+		RateLimitBackoff: 30 * time.Second,
+		LastPoll:         lastPoll,
+		Breaker:          accrual.BreakerClosed,
+	}}
+
+	mockService := ordermocks.NewMockOrderService(ctrl)
+	mockService.EXPECT().CountByStatus(gomock.Any()).Return(map[model.OrderStatus]int64{
+		model.OrderStatusNew:       2,
+		model.OrderStatusProcessed: 7,
+	}, nil)
+	handler := NewAdminHandler(mockService, nil, poller, zaptest.NewLogger(t).Sugar())
+
+	req := httptest.NewRequest(http.MethodGet, config.PathAdminPoller, nil)
+	rr := httptest.NewRecorder()
+	handler.PollerStatus(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var resp pollerStatusResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Workers != 5 || resp.QueueDepth != 3 || resp.InFlight != 4 || !resp.Leader {
+		t.Fatalf("unexpected pool stats: %+v", resp)
+	}
+	if resp.RateLimitBackoff != "30s" || resp.LastPoll == nil || !resp.LastPoll.Equal(lastPoll) || resp.CircuitBreaker != "closed" {
+		t.Fatalf("unexpected accrual state: %+v", resp)
+	}
+	if resp.Orders[model.OrderStatusNew] != 2 || resp.Orders[model.OrderStatusProcessed] != 7 {
+		t.Fatalf("unexpected order counts: %+v", resp.Orders)
+	}
+}
+
+func TestAdminPollOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		order      *model.Order
+		pollErr    error
+		wantStatus int
+	}{
+		{"polled", &model.Order{Number: "79927398713", Status: model.OrderStatusProcessed, Accrual: model.FromFloat64(10)}, nil, http.StatusOK},
+		{"unknown order", nil, sql.ErrNoRows, http.StatusNotFound},
+		{"final status", nil, accrual.ErrOrderFinal, http.StatusConflict},
+		{"already polling", nil, accrual.ErrOrderInFlight, http.StatusConflict},
+		{"breaker open", nil, accrual.ErrCircuitOpen, http.StatusServiceUnavailable},
+		{"rate limited", nil, &accrual.TooManyRequestsError{RetryAfter: time.Minute}, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAdminHandler(nil, nil, &stubPoller{order: tt.order, pollErr: tt.pollErr}, zaptest.NewLogger(t).Sugar())
+
+			path := strings.Replace(config.PathAdminPollOrder, "{number}", "79927398713", 1)
+			req := httptest.NewRequest(http.MethodPost, path, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("number", "79927398713")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+
+			handler.PollOrder(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if tt.order == nil {
+				return
+			}
+
+			var resp polledOrderResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Status != string(model.OrderStatusProcessed) || resp.Accrual != 10 {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
@@ -1,12 +1,21 @@
 package handler
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/prbllm/go-loyalty-service/internal/config"
 	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/repository"
 	"github.com/prbllm/go-loyalty-service/internal/gophermart/service/balance"
 	"github.com/prbllm/go-loyalty-service/internal/logger"
 	"github.com/prbllm/go-loyalty-service/pkg/luhn"
@@ -25,14 +34,48 @@ func NewBalanceHandler(service balance.Service, logger logger.Logger) *BalanceHa
 }
 
 type balanceResponse struct {
-	Current   float64 `json:"current"`
-	Withdrawn float64 `json:"withdrawn"`
+	Current      float64 `json:"current"`
+	Withdrawn    float64 `json:"withdrawn"`
+	Available    float64 `json:"available"`
+	Held         float64 `json:"held"`
+	ExpiringSoon float64 `json:"expiring_soon"`
+	Debt         float64 `json:"debt"`
+
+	Pending        float64          `json:"pending"`
+	PendingUnknown int64            `json:"pending_unknown"`
+	Orders         map[string]int64 `json:"orders"`
+}
+
+type holdRequest struct {
+	Order string  `json:"order"`
+	Sum   float64 `json:"sum"`
+}
+
+type holdResponse struct {
+	ID         int64   `json:"id"`
+	Order      string  `json:"order"`
+	Sum        float64 `json:"sum"`
+	Status     string  `json:"status"`
+	ExpiresAt  string  `json:"expires_at"`
+	CreatedAt  string  `json:"created_at"`
+	ResolvedAt string  `json:"resolved_at,omitempty"`
 }
 
 type withdrawalResponse struct {
 	Order       string  `json:"order"`
 	Sum         float64 `json:"sum"`
 	ProcessedAt string  `json:"processed_at"`
+	ReversedAt  string  `json:"reversed_at,omitempty"`
+}
+
+type transactionResponse struct {
+	ID           int64   `json:"id"`
+	Type         string  `json:"type"`
+	Amount       float64 `json:"amount"`
+	Balance      float64 `json:"balance"`
+	Reference    string  `json:"reference,omitempty"`
+	Counterparty string  `json:"counterparty,omitempty"`
+	ProcessedAt  string  `json:"processed_at"`
 }
 
 type withdrawRequest struct {
@@ -40,6 +83,18 @@ type withdrawRequest struct {
 	Sum   float64 `json:"sum"`
 }
 
+type transferRequest struct {
+	Recipient string  `json:"recipient"`
+	Sum       float64 `json:"sum"`
+}
+
+type transferResponse struct {
+	ID          int64   `json:"id"`
+	Recipient   string  `json:"recipient"`
+	Sum         float64 `json:"sum"`
+	ProcessedAt string  `json:"processed_at"`
+}
+
 func (h *BalanceHandler) Balance(w http.ResponseWriter, r *http.Request) {
 	userID, ok := getUserID(w, r)
 	if !ok {
@@ -53,9 +108,24 @@ func (h *BalanceHandler) Balance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// current stays the spendable balance, as before holds existed
 	resp := balanceResponse{
 		Current:   bal.Current.ToFloat64(),
 		Withdrawn: bal.Withdrawn.ToFloat64(),
+		Available: bal.Current.ToFloat64(),
+		Held:      bal.Held.ToFloat64(),
+
+		ExpiringSoon: bal.ExpiringSoon.ToFloat64(),
+		Debt:         bal.Debt.ToFloat64(),
+
+		Pending:        bal.Pending.ToFloat64(),
+		PendingUnknown: bal.PendingUnknown,
+		Orders: map[string]int64{
+			string(model.OrderStatusNew):        bal.Orders[model.OrderStatusNew],
+			string(model.OrderStatusProcessing): bal.Orders[model.OrderStatusProcessing],
+			string(model.OrderStatusInvalid):    bal.Orders[model.OrderStatusInvalid],
+			string(model.OrderStatusProcessed):  bal.Orders[model.OrderStatusProcessed],
+		},
 	}
 
 	w.Header().Set(config.HeaderContentType, config.ContentTypeJSON)
@@ -69,12 +139,29 @@ func (h *BalanceHandler) Withdrawals(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	items, err := h.service.GetWithdrawals(r.Context(), userID)
+	filter := model.WithdrawalFilter{}
+	var err error
+	if filter.From, filter.To, err = parseDateRange(r.URL.Query()); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if filter.Page, err = parsePage(r.URL.Query()); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit := filter.Limit
+	filter.Page = lookahead(filter.Page)
+
+	items, err := h.service.GetWithdrawals(r.Context(), userID, filter)
 	if err != nil {
 		h.logger.Errorf("withdrawals: get list: %v", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	items = paginate(w, r, items, limit, func(it *model.Withdrawal) model.Cursor {
+		return model.Cursor{Time: it.ProcessedAt, ID: it.ID}
+	})
 
 	if len(items) == 0 {
 		w.WriteHeader(http.StatusNoContent)
@@ -83,11 +170,15 @@ func (h *BalanceHandler) Withdrawals(w http.ResponseWriter, r *http.Request) {
 
 	resp := make([]withdrawalResponse, 0, len(items))
 	for _, it := range items {
-		resp = append(resp, withdrawalResponse{
+		item := withdrawalResponse{
 			Order:       it.OrderNumber,
 			Sum:         it.Sum.ToFloat64(),
 			ProcessedAt: it.ProcessedAt.Format(time.RFC3339),
-		})
+		}
+		if it.ReversedAt != nil {
+			item.ReversedAt = it.ReversedAt.Format(time.RFC3339)
+		}
+		resp = append(resp, item)
 	}
 
 	w.Header().Set(config.HeaderContentType, config.ContentTypeJSON)
@@ -130,3 +221,216 @@ func (h *BalanceHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 }
+
+func (h *BalanceHandler) Transfer(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req transferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	req.Recipient = strings.TrimSpace(req.Recipient)
+	if req.Recipient == "" {
+		writeJSONError(w, http.StatusBadRequest, "recipient is required")
+		return
+	}
+
+	if req.Sum <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "invalid sum")
+		return
+	}
+
+	t, err := h.service.Transfer(r.Context(), userID, req.Recipient, model.FromFloat64(req.Sum))
+	if err != nil {
+		h.writeTransferError(w, userID, err)
+		return
+	}
+
+	w.Header().Set(config.HeaderContentType, config.ContentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(transferResponse{
+		ID:          t.ID,
+		Recipient:   t.RecipientLogin,
+		Sum:         t.Amount.ToFloat64(),
+		ProcessedAt: t.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+func (h *BalanceHandler) writeTransferError(w http.ResponseWriter, userID int64, err error) {
+	switch {
+	case errors.Is(err, repository.ErrRecipientNotFound):
+		writeJSONError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, repository.ErrTransferToSelf), errors.Is(err, repository.ErrTransferLimitExceeded):
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, repository.ErrInsufficientFunds):
+		writeJSONError(w, http.StatusPaymentRequired, "insufficient funds")
+	default:
+		h.logger.Errorf("transfer: user %d: %v", userID, err)
+		writeJSONError(w, http.StatusInternalServerError, "internal error")
+	}
+}
+
+func (h *BalanceHandler) Hold(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req holdRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if !luhn.IsValidOrderNumber(req.Order) {
+		writeJSONError(w, http.StatusUnprocessableEntity, "invalid order number")
+		return
+	}
+
+	if req.Sum <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "invalid sum")
+		return
+	}
+
+	hold, err := h.service.Hold(r.Context(), userID, req.Order, model.FromFloat64(req.Sum))
+	if err != nil {
+		h.writeHoldError(w, "hold", userID, err)
+		return
+	}
+
+	writeHold(w, http.StatusCreated, hold)
+}
+
+func (h *BalanceHandler) CaptureHold(w http.ResponseWriter, r *http.Request) {
+	h.resolveHold(w, r, "capture", h.service.Capture)
+}
+
+func (h *BalanceHandler) ReleaseHold(w http.ResponseWriter, r *http.Request) {
+	h.resolveHold(w, r, "release", h.service.Release)
+}
+
+func (h *BalanceHandler) resolveHold(w http.ResponseWriter, r *http.Request, op string,
+	resolve func(ctx context.Context, userID, holdID int64) (*model.Hold, error)) {
+	userID, ok := getUserID(w, r)
+	if !ok {
+		return
+	}
+
+	holdID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid hold id")
+		return
+	}
+
+	hold, err := resolve(r.Context(), userID, holdID)
+	if err != nil {
+		h.writeHoldError(w, op, userID, err)
+		return
+	}
+
+	writeHold(w, http.StatusOK, hold)
+}
+
+func (h *BalanceHandler) writeHoldError(w http.ResponseWriter, op string, userID int64, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		writeJSONError(w, http.StatusNotFound, "hold not found")
+	case errors.Is(err, repository.ErrHoldConflict), errors.Is(err, repository.ErrHoldNotActive):
+		writeJSONError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, repository.ErrInsufficientFunds):
+		writeJSONError(w, http.StatusPaymentRequired, "insufficient funds")
+	default:
+		h.logger.Errorf("%s: user %d: %v", op, userID, err)
+		writeJSONError(w, http.StatusInternalServerError, "internal error")
+	}
+}
+
+func writeHold(w http.ResponseWriter, statusCode int, hold *model.Hold) {
+	resp := holdResponse{
+		ID:        hold.ID,
+		Order:     hold.OrderNumber,
+		Sum:       hold.Amount.ToFloat64(),
+		Status:    string(hold.Status),
+		ExpiresAt: hold.ExpiresAt.Format(time.RFC3339),
+		CreatedAt: hold.CreatedAt.Format(time.RFC3339),
+	}
+	if hold.ResolvedAt != nil {
+		resp.ResolvedAt = hold.ResolvedAt.Format(time.RFC3339)
+	}
+
+	w.Header().Set(config.HeaderContentType, config.ContentTypeJSON)
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (h *BalanceHandler) Transactions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserID(w, r)
+	if !ok {
+		return
+	}
+
+	filter, err := parseTransactionFilter(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	items, err := h.service.GetTransactions(r.Context(), userID, filter)
+	if err != nil {
+		h.logger.Errorf("transactions: get list: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	if len(items) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resp := make([]transactionResponse, 0, len(items))
+	for _, it := range items {
+		resp = append(resp, transactionResponse{
+			ID:           it.ID,
+			Type:         string(it.Type),
+			Amount:       it.Amount.ToFloat64(),
+			Balance:      it.Balance.ToFloat64(),
+			Reference:    it.OrderNumber,
+			Counterparty: it.Counterparty,
+			ProcessedAt:  it.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	w.Header().Set(config.HeaderContentType, config.ContentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// parseTransactionFilter reads type (repeated or comma separated), from and to
+func parseTransactionFilter(query url.Values) (model.TransactionFilter, error) {
+	var filter model.TransactionFilter
+
+	for _, value := range query[config.QueryParamType] {
+		for _, name := range strings.Split(value, ",") {
+			t := model.LedgerEntryType(strings.ToUpper(strings.TrimSpace(name)))
+			switch t {
+			case model.LedgerEntryAccrual, model.LedgerEntryWithdrawal, model.LedgerEntryAdjustment,
+				model.LedgerEntryReversal, model.LedgerEntryExpiration, model.LedgerEntryTransfer:
+				filter.Types = append(filter.Types, t)
+			default:
+				return filter, fmt.Errorf("invalid transaction type %q", name)
+			}
+		}
+	}
+
+	var err error
+	if filter.From, filter.To, err = parseDateRange(query); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
@@ -2,11 +2,15 @@ package handler
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/prbllm/go-loyalty-service/internal/config"
 	"github.com/prbllm/go-loyalty-service/internal/gophermart/middleware"
 	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
@@ -52,7 +56,7 @@ func TestBalanceHandler_Withdrawals(t *testing.T) {
 	h := NewBalanceHandler(mockService, log)
 
 	now := time.Now()
-	mockService.EXPECT().GetWithdrawals(gomock.Any(), int64(1)).Return([]*model.Withdrawal{
+	mockService.EXPECT().GetWithdrawals(gomock.Any(), int64(1), model.WithdrawalFilter{}).Return([]*model.Withdrawal{
 		{OrderNumber: "1", Sum: model.Amount(500), ProcessedAt: now},
 	}, nil)
 
@@ -111,6 +115,50 @@ func TestBalanceHandler_Withdraw_Insufficient(t *testing.T) {
 	}
 }
 
+func TestBalanceHandler_Withdraw_ConflictingRepeat(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := balancemocks.NewMockBalanceService(ctrl)
+	log := zaptest.NewLogger(t).Sugar()
+	h := NewBalanceHandler(mockService, log)
+
+	mockService.EXPECT().Withdraw(gomock.Any(), int64(1), "79927398713", model.Amount(600)).Return(repository.ErrWithdrawalConflict)
+
+	body := bytes.NewBufferString(`{"order":"79927398713","sum":6}`)
+	req := httptest.NewRequest(http.MethodPost, "/withdraw", body)
+	addAuthHeader(req, 1)
+	rr := httptest.NewRecorder()
+
+	middleware.Auth(http.HandlerFunc(h.Withdraw)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rr.Code)
+	}
+}
+
+func TestBalanceHandler_Withdraw_ReversedRepeat(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := balancemocks.NewMockBalanceService(ctrl)
+	log := zaptest.NewLogger(t).Sugar()
+	h := NewBalanceHandler(mockService, log)
+
+	mockService.EXPECT().Withdraw(gomock.Any(), int64(1), "79927398713", model.Amount(600)).Return(repository.ErrWithdrawalReversed)
+
+	body := bytes.NewBufferString(`{"order":"79927398713","sum":6}`)
+	req := httptest.NewRequest(http.MethodPost, "/withdraw", body)
+	addAuthHeader(req, 1)
+	rr := httptest.NewRecorder()
+
+	middleware.Auth(http.HandlerFunc(h.Withdraw)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rr.Code)
+	}
+}
+
 func TestBalanceHandler_Withdraw_InvalidOrder(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -130,3 +178,259 @@ func TestBalanceHandler_Withdraw_InvalidOrder(t *testing.T) {
 		t.Fatalf("expected 422, got %d", rr.Code)
 	}
 }
+
+func TestBalanceHandler_Transactions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := balancemocks.NewMockBalanceService(ctrl)
+	log := zaptest.NewLogger(t).Sugar()
+	h := NewBalanceHandler(mockService, log)
+
+	want := model.TransactionFilter{
+		Types: []model.LedgerEntryType{model.LedgerEntryAccrual, model.LedgerEntryWithdrawal},
+		From:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:    time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+	mockService.EXPECT().GetTransactions(gomock.Any(), int64(1), want).Return([]*model.Transaction{
+		{ID: 2, Type: model.LedgerEntryWithdrawal, Amount: model.Amount(-500), Balance: model.Amount(500), OrderNumber: "2", CreatedAt: time.Now()},
+		{ID: 1, Type: model.LedgerEntryAccrual, Amount: model.Amount(1000), Balance: model.Amount(1000), OrderNumber: "1", CreatedAt: time.Now()},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/transactions?type=accrual,WITHDRAWAL&from=2024-01-01&to=2024-01-31", nil)
+	addAuthHeader(req, 1)
+	rr := httptest.NewRecorder()
+
+	middleware.Auth(http.HandlerFunc(h.Transactions)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	var resp []transactionResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp) != 2 || resp[0].Amount != -5 || resp[0].Balance != 5 || resp[0].Reference != "2" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestBalanceHandler_Transactions_InvalidFilter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := balancemocks.NewMockBalanceService(ctrl)
+	log := zaptest.NewLogger(t).Sugar()
+	h := NewBalanceHandler(mockService, log)
+
+	for _, query := range []string{"type=bonus", "from=yesterday", "from=2024-02-01&to=2024-01-01"} {
+		req := httptest.NewRequest(http.MethodGet, "/transactions?"+query, nil)
+		addAuthHeader(req, 1)
+		rr := httptest.NewRecorder()
+
+		middleware.Auth(http.HandlerFunc(h.Transactions)).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestBalanceHandler_Balance_Held(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := balancemocks.NewMockBalanceService(ctrl)
+	log := zaptest.NewLogger(t).Sugar()
+	h := NewBalanceHandler(mockService, log)
+
+	mockService.EXPECT().GetBalance(gomock.Any(), int64(1)).Return(&model.Balance{Current: model.Amount(700), Held: model.Amount(300), ExpiringSoon: model.Amount(200), Debt: model.Amount(100)}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/balance", nil)
+	addAuthHeader(req, 1)
+	rr := httptest.NewRecorder()
+
+	middleware.Auth(http.HandlerFunc(h.Balance)).ServeHTTP(rr, req)
+
+	var resp balanceResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Current != 7 || resp.Available != 7 || resp.Held != 3 || resp.ExpiringSoon != 2 || resp.Debt != 1 {
+		t.Fatalf("unexpected balance: %+v", resp)
+	}
+}
+
+func TestBalanceHandler_Hold(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := balancemocks.NewMockBalanceService(ctrl)
+	log := zaptest.NewLogger(t).Sugar()
+	h := NewBalanceHandler(mockService, log)
+
+	mockService.EXPECT().Hold(gomock.Any(), int64(1), "79927398713", model.Amount(500)).Return(&model.Hold{
+		ID: 3, OrderNumber: "79927398713", Amount: model.Amount(500), Status: model.HoldStatusHeld, ExpiresAt: time.Now().Add(time.Minute),
+	}, nil)
+
+	body := bytes.NewBufferString(`{"order":"79927398713","sum":5}`)
+	req := httptest.NewRequest(http.MethodPost, config.PathUserHolds, body)
+	addAuthHeader(req, 1)
+	rr := httptest.NewRecorder()
+
+	middleware.Auth(http.HandlerFunc(h.Hold)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rr.Code)
+	}
+	var resp holdResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.ID != 3 || resp.Status != string(model.HoldStatusHeld) || resp.Sum != 5 {
+		t.Fatalf("unexpected hold: %+v", resp)
+	}
+}
+
+func TestBalanceHandler_ResolveHold(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		serviceErr error
+		wantStatus int
+	}{
+		{"captured", "3", nil, http.StatusOK},
+		{"not found", "3", sql.ErrNoRows, http.StatusNotFound},
+		{"no longer active", "3", repository.ErrHoldNotActive, http.StatusConflict},
+		{"invalid id", "x", nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := balancemocks.NewMockBalanceService(ctrl)
+			h := NewBalanceHandler(mockService, zaptest.NewLogger(t).Sugar())
+
+			if tt.wantStatus != http.StatusBadRequest {
+				var hold *model.Hold
+				if tt.serviceErr == nil {
+					hold = &model.Hold{ID: 3, Status: model.HoldStatusCaptured}
+				}
+				mockService.EXPECT().Capture(gomock.Any(), int64(1), int64(3)).Return(hold, tt.serviceErr)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, config.PathUserHoldCapture, nil)
+			addAuthHeader(req, 1)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+
+			middleware.Auth(http.HandlerFunc(h.CaptureHold)).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestBalanceHandler_Transfer(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		serviceErr error
+		wantStatus int
+	}{
+		{"transferred", `{"recipient":"bob","sum":5}`, nil, http.StatusOK},
+		{"unknown recipient", `{"recipient":"bob","sum":5}`, repository.ErrRecipientNotFound, http.StatusNotFound},
+		{"to self", `{"recipient":"bob","sum":5}`, repository.ErrTransferToSelf, http.StatusUnprocessableEntity},
+		{"limit exceeded", `{"recipient":"bob","sum":5}`, repository.ErrTransferLimitExceeded, http.StatusUnprocessableEntity},
+		{"insufficient funds", `{"recipient":"bob","sum":5}`, repository.ErrInsufficientFunds, http.StatusPaymentRequired},
+		{"missing recipient", `{"recipient":" ","sum":5}`, nil, http.StatusBadRequest},
+		{"invalid sum", `{"recipient":"bob","sum":0}`, nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := balancemocks.NewMockBalanceService(ctrl)
+			h := NewBalanceHandler(mockService, zaptest.NewLogger(t).Sugar())
+
+			if tt.wantStatus != http.StatusBadRequest {
+				var transfer *model.Transfer
+				if tt.serviceErr == nil {
+					transfer = &model.Transfer{ID: 4, SenderID: 1, RecipientID: 2, RecipientLogin: "bob", Amount: model.Amount(500), CreatedAt: time.Now()}
+				}
+				mockService.EXPECT().Transfer(gomock.Any(), int64(1), "bob", model.Amount(500)).Return(transfer, tt.serviceErr)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, config.PathUserTransfer, bytes.NewBufferString(tt.body))
+			addAuthHeader(req, 1)
+			rr := httptest.NewRecorder()
+
+			middleware.Auth(http.HandlerFunc(h.Transfer)).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp transferResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.ID != 4 || resp.Recipient != "bob" || resp.Sum != 5 {
+				t.Fatalf("unexpected transfer: %+v", resp)
+			}
+		})
+	}
+}
+
+func TestBalanceHandler_Balance_Pending(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := balancemocks.NewMockBalanceService(ctrl)
+	h := NewBalanceHandler(mockService, zaptest.NewLogger(t).Sugar())
+
+	mockService.EXPECT().GetBalance(gomock.Any(), int64(1)).Return(&model.Balance{
+		Current:        model.Amount(700),
+		Pending:        model.Amount(250),
+		PendingUnknown: 1,
+		Orders: map[model.OrderStatus]int64{
+			model.OrderStatusNew:        1,
+			model.OrderStatusProcessing: 2,
+		},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, config.PathUserBalance, nil)
+	addAuthHeader(req, 1)
+	rr := httptest.NewRecorder()
+
+	middleware.Auth(http.HandlerFunc(h.Balance)).ServeHTTP(rr, req)
+
+	var resp balanceResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Pending != 2.5 || resp.PendingUnknown != 1 {
+		t.Fatalf("unexpected pending: %+v", resp)
+	}
+	// Statuses without orders are reported as zero
+	want := map[string]int64{"NEW": 1, "PROCESSING": 2, "INVALID": 0, "PROCESSED": 0}
+	if len(resp.Orders) != len(want) {
+		t.Fatalf("unexpected order counts: %+v", resp.Orders)
+	}
+	for status, count := range want {
+		if resp.Orders[status] != count {
+			t.Fatalf("unexpected order counts: %+v", resp.Orders)
+		}
+	}
+}
@@ -33,6 +33,8 @@ func getStatusCode(err error) int {
 		return http.StatusConflict
 	case errors.Is(err, repository.ErrInsufficientFunds):
 		return http.StatusPaymentRequired
+	case errors.Is(err, repository.ErrWithdrawalConflict), errors.Is(err, repository.ErrWithdrawalReversed):
+		return http.StatusUnprocessableEntity
 	default:
 		return http.StatusInternalServerError
 	}
@@ -55,6 +57,12 @@ func getErrorMessage(err error, statusCode int) string {
 	case http.StatusUnauthorized:
 		return "invalid credentials"
 	case http.StatusUnprocessableEntity:
+		if errors.Is(err, repository.ErrWithdrawalConflict) {
+			return "order already has a withdrawal with a different sum"
+		}
+		if errors.Is(err, repository.ErrWithdrawalReversed) {
+			return "withdrawal for this order was reversed"
+		}
 		return "invalid order number"
 	case http.StatusPaymentRequired:
 		return "insufficient funds"
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/prbllm/go-loyalty-service/internal/config"
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/service/accrual"
+	"github.com/prbllm/go-loyalty-service/internal/logger"
+)
+
+const (
+	healthStatusOK       = "ok"
+	healthStatusDegraded = "degraded"
+)
+
+type BreakerStatus interface {
+	State() accrual.BreakerState
+}
+
+type HealthHandler struct {
+	breaker BreakerStatus
+	logger  logger.Logger
+}
+
+type healthResponse struct {
+	Status  string                `json:"status"`
+	Accrual accrualHealthResponse `json:"accrual"`
+}
+
+type accrualHealthResponse struct {
+	CircuitBreaker string `json:"circuit_breaker"`
+}
+
+func NewHealthHandler(breaker BreakerStatus, logger logger.Logger) *HealthHandler {
+	return &HealthHandler{
+		breaker: breaker,
+		logger:  logger,
+	}
+}
+
+// Health answers 200 while gophermart itself serves requests;
+// an accrual outage only degrades the status, since users can still log in and withdraw.
+func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
+	state := h.breaker.State()
+
+	response := healthResponse{
+		Status:  healthStatusOK,
+		Accrual: accrualHealthResponse{CircuitBreaker: string(state)},
+	}
+	if state != accrual.BreakerClosed {
+		response.Status = healthStatusDegraded
+	}
+
+	w.Header().Set(config.HeaderContentType, config.ContentTypeJSON)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Errorf("health: encode response: %v", err)
+	}
+}
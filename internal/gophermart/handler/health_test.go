@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prbllm/go-loyalty-service/internal/config"
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/service/accrual"
+	"go.uber.org/zap/zaptest"
+)
+
+type breakerState accrual.BreakerState
+
+func (s breakerState) State() accrual.BreakerState {
+	return accrual.BreakerState(s)
+}
+
+func TestHealth(t *testing.T) {
+	tests := []struct {
+		name       string
+		state      accrual.BreakerState
+		wantStatus string
+	}{
+		{"accrual available", accrual.BreakerClosed, "ok"},
+		{"accrual unavailable", accrual.BreakerOpen, "degraded"},
+		{"accrual probing", accrual.BreakerHalfOpen, "degraded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHealthHandler(breakerState(tt.state), zaptest.NewLogger(t).Sugar())
+
+			req := httptest.NewRequest(http.MethodGet, config.PathHealth, nil)
+			rr := httptest.NewRecorder()
+			handler.Health(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+
+			var resp healthResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Status != tt.wantStatus || resp.Accrual.CircuitBreaker != string(tt.state) {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
@@ -36,7 +36,7 @@ func TestIntegrationHappyPath(t *testing.T) {
 
 	orderSvc.EXPECT().Upload(gomock.Any(), int64(1), "79927398713").Return(nil)
 	now := time.Now().UTC()
-	orderSvc.EXPECT().List(gomock.Any(), int64(1)).Return([]*model.Order{
+	orderSvc.EXPECT().List(gomock.Any(), int64(1), model.OrderFilter{}).Return([]*model.Order{
 		{
 			Number:     "79927398713",
 			Status:     model.OrderStatusProcessed,
@@ -50,7 +50,7 @@ func TestIntegrationHappyPath(t *testing.T) {
 		Withdrawn: model.Amount(0),
 	}, nil)
 	balanceSvc.EXPECT().Withdraw(gomock.Any(), int64(1), "79927398713", model.Amount(500)).Return(nil)
-	balanceSvc.EXPECT().GetWithdrawals(gomock.Any(), int64(1)).Return([]*model.Withdrawal{
+	balanceSvc.EXPECT().GetWithdrawals(gomock.Any(), int64(1), model.WithdrawalFilter{}).Return([]*model.Withdrawal{
 		{OrderNumber: "79927398713", Sum: model.Amount(500), ProcessedAt: now},
 	}, nil)
 
@@ -2,8 +2,10 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -74,12 +76,23 @@ func (h *OrderHandler) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	orders, err := h.service.List(r.Context(), userID)
+	filter, err := parseOrderFilter(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := filter.Limit
+	filter.Page = lookahead(filter.Page)
+
+	orders, err := h.service.List(r.Context(), userID, filter)
 	if err != nil {
 		h.logger.Errorf("list orders error: %v", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	orders = paginate(w, r, orders, limit, func(o *model.Order) model.Cursor {
+		return model.Cursor{Time: o.UploadedAt, ID: o.ID}
+	})
 
 	if len(orders) == 0 {
 		w.WriteHeader(http.StatusNoContent)
@@ -108,6 +121,33 @@ func (h *OrderHandler) List(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// parseOrderFilter reads status (repeated or comma separated), from, to, limit and cursor
+func parseOrderFilter(query url.Values) (model.OrderFilter, error) {
+	var filter model.OrderFilter
+
+	for _, value := range query[config.QueryParamStatus] {
+		for _, name := range strings.Split(value, ",") {
+			status := model.OrderStatus(strings.ToUpper(strings.TrimSpace(name)))
+			switch status {
+			case model.OrderStatusNew, model.OrderStatusProcessing, model.OrderStatusInvalid, model.OrderStatusProcessed:
+				filter.Statuses = append(filter.Statuses, status)
+			default:
+				return filter, fmt.Errorf("invalid order status %q", name)
+			}
+		}
+	}
+
+	var err error
+	if filter.From, filter.To, err = parseDateRange(query); err != nil {
+		return filter, err
+	}
+	if filter.Page, err = parsePage(query); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
 func getUserID(w http.ResponseWriter, r *http.Request) (int64, bool) {
 	userID, ok := middleware.UserIDFromContext(r.Context())
 	if !ok {
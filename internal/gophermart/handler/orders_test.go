@@ -98,7 +98,7 @@ func TestOrderListHandler(t *testing.T) {
 			UploadedAt: now.Add(-time.Minute),
 		},
 	}
-	mockService.EXPECT().List(gomock.Any(), int64(1)).Return(expectedOrders, nil)
+	mockService.EXPECT().List(gomock.Any(), int64(1), model.OrderFilter{}).Return(expectedOrders, nil)
 
 	handler := NewOrderHandler(mockService, log)
 
@@ -132,6 +132,103 @@ func TestOrderListHandler(t *testing.T) {
 	}
 }
 
+func TestOrderListHandlerPaging(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := ordermocks.NewMockOrderService(ctrl)
+	token, _ := utils.GenerateToken(1)
+	log := zaptest.NewLogger(t).Sugar()
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	after := model.Cursor{Time: now, ID: 10}
+	// The handler asks for one row more than the limit to find out whether there is a next page
+	mockService.EXPECT().List(gomock.Any(), int64(1), model.OrderFilter{
+		Statuses: []model.OrderStatus{model.OrderStatusProcessed},
+		Page:     model.Page{Limit: 3, After: &after},
+	}).Return([]*model.Order{
+		{ID: 9, Number: "9", Status: model.OrderStatusProcessed, UploadedAt: now.Add(-time.Minute)},
+		{ID: 8, Number: "8", Status: model.OrderStatusProcessed, UploadedAt: now.Add(-2 * time.Minute)},
+		{ID: 7, Number: "7", Status: model.OrderStatusProcessed, UploadedAt: now.Add(-3 * time.Minute)},
+	}, nil)
+
+	handler := NewOrderHandler(mockService, log)
+
+	req := httptest.NewRequest(http.MethodGet, config.PathUserOrders+"?status=processed&limit=2&cursor="+after.String(), nil)
+	req.Header.Set(config.HeaderAuthorization, config.BearerPrefix+token)
+	rr := httptest.NewRecorder()
+
+	middleware.Auth(http.HandlerFunc(handler.List)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	var resp []map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid json response: %v", err)
+	}
+	if len(resp) != 2 {
+		t.Fatalf("expected 2 orders, got %d", len(resp))
+	}
+
+	next := model.Cursor{Time: now.Add(-2 * time.Minute), ID: 8}
+	wantLink := `<` + config.PathUserOrders + `?cursor=` + next.String() + `&limit=2&status=processed>; rel="next"`
+	if link := rr.Header().Get(config.HeaderLink); link != wantLink {
+		t.Fatalf("expected link %s, got %s", wantLink, link)
+	}
+}
+
+func TestOrderListHandlerLastPage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := ordermocks.NewMockOrderService(ctrl)
+	token, _ := utils.GenerateToken(1)
+	log := zaptest.NewLogger(t).Sugar()
+
+	mockService.EXPECT().List(gomock.Any(), int64(1), model.OrderFilter{Page: model.Page{Limit: 3}}).Return([]*model.Order{
+		{ID: 1, Number: "1", Status: model.OrderStatusNew, UploadedAt: time.Now()},
+	}, nil)
+
+	handler := NewOrderHandler(mockService, log)
+
+	req := httptest.NewRequest(http.MethodGet, config.PathUserOrders+"?limit=2", nil)
+	req.Header.Set(config.HeaderAuthorization, config.BearerPrefix+token)
+	rr := httptest.NewRecorder()
+
+	middleware.Auth(http.HandlerFunc(handler.List)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if link := rr.Header().Get(config.HeaderLink); link != "" {
+		t.Fatalf("expected no link on the last page, got %s", link)
+	}
+}
+
+func TestOrderListHandlerInvalidQuery(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := ordermocks.NewMockOrderService(ctrl)
+	token, _ := utils.GenerateToken(1)
+	log := zaptest.NewLogger(t).Sugar()
+	handler := NewOrderHandler(mockService, log)
+
+	for _, query := range []string{"limit=0", "limit=5000", "cursor=bogus", "status=DONE", "to=tomorrow"} {
+		req := httptest.NewRequest(http.MethodGet, config.PathUserOrders+"?"+query, nil)
+		req.Header.Set(config.HeaderAuthorization, config.BearerPrefix+token)
+		rr := httptest.NewRecorder()
+
+		middleware.Auth(http.HandlerFunc(handler.List)).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
 func TestOrderListHandlerEmpty(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -140,7 +237,7 @@ func TestOrderListHandlerEmpty(t *testing.T) {
 	token, _ := utils.GenerateToken(1)
 	log := zaptest.NewLogger(t).Sugar()
 
-	mockService.EXPECT().List(gomock.Any(), int64(1)).Return([]*model.Order{}, nil)
+	mockService.EXPECT().List(gomock.Any(), int64(1), model.OrderFilter{}).Return([]*model.Order{}, nil)
 
 	handler := NewOrderHandler(mockService, log)
 
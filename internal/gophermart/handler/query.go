@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/prbllm/go-loyalty-service/internal/config"
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
+)
+
+// parseDateRange reads from and to as RFC 3339 or a plain date in UTC. A date in to covers the whole day.
+func parseDateRange(query url.Values) (time.Time, time.Time, error) {
+	var from, to time.Time
+
+	if value := query.Get(config.QueryParamFrom); value != "" {
+		t, _, err := parseTime(value)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid %s: %s", config.QueryParamFrom, value)
+		}
+		from = t
+	}
+	if value := query.Get(config.QueryParamTo); value != "" {
+		t, dateOnly, err := parseTime(value)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid %s: %s", config.QueryParamTo, value)
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		to = t
+	}
+
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return from, to, fmt.Errorf("%s must be before %s", config.QueryParamFrom, config.QueryParamTo)
+	}
+	return from, to, nil
+}
+
+func parseTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	return t, true, err
+}
+
+// parsePage reads limit and cursor. Without limit the whole list is returned, as before paging existed.
+func parsePage(query url.Values) (model.Page, error) {
+	var page model.Page
+
+	if value := query.Get(config.QueryParamLimit); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > config.MaxPageLimit {
+			return page, fmt.Errorf("%s must be between 1 and %d", config.QueryParamLimit, config.MaxPageLimit)
+		}
+		page.Limit = limit
+	}
+	if value := query.Get(config.QueryParamCursor); value != "" {
+		cursor, err := model.ParseCursor(value)
+		if err != nil {
+			return page, fmt.Errorf("invalid %s", config.QueryParamCursor)
+		}
+		page.After = &cursor
+	}
+
+	return page, nil
+}
+
+// lookahead asks for one row more than the page holds; the extra row tells whether there is a next page
+func lookahead(page model.Page) model.Page {
+	if page.Limit > 0 {
+		page.Limit++
+	}
+	return page
+}
+
+// paginate drops the lookahead row and, if there was one, links the page after the last row kept
+func paginate[T any](w http.ResponseWriter, r *http.Request, items []T, limit int, cursor func(T) model.Cursor) []T {
+	if limit <= 0 || len(items) <= limit {
+		return items
+	}
+	items = items[:limit]
+
+	query := r.URL.Query()
+	query.Set(config.QueryParamCursor, cursor(items[limit-1]).String())
+	next := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	w.Header().Add(config.HeaderLink, fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+
+	return items
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+
+	"github.com/prbllm/go-loyalty-service/internal/config"
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
+	"github.com/prbllm/go-loyalty-service/internal/logger"
+)
+
+// IdempotencyStore keeps the responses to requests sent with an Idempotency-Key header
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(ctx context.Context, userID int64, key, fingerprint string) (*model.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, userID int64, key string, statusCode int, contentType string, body []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, userID int64, key string) error
+}
+
+// Idempotency replays the stored response when a request is repeated with the same Idempotency-Key,
+// and rejects a key reused for a different request with 422. Requests without the header pass through.
+// Server errors and panics are not stored, so the client can retry them with the same key. Must run after Auth.
+func Idempotency(store IdempotencyStore, log logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(config.HeaderIdempotencyKey)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > config.MaxIdempotencyKeyLength {
+				http.Error(w, "idempotency key is too long", http.StatusBadRequest)
+				return
+			}
+
+			userID, ok := UserIDFromContext(r.Context())
+			if !ok {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			rec, err := store.ReserveIdempotencyKey(r.Context(), userID, key, fingerprint(r, body))
+			if err != nil {
+				log.Errorf("idempotency: reserve key for user %d: %v", userID, err)
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			if rec != nil {
+				replay(w, r, rec, body)
+				return
+			}
+
+			// The response is already sent; keep the record even if the client has gone away
+			ctx := context.WithoutCancel(r.Context())
+			release := func() {
+				if err := store.ReleaseIdempotencyKey(ctx, userID, key); err != nil {
+					log.Errorf("idempotency: release key for user %d: %v", userID, err)
+				}
+			}
+
+			// A panicking handler must not leave the key in progress forever
+			defer func() {
+				if p := recover(); p != nil {
+					release()
+					panic(p)
+				}
+			}()
+
+			var recorded bytes.Buffer
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(&recorded)
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			if status >= http.StatusInternalServerError {
+				release()
+				return
+			}
+			if err := store.CompleteIdempotencyKey(ctx, userID, key, status,
+				ww.Header().Get(config.HeaderContentType), recorded.Bytes()); err != nil {
+				log.Errorf("idempotency: store response for user %d: %v", userID, err)
+			}
+		})
+	}
+}
+
+func replay(w http.ResponseWriter, r *http.Request, rec *model.IdempotencyRecord, body []byte) {
+	if rec.Fingerprint != fingerprint(r, body) {
+		http.Error(w, "idempotency key was used for a different request", http.StatusUnprocessableEntity)
+		return
+	}
+	if rec.StatusCode == 0 {
+		http.Error(w, "request with this idempotency key is in progress", http.StatusConflict)
+		return
+	}
+
+	if rec.ContentType != "" {
+		w.Header().Set(config.HeaderContentType, rec.ContentType)
+	}
+	w.Header().Set(config.HeaderIdempotentReplayed, "true")
+	w.WriteHeader(rec.StatusCode)
+	_, _ = w.Write(rec.Body)
+}
+
+// fingerprint identifies the request by method, path and body. JSON bodies are compared
+// by content, so formatting and key order do not make a retry look like a new request.
+func fingerprint(r *http.Request, body []byte) string {
+	var decoded any
+	if err := json.Unmarshal(body, &decoded); err == nil {
+		if canonical, err := json.Marshal(decoded); err == nil {
+			body = canonical
+		}
+	}
+
+	h := sha256.New()
+	h.Write([]byte(r.Method + "\n" + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
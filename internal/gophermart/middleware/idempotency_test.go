@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prbllm/go-loyalty-service/internal/config"
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
+	"go.uber.org/zap/zaptest"
+)
+
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*model.IdempotencyRecord
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: make(map[string]*model.IdempotencyRecord)}
+}
+
+func (s *memoryIdempotencyStore) ReserveIdempotencyKey(_ context.Context, userID int64, key, fingerprint string) (*model.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.records[key]; ok {
+		copied := *rec
+		return &copied, nil
+	}
+	s.records[key] = &model.IdempotencyRecord{UserID: userID, Key: key, Fingerprint: fingerprint}
+	return nil, nil
+}
+
+func (s *memoryIdempotencyStore) CompleteIdempotencyKey(_ context.Context, _ int64, key string, statusCode int, contentType string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := s.records[key]
+	rec.StatusCode, rec.ContentType, rec.Body = statusCode, contentType, body
+	return nil
+}
+
+func (s *memoryIdempotencyStore) ReleaseIdempotencyKey(_ context.Context, _ int64, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+func idempotentRequest(key, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, config.PathUserWithdraw, strings.NewReader(body))
+	req.Header.Set(config.HeaderIdempotencyKey, key)
+	return req.WithContext(context.WithValue(req.Context(), userIDContextKey, int64(1)))
+}
+
+func TestIdempotency_ReplaysResponse(t *testing.T) {
+	calls := 0
+	handler := Idempotency(newMemoryIdempotencyStore(), zaptest.NewLogger(t).Sugar())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set(config.HeaderContentType, config.ContentTypeJSON)
+		w.WriteHeader(http.StatusPaymentRequired)
+		w.Write([]byte(`{"error":"insufficient funds"}`))
+	}))
+
+	first := httptest.NewRecorder()
+	handler.ServeHTTP(first, idempotentRequest("key-1", `{"order":"1","sum":5}`))
+
+	// Same content with other formatting and key order is the same request
+	second := httptest.NewRecorder()
+	handler.ServeHTTP(second, idempotentRequest("key-1", `{ "sum": 5, "order": "1" }`))
+
+	if calls != 1 {
+		t.Fatalf("expected handler to run once, ran %d times", calls)
+	}
+	if second.Code != http.StatusPaymentRequired || second.Body.String() != first.Body.String() {
+		t.Fatalf("expected replay of %d %s, got %d %s", first.Code, first.Body, second.Code, second.Body)
+	}
+	if second.Header().Get(config.HeaderIdempotentReplayed) != "true" ||
+		second.Header().Get(config.HeaderContentType) != config.ContentTypeJSON {
+		t.Fatalf("unexpected replay headers: %v", second.Header())
+	}
+}
+
+func TestIdempotency_RejectsDifferentPayload(t *testing.T) {
+	handler := Idempotency(newMemoryIdempotencyStore(), zaptest.NewLogger(t).Sugar())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("key-1", `{"order":"1","sum":5}`))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, idempotentRequest("key-1", `{"order":"1","sum":6}`))
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rr.Code)
+	}
+}
+
+func TestIdempotency_InProgress(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	handler := Idempotency(store, zaptest.NewLogger(t).Sugar())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A retry arrives while the first request is still being handled
+		rr := httptest.NewRecorder()
+		Idempotency(store, zaptest.NewLogger(t).Sugar())(http.NotFoundHandler()).ServeHTTP(rr, idempotentRequest("key-1", `{}`))
+		if rr.Code != http.StatusConflict {
+			t.Errorf("expected 409 for concurrent retry, got %d", rr.Code)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, idempotentRequest("key-1", `{}`))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+}
+
+func TestIdempotency_ServerErrorIsRetryable(t *testing.T) {
+	calls := 0
+	handler := Idempotency(newMemoryIdempotencyStore(), zaptest.NewLogger(t).Sugar())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("key-1", `{}`))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, idempotentRequest("key-1", `{}`))
+
+	if calls != 2 || rr.Code != http.StatusOK {
+		t.Fatalf("expected retry to reach the handler, calls %d status %d", calls, rr.Code)
+	}
+}
+
+func TestIdempotency_PanicReleasesKey(t *testing.T) {
+	calls := 0
+	handler := Idempotency(newMemoryIdempotencyStore(), zaptest.NewLogger(t).Sugar())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			panic("handler failed")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected the panic to propagate")
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("key-1", `{}`))
+	}()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, idempotentRequest("key-1", `{}`))
+
+	if calls != 2 || rr.Code != http.StatusOK {
+		t.Fatalf("expected retry to reach the handler, calls %d status %d", calls, rr.Code)
+	}
+}
+
+func TestIdempotency_WithoutKey(t *testing.T) {
+	calls := 0
+	handler := Idempotency(newMemoryIdempotencyStore(), zaptest.NewLogger(t).Sugar())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+
+	for i := 0; i < 2; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("", `{}`))
+	}
+
+	if calls != 2 {
+		t.Fatalf("expected every request without a key to reach the handler, got %d", calls)
+	}
+}
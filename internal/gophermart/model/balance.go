@@ -2,13 +2,40 @@ package model
 
 import "time"
 
+// Balance of a user. Current is what the user can spend: the ledger balance minus Held,
+// the points reserved by active holds. ExpiringSoon is the part of the balance that expires
+// within the warning window. Debt is what clawed back accruals left unpaid; later credits
+// pay it off first.
 type Balance struct {
-	Current   Amount
-	Withdrawn Amount
+	Current      Amount
+	Withdrawn    Amount
+	Held         Amount
+	ExpiringSoon Amount
+	Debt         Amount
+
+	// Pending is the expected accrual of unfinished orders; PendingUnknown counts the
+	// unfinished orders accrual has not estimated yet. Orders counts orders by status.
+	Pending        Amount
+	PendingUnknown int64
+	Orders         map[OrderStatus]int64
 }
 
+// NegativeBalancePolicy decides what happens when a clawed back accrual exceeds the balance
+type NegativeBalancePolicy string
+
+const (
+	NegativeBalanceAllow NegativeBalancePolicy = "allow" // debit in full, the balance goes negative
+	NegativeBalanceFloor NegativeBalancePolicy = "floor" // debit down to zero, write off the rest
+	NegativeBalanceDebt  NegativeBalancePolicy = "debt"  // debit down to zero, recover the rest from later credits
+)
+
 type Withdrawal struct {
+	ID          int64
 	OrderNumber string
 	Sum         Amount
 	ProcessedAt time.Time
+
+	// Set once the withdrawal is reversed and its sum credited back
+	ReversedAt     *time.Time
+	ReversalReason string
 }
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+type HoldStatus string
+
+const (
+	HoldStatusHeld     HoldStatus = "HELD"
+	HoldStatusCaptured HoldStatus = "CAPTURED"
+	HoldStatusReleased HoldStatus = "RELEASED"
+	HoldStatusExpired  HoldStatus = "EXPIRED"
+)
+
+// Hold reserves points for an order until it is captured as a withdrawal, released or expires
+type Hold struct {
+	ID           int64
+	UserID       int64
+	OrderNumber  string
+	Amount       Amount
+	Status       HoldStatus
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+	ResolvedAt   *time.Time
+	WithdrawalID int64
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// IdempotencyRecord is the stored outcome of a request sent with an Idempotency-Key.
+// StatusCode is 0 while the first request is still being handled.
+type IdempotencyRecord struct {
+	UserID      int64
+	Key         string
+	Fingerprint string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+}
@@ -0,0 +1,57 @@
+package model
+
+import "time"
+
+type LedgerEntryType string
+
+const (
+	LedgerEntryAccrual    LedgerEntryType = "ACCRUAL"
+	LedgerEntryWithdrawal LedgerEntryType = "WITHDRAWAL"
+	LedgerEntryAdjustment LedgerEntryType = "ADJUSTMENT"
+	LedgerEntryReversal   LedgerEntryType = "REVERSAL"
+	LedgerEntryExpiration LedgerEntryType = "EXPIRATION"
+	LedgerEntryTransfer   LedgerEntryType = "TRANSFER"
+)
+
+// Ledger accounts. Every user has a user account; the others are system accounts
+// that balance the postings.
+const (
+	LedgerAccountUser        = "user"
+	LedgerAccountAccruals    = "accruals"    // issues accrued points
+	LedgerAccountRedemptions = "redemptions" // receives withdrawn points
+	LedgerAccountExpirations = "expirations" // receives expired points
+)
+
+// LedgerEntry is one side of a posting. Amount is positive for a credit and negative for a debit;
+// the entries of a posting sum to zero.
+type LedgerEntry struct {
+	ID           int64
+	PostingID    int64
+	UserID       int64 // 0 for system accounts
+	Account      string
+	Type         LedgerEntryType
+	Amount       Amount
+	OrderNumber  string
+	WithdrawalID int64
+	TransferID   int64
+	CreatedAt    time.Time
+}
+
+// Transaction is an entry of the user account as shown in the history; Balance is the
+// running balance right after it. Counterparty is the login of the other user of a transfer.
+type Transaction struct {
+	ID           int64
+	Type         LedgerEntryType
+	Amount       Amount
+	Balance      Amount
+	OrderNumber  string
+	Counterparty string
+	CreatedAt    time.Time
+}
+
+// TransactionFilter narrows the history; zero fields do not filter. To is exclusive.
+type TransactionFilter struct {
+	Types []LedgerEntryType
+	From  time.Time
+	To    time.Time
+}
@@ -11,6 +11,13 @@ const (
 	OrderStatusProcessed  OrderStatus = "PROCESSED"
 )
 
+// RecheckPolicy makes the poller ask accrual again about orders processed within Window,
+// every Interval, so lowered or reversed accruals are clawed back. A zero Window disables it.
+type RecheckPolicy struct {
+	Window   time.Duration
+	Interval time.Duration
+}
+
 type Order struct {
 	ID         int64
 	UserID     int64
@@ -18,4 +25,10 @@ type Order struct {
 	Status     OrderStatus
 	Accrual    Amount
 	UploadedAt time.Time
+
+	PollAttempts  int
+	NextPollAt    time.Time
+	LastPollError string
+	ParkedAt      *time.Time
+	ProcessedAt   *time.Time
 }
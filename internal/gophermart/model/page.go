@@ -0,0 +1,68 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks the last row of a page. Lists are ordered newest first by time, then by id,
+// so the next page starts right after (Time, ID).
+type Cursor struct {
+	Time time.Time
+	ID   int64
+}
+
+// String encodes the cursor as an opaque URL-safe token
+func (c Cursor) String() string {
+	raw := strconv.FormatInt(c.Time.UnixMicro(), 10) + "_" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func ParseCursor(token string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	micros, id, ok := strings.Cut(string(raw), "_")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+	ts, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	rowID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	return Cursor{Time: time.UnixMicro(ts).UTC(), ID: rowID}, nil
+}
+
+// Page limits a list; zero Limit returns every row after After
+type Page struct {
+	Limit int
+	After *Cursor
+}
+
+// OrderFilter narrows the orders of a user; zero fields do not filter. To is exclusive.
+type OrderFilter struct {
+	Statuses []OrderStatus
+	From     time.Time
+	To       time.Time
+	Page
+}
+
+// WithdrawalFilter narrows the withdrawals of a user; zero fields do not filter. To is exclusive.
+type WithdrawalFilter struct {
+	From time.Time
+	To   time.Time
+	Page
+}
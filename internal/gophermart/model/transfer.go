@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// Transfer moves points from the sender to another user
+type Transfer struct {
+	ID             int64
+	SenderID       int64
+	RecipientID    int64
+	RecipientLogin string
+	Amount         Amount
+	CreatedAt      time.Time
+}
+
+// TransferLimits caps what a user may send within the last 24 hours; zero fields do not limit
+type TransferLimits struct {
+	DailySum   Amount
+	DailyCount int
+}
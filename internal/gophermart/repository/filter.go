@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
+)
+
+// whereClause collects the conditions of a dynamically filtered query. Conditions use ? for
+// arguments; they are numbered in order of appearance.
+type whereClause struct {
+	args       []any
+	conditions []string
+}
+
+func newWhereClause(args ...any) *whereClause {
+	return &whereClause{args: args}
+}
+
+func (w *whereClause) add(condition string, args ...any) {
+	for _, arg := range args {
+		w.args = append(w.args, arg)
+		condition = strings.Replace(condition, "?", fmt.Sprintf("$%d", len(w.args)), 1)
+	}
+	w.conditions = append(w.conditions, condition)
+}
+
+func (w *whereClause) in(column string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	w.add(column+" IN ("+placeholders+")", args...)
+}
+
+// between limits column to [from, to); zero bounds are open
+func (w *whereClause) between(column string, from, to time.Time) {
+	if !from.IsZero() {
+		w.add(column+" >= ?", from)
+	}
+	if !to.IsZero() {
+		w.add(column+" < ?", to)
+	}
+}
+
+// after continues a list ordered by timeColumn, idColumn descending past the cursor
+func (w *whereClause) after(timeColumn, idColumn string, cursor *model.Cursor) {
+	if cursor != nil {
+		w.add("("+timeColumn+", "+idColumn+") < (?, ?)", cursor.Time, cursor.ID)
+	}
+}
+
+// String renders the conditions joined with AND, prefixed with the given keyword
+func (w *whereClause) String(keyword string) string {
+	if len(w.conditions) == 0 {
+		return ""
+	}
+	return " " + keyword + " " + strings.Join(w.conditions, " AND ")
+}
+
+// limit adds a LIMIT argument; call it after all conditions, 0 means no limit
+func (w *whereClause) limit(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	w.args = append(w.args, n)
+	return fmt.Sprintf(" LIMIT $%d", len(w.args))
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
+)
+
+var (
+	ErrHoldConflict  = errors.New("order already has an active hold or a withdrawal")
+	ErrHoldNotActive = errors.New("hold is no longer active")
+)
+
+const holdColumns = "id, user_id, order_number, amount, status, expires_at, created_at, resolved_at, COALESCE(withdrawal_id, 0)"
+
+// HoldBalance reserves amount for the order until ttl passes. The reserved points are not
+// available for withdrawals or other holds.
+func (r *PostgresRepository) HoldBalance(ctx context.Context, userID int64, orderNumber string, amount model.Amount, ttl time.Duration) (*model.Hold, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockUser(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	// An expired hold the sweeper has not reached yet must not block a new one
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE gophermart.balance_holds SET status = $3, resolved_at = NOW()
+		WHERE user_id = $1 AND order_number = $2 AND status = $4 AND expires_at <= NOW()`,
+		userID, orderNumber, string(model.HoldStatusExpired), string(model.HoldStatusHeld)); err != nil {
+		return nil, fmt.Errorf("failed to expire stale hold: %w", err)
+	}
+
+	var taken bool
+	if err := tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM gophermart.balance_holds WHERE user_id = $1 AND order_number = $2 AND status = $3)
+			OR EXISTS (SELECT 1 FROM gophermart.balance_transactions WHERE user_id = $1 AND order_number = $2)`,
+		userID, orderNumber, string(model.HoldStatusHeld)).Scan(&taken); err != nil {
+		return nil, fmt.Errorf("failed to check order: %w", err)
+	}
+	if taken {
+		return nil, ErrHoldConflict
+	}
+
+	balance, err := ledgerBalance(ctx, tx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance: %w", err)
+	}
+	if balance.Current < amount {
+		return nil, ErrInsufficientFunds
+	}
+
+	hold, err := scanHold(tx.QueryRowContext(ctx,
+		`INSERT INTO gophermart.balance_holds (user_id, order_number, amount, expires_at)
+		VALUES ($1, $2, $3, NOW() + make_interval(secs => $4))
+		RETURNING `+holdColumns,
+		userID, orderNumber, amount.Int64(), ttl.Seconds()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert hold: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// CaptureHold turns an active hold into a withdrawal of its amount for its order.
+// It returns sql.ErrNoRows if the user has no such hold.
+func (r *PostgresRepository) CaptureHold(ctx context.Context, userID, holdID int64) (*model.Hold, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockUser(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+	hold, err := lockActiveHold(ctx, tx, userID, holdID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve the hold first so its amount counts as available for the withdrawal
+	if err := resolveHold(ctx, tx, hold, model.HoldStatusCaptured); err != nil {
+		return nil, err
+	}
+	if hold.WithdrawalID, err = withdraw(ctx, tx, userID, hold.OrderNumber, hold.Amount); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE gophermart.balance_holds SET withdrawal_id = $2 WHERE id = $1",
+		hold.ID, hold.WithdrawalID); err != nil {
+		return nil, fmt.Errorf("failed to link hold to withdrawal: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// ReleaseHold gives the points of an active hold back to the available balance.
+// It returns sql.ErrNoRows if the user has no such hold.
+func (r *PostgresRepository) ReleaseHold(ctx context.Context, userID, holdID int64) (*model.Hold, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockUser(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+	hold, err := lockActiveHold(ctx, tx, userID, holdID)
+	if err != nil {
+		return nil, err
+	}
+	if err := resolveHold(ctx, tx, hold, model.HoldStatusReleased); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// ExpireHolds marks the holds past their expiry as expired and returns how many there were.
+// Expired holds stop counting as held right away; this only records it.
+func (r *PostgresRepository) ExpireHolds(ctx context.Context) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE gophermart.balance_holds SET status = $1, resolved_at = NOW()
+		WHERE status = $2 AND expires_at <= NOW()`,
+		string(model.HoldStatusExpired), string(model.HoldStatusHeld))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// lockActiveHold takes the hold FOR UPDATE within tx, which must hold the user lock.
+// Expiry is checked against the database clock, as everywhere else holds are.
+func lockActiveHold(ctx context.Context, tx *sql.Tx, userID, holdID int64) (*model.Hold, error) {
+	var unexpired bool
+	hold, err := scanHold(tx.QueryRowContext(ctx,
+		"SELECT "+holdColumns+", expires_at > NOW() FROM gophermart.balance_holds WHERE id = $1 AND user_id = $2 FOR UPDATE",
+		holdID, userID), &unexpired)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status != model.HoldStatusHeld || !unexpired {
+		return nil, ErrHoldNotActive
+	}
+	return hold, nil
+}
+
+func resolveHold(ctx context.Context, tx *sql.Tx, hold *model.Hold, status model.HoldStatus) error {
+	var resolvedAt time.Time
+	if err := tx.QueryRowContext(ctx,
+		"UPDATE gophermart.balance_holds SET status = $2, resolved_at = NOW() WHERE id = $1 RETURNING resolved_at",
+		hold.ID, string(status)).Scan(&resolvedAt); err != nil {
+		return fmt.Errorf("failed to resolve hold: %w", err)
+	}
+	hold.Status = status
+	hold.ResolvedAt = &resolvedAt
+	return nil
+}
+
+// scanHold reads holdColumns followed by the columns scanned into extra
+func scanHold(row *sql.Row, extra ...any) (*model.Hold, error) {
+	var (
+		h      model.Hold
+		amount int64
+		status string
+	)
+	dest := append([]any{&h.ID, &h.UserID, &h.OrderNumber, &amount, &status,
+		&h.ExpiresAt, &h.CreatedAt, &h.ResolvedAt, &h.WithdrawalID}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	h.Amount = model.FromInt64(amount)
+	h.Status = model.HoldStatus(status)
+	return &h, nil
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
+)
+
+// ReserveIdempotencyKey claims key for a new request. It returns nil if the key was free,
+// otherwise the record left by the earlier request with that key.
+func (r *PostgresRepository) ReserveIdempotencyKey(ctx context.Context, userID int64, key, fingerprint string) (*model.IdempotencyRecord, error) {
+	// A second pass covers a key released between the insert and the read
+	for range 2 {
+		res, err := r.db.ExecContext(ctx,
+			`INSERT INTO gophermart.idempotency_keys (user_id, key, fingerprint)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, key) DO NOTHING`,
+			userID, key, fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return nil, err
+		} else if n == 1 {
+			return nil, nil
+		}
+
+		var (
+			rec         = model.IdempotencyRecord{UserID: userID, Key: key}
+			statusCode  sql.NullInt32
+			contentType sql.NullString
+		)
+		err = r.db.QueryRowContext(ctx,
+			`SELECT fingerprint, status_code, content_type, response_body, created_at
+			FROM gophermart.idempotency_keys WHERE user_id = $1 AND key = $2`,
+			userID, key).Scan(&rec.Fingerprint, &statusCode, &contentType, &rec.Body, &rec.CreatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		rec.StatusCode = int(statusCode.Int32)
+		rec.ContentType = contentType.String
+		return &rec, nil
+	}
+
+	return nil, errors.New("idempotency key is being released concurrently")
+}
+
+// CompleteIdempotencyKey stores the response to replay for later requests with key
+func (r *PostgresRepository) CompleteIdempotencyKey(ctx context.Context, userID int64, key string, statusCode int, contentType string, body []byte) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE gophermart.idempotency_keys
+		SET status_code = $3, content_type = $4, response_body = $5
+		WHERE user_id = $1 AND key = $2`,
+		userID, key, statusCode, contentType, body)
+	return err
+}
+
+// ReleaseIdempotencyKey frees key so the request can be retried, e.g. after an internal error
+func (r *PostgresRepository) ReleaseIdempotencyKey(ctx context.Context, userID int64, key string) error {
+	_, err := r.db.ExecContext(ctx,
+		"DELETE FROM gophermart.idempotency_keys WHERE user_id = $1 AND key = $2",
+		userID, key)
+	return err
+}
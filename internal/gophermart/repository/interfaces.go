@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
 )
@@ -16,12 +17,37 @@ type Repository interface {
 
 	CreateOrder(ctx context.Context, userID int64, orderNumber string) error
 	GetOrderByNumber(ctx context.Context, orderNumber string) (*model.Order, error)
-	GetOrdersByUserID(ctx context.Context, userID int64) ([]*model.Order, error)
+	GetOrdersByUserID(ctx context.Context, userID int64, filter model.OrderFilter) ([]*model.Order, error)
 	GetOrdersByStatus(ctx context.Context, status model.OrderStatus) ([]*model.Order, error)
 	UpdateOrderStatus(ctx context.Context, orderNumber string, status model.OrderStatus, accrual model.Amount) error
+	RecheckOrder(ctx context.Context, orderNumber string, accrual model.Amount, policy model.NegativeBalancePolicy) (model.Amount, error)
+
+	ClaimOrders(ctx context.Context, owner string, limit int, lease time.Duration, recheck model.RecheckPolicy) ([]*model.Order, error)
+	ReleaseOrderLeases(ctx context.Context, owner string) error
+	ScheduleOrderRetry(ctx context.Context, orderNumber string, nextPollAt time.Time, lastError string) error
+	ParkOrder(ctx context.Context, orderNumber string, lastError string) error
+	GetParkedOrders(ctx context.Context) ([]*model.Order, error)
+	RedriveOrder(ctx context.Context, orderNumber string) error
+	GetOrderForPoll(ctx context.Context, orderNumber string) (*model.Order, error)
+	CountOrdersByStatus(ctx context.Context) (map[model.OrderStatus]int64, error)
 
 	GetBalance(ctx context.Context, userID int64) (*model.Balance, error)
 	WithdrawBalance(ctx context.Context, userID int64, orderNumber string, amount model.Amount) error
-	GetWithdrawals(ctx context.Context, userID int64) ([]*model.Withdrawal, error)
+	GetWithdrawals(ctx context.Context, userID int64, filter model.WithdrawalFilter) ([]*model.Withdrawal, error)
 	AddAccrual(ctx context.Context, userID int64, amount model.Amount) error
+	ReverseWithdrawal(ctx context.Context, userID int64, orderNumber, reason string) (*model.Withdrawal, error)
+	Transfer(ctx context.Context, senderID int64, recipientLogin string, amount model.Amount, limits model.TransferLimits) (*model.Transfer, error)
+
+	HoldBalance(ctx context.Context, userID int64, orderNumber string, amount model.Amount, ttl time.Duration) (*model.Hold, error)
+	CaptureHold(ctx context.Context, userID, holdID int64) (*model.Hold, error)
+	ReleaseHold(ctx context.Context, userID, holdID int64) (*model.Hold, error)
+	ExpireHolds(ctx context.Context) (int64, error)
+
+	GetExpiringPoints(ctx context.Context, userID int64, earnedBefore time.Time) (model.Amount, error)
+	ExpirePoints(ctx context.Context, earnedBefore time.Time) (model.Amount, error)
+	GetTransactions(ctx context.Context, userID int64, filter model.TransactionFilter) ([]*model.Transaction, error)
+
+	ReserveIdempotencyKey(ctx context.Context, userID int64, key, fingerprint string) (*model.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, userID int64, key string, statusCode int, contentType string, body []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, userID int64, key string) error
 }
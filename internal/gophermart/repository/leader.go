@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prbllm/go-loyalty-service/internal/logger"
+)
+
+// PollerLockName names the advisory lock held by the replica that runs the accrual poller
+const PollerLockName = "gophermart_accrual_poller"
+
+const (
+	campaignRetryInterval = 5 * time.Second
+	leaderCheckInterval   = 5 * time.Second
+	unlockTimeout         = 5 * time.Second
+)
+
+// LeaderElector elects one replica through a session-level advisory lock. The lock lives
+// on a dedicated connection outside the pool: it is released when that connection closes,
+// so a crashed or partitioned leader is replaced without manual cleanup.
+type LeaderElector struct {
+	dsn    string
+	name   string
+	logger logger.Logger
+}
+
+func NewLeaderElector(dsn, name string, logger logger.Logger) *LeaderElector {
+	return &LeaderElector{
+		dsn:    dsn,
+		name:   name,
+		logger: logger,
+	}
+}
+
+// Campaign blocks until this replica holds the lock or ctx is cancelled. The returned context
+// is cancelled when leadership is lost; release gives the lock up and must always be called.
+func (e *LeaderElector) Campaign(ctx context.Context) (context.Context, func(), error) {
+	for {
+		conn, err := e.tryLock(ctx)
+		if err != nil {
+			e.logger.Errorf("leader election: %v", err)
+		}
+		if conn != nil {
+			e.logger.Infof("leader election: acquired %s", e.name)
+			return e.hold(ctx, conn)
+		}
+
+		timer := time.NewTimer(campaignRetryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// tryLock returns a connection holding the lock, or nil if another replica holds it
+func (e *LeaderElector) tryLock(ctx context.Context) (*pgx.Conn, error) {
+	conn, err := pgx.Connect(ctx, e.dsn)
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+
+	var locked bool
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", e.name).Scan(&locked); err != nil {
+		conn.Close(context.Background())
+		return nil, fmt.Errorf("try advisory lock: %w", err)
+	}
+	if !locked {
+		conn.Close(context.Background())
+		return nil, nil
+	}
+	return conn, nil
+}
+
+// hold watches the lock connection and cancels the leader context once it is gone
+func (e *LeaderElector) hold(ctx context.Context, conn *pgx.Conn) (context.Context, func(), error) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(leaderCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-leaderCtx.Done():
+				return
+			case <-ticker.C:
+				if err := conn.Ping(leaderCtx); err != nil && leaderCtx.Err() == nil {
+					e.logger.Errorf("leader election: lost %s: %v", e.name, err)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	release := func() {
+		cancel()
+		<-done
+
+		unlockCtx, unlockCancel := context.WithTimeout(context.Background(), unlockTimeout)
+		defer unlockCancel()
+
+		// Closing the session drops the lock anyway; unlocking first hands over without waiting for the server to notice
+		if _, err := conn.Exec(unlockCtx, "SELECT pg_advisory_unlock(hashtext($1))", e.name); err != nil {
+			e.logger.Warnf("leader election: unlock %s: %v", e.name, err)
+		}
+		conn.Close(unlockCtx)
+		e.logger.Infof("leader election: released %s", e.name)
+	}
+
+	return leaderCtx, release, nil
+}
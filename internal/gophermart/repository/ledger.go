@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
+)
+
+// ledgerLine is one entry of a posting; userID 0 books it to a system account
+type ledgerLine struct {
+	userID  int64
+	account string
+	amount  model.Amount
+}
+
+func userLine(userID int64, amount model.Amount) ledgerLine {
+	return ledgerLine{userID: userID, account: model.LedgerAccountUser, amount: amount}
+}
+
+func systemLine(account string, amount model.Amount) ledgerLine {
+	return ledgerLine{account: account, amount: amount}
+}
+
+// ledgerRef links a posting to the order, withdrawal or transfer that caused it
+type ledgerRef struct {
+	orderNumber  string
+	withdrawalID int64
+	transferID   int64
+}
+
+// postLedger appends a posting within tx. The entries must sum to zero, so points are
+// only ever moved between accounts, never created or lost.
+func postLedger(ctx context.Context, tx *sql.Tx, entryType model.LedgerEntryType, ref ledgerRef, lines ...ledgerLine) error {
+	var total model.Amount
+	for _, line := range lines {
+		total += line.amount
+	}
+	if total != 0 {
+		return fmt.Errorf("unbalanced %s posting: entries sum to %d", entryType, total)
+	}
+
+	var postingID int64
+	if err := tx.QueryRowContext(ctx, "SELECT nextval('gophermart.ledger_postings_seq')").Scan(&postingID); err != nil {
+		return fmt.Errorf("failed to allocate ledger posting: %w", err)
+	}
+
+	for _, line := range lines {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO gophermart.ledger_entries
+				(posting_id, user_id, account, entry_type, amount, order_number, withdrawal_id, transfer_id)
+			VALUES ($1, NULLIF($2::bigint, 0), $3, $4, $5, NULLIF($6, ''), NULLIF($7::bigint, 0), NULLIF($8::bigint, 0))`,
+			postingID, line.userID, line.account, string(entryType), line.amount.Int64(),
+			ref.orderNumber, ref.withdrawalID, ref.transferID); err != nil {
+			return fmt.Errorf("failed to insert ledger entry: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// creditUser moves amount from the accruals account to the user and updates the balance
+// projection. A positive amount pays off the user's debt first; what is left starts a points
+// lot, except for the part that covers a negative balance. It takes the user lock itself, so a
+// caller that also updates other rows must lock the user before them.
+func creditUser(ctx context.Context, tx *sql.Tx, userID int64, entryType model.LedgerEntryType, ref ledgerRef, amount model.Amount) error {
+	return creditUserFrom(ctx, tx, userID, entryType, ref, amount, systemLine(model.LedgerAccountAccruals, -amount))
+}
+
+// creditUserFrom is creditUser with the amount taken by the source line instead of the
+// accruals account. Only the user's side of the projection is updated.
+func creditUserFrom(ctx context.Context, tx *sql.Tx, userID int64, entryType model.LedgerEntryType, ref ledgerRef, amount model.Amount, source ledgerLine) error {
+	if err := lockUser(ctx, tx, userID); err != nil {
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	var balance int64
+	if err := tx.QueryRowContext(ctx,
+		"SELECT balance FROM gophermart.users WHERE id = $1",
+		userID).Scan(&balance); err != nil {
+		return fmt.Errorf("failed to get user balance: %w", err)
+	}
+
+	if err := postLedger(ctx, tx, entryType, ref, userLine(userID, amount), source); err != nil {
+		return err
+	}
+	repay, err := repayDebt(ctx, tx, userID, ref, amount)
+	if err != nil {
+		return err
+	}
+
+	net := amount - repay
+	if lot := min(net, max(model.FromInt64(balance)+net, 0)); lot > 0 {
+		if err := addLot(ctx, tx, userID, lot, ref.orderNumber); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE gophermart.users SET balance = balance + $1 WHERE id = $2",
+		amount.Int64(), userID); err != nil {
+		return fmt.Errorf("failed to update user balance: %w", err)
+	}
+	return nil
+}
+
+// repayDebt pays the user's debt off from a credit of amount that is already posted, and
+// returns the part paid off. It updates the debt and takes the repayment off the balance
+// projection; the caller adds the credit itself. tx must hold the user lock.
+func repayDebt(ctx context.Context, tx *sql.Tx, userID int64, ref ledgerRef, amount model.Amount) (model.Amount, error) {
+	var debt int64
+	if err := tx.QueryRowContext(ctx,
+		"SELECT debt FROM gophermart.users WHERE id = $1",
+		userID).Scan(&debt); err != nil {
+		return 0, fmt.Errorf("failed to get user debt: %w", err)
+	}
+
+	repay := model.FromInt64(min(debt, max(amount.Int64(), 0)))
+	if repay == 0 {
+		return 0, nil
+	}
+	if err := postLedger(ctx, tx, model.LedgerEntryAdjustment, ref,
+		userLine(userID, -repay),
+		systemLine(model.LedgerAccountAccruals, repay)); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE gophermart.users SET balance = balance - $1, debt = debt - $1 WHERE id = $2",
+		repay.Int64(), userID); err != nil {
+		return 0, fmt.Errorf("failed to repay user debt: %w", err)
+	}
+	return repay, nil
+}
+
+// GetTransactions lists the entries of the user account, newest first. The running balance is
+// computed over the whole history before filtering, so it stays correct for a filtered page.
+func (r *PostgresRepository) GetTransactions(ctx context.Context, userID int64, filter model.TransactionFilter) ([]*model.Transaction, error) {
+	where := newWhereClause(userID)
+	types := make([]string, len(filter.Types))
+	for i, t := range filter.Types {
+		types[i] = string(t)
+	}
+	where.in("entry_type", types)
+	where.between("created_at", filter.From, filter.To)
+
+	query := `SELECT id, entry_type, amount, balance, COALESCE(order_number, ''), COALESCE(counterparty, ''), created_at
+		FROM (
+			SELECT e.id, e.entry_type, e.amount, e.order_number, e.created_at, u.login AS counterparty,
+				SUM(e.amount) OVER (ORDER BY e.created_at, e.id) AS balance
+			FROM gophermart.ledger_entries e
+			LEFT JOIN gophermart.transfers t ON t.id = e.transfer_id
+			LEFT JOIN gophermart.users u
+				ON u.id = CASE WHEN t.sender_id = e.user_id THEN t.recipient_id ELSE t.sender_id END
+			WHERE e.user_id = $1
+		) history` + where.String("WHERE") + " ORDER BY created_at DESC, id DESC"
+
+	rows, err := r.db.QueryContext(ctx, query, where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []*model.Transaction
+	for rows.Next() {
+		var (
+			t               model.Transaction
+			entryType       string
+			amount, balance int64
+		)
+		if err := rows.Scan(&t.ID, &entryType, &amount, &balance, &t.OrderNumber, &t.Counterparty, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		t.Type = model.LedgerEntryType(entryType)
+		t.Amount = model.FromInt64(amount)
+		t.Balance = model.FromInt64(balance)
+		transactions = append(transactions, &t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
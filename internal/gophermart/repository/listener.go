@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prbllm/go-loyalty-service/internal/logger"
+)
+
+// NewOrdersChannel is the NOTIFY channel CreateOrder publishes uploaded order numbers to
+const NewOrdersChannel = "gophermart_new_orders"
+
+const (
+	listenRetryMin = time.Second
+	listenRetryMax = 30 * time.Second
+)
+
+// OrderListener receives upload notifications on a dedicated connection outside the pool,
+// since LISTEN is bound to the session that issued it.
+type OrderListener struct {
+	dsn    string
+	logger logger.Logger
+}
+
+func NewOrderListener(dsn string, logger logger.Logger) *OrderListener {
+	return &OrderListener{
+		dsn:    dsn,
+		logger: logger,
+	}
+}
+
+// Listen calls onOrder with the number of every uploaded order until ctx is cancelled.
+// A lost connection is re-established with backoff; after every (re)connect onOrder is
+// called with an empty number, because uploads made while disconnected were not delivered.
+func (l *OrderListener) Listen(ctx context.Context, onOrder func(number string)) {
+	delay := listenRetryMin
+	for {
+		connected, err := l.listen(ctx, onOrder)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			delay = listenRetryMin
+		}
+		l.logger.Errorf("order listener: %v, reconnecting in %s", err, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		delay *= 2
+		if delay > listenRetryMax {
+			delay = listenRetryMax
+		}
+	}
+}
+
+func (l *OrderListener) listen(ctx context.Context, onOrder func(number string)) (bool, error) {
+	conn, err := pgx.Connect(ctx, l.dsn)
+	if err != nil {
+		return false, fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{NewOrdersChannel}.Sanitize()); err != nil {
+		return false, fmt.Errorf("listen: %w", err)
+	}
+	l.logger.Infof("order listener: listening on %s", NewOrdersChannel)
+	onOrder("")
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, fmt.Errorf("wait for notification: %w", err)
+		}
+		onOrder(notification.Payload)
+	}
+}
@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
+)
+
+// addLot records credited points so they can expire; must run in the transaction that credits them
+func addLot(ctx context.Context, tx *sql.Tx, userID int64, amount model.Amount, orderNumber string) error {
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO gophermart.points_lots (user_id, amount, remaining, order_number)
+		VALUES ($1, $2, $2, NULLIF($3, ''))`,
+		userID, amount.Int64(), orderNumber); err != nil {
+		return fmt.Errorf("failed to insert points lot: %w", err)
+	}
+	return nil
+}
+
+// consumeLots takes amount from the oldest lots of the user. withdrawalID, if set, records
+// which lots the withdrawal used. tx must hold the user lock.
+func consumeLots(ctx context.Context, tx *sql.Tx, userID, withdrawalID int64, amount model.Amount) error {
+	return consumeLotsBefore(ctx, tx, userID, withdrawalID, amount, nil)
+}
+
+// consumeLotsBefore is consumeLots limited to the lots earned before earnedBefore, if it is set
+func consumeLotsBefore(ctx context.Context, tx *sql.Tx, userID, withdrawalID int64, amount model.Amount, earnedBefore *time.Time) error {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, remaining FROM gophermart.points_lots
+		WHERE user_id = $1 AND remaining > 0 AND ($2::timestamptz IS NULL OR earned_at < $2)
+		ORDER BY earned_at, id
+		FOR UPDATE`,
+		userID, earnedBefore)
+	if err != nil {
+		return fmt.Errorf("failed to select points lots: %w", err)
+	}
+
+	type take struct {
+		lotID  int64
+		amount int64
+	}
+	var (
+		takes []take
+		left  = amount.Int64()
+	)
+	for left > 0 && rows.Next() {
+		var lotID, remaining int64
+		if err := rows.Scan(&lotID, &remaining); err != nil {
+			rows.Close()
+			return err
+		}
+		n := min(remaining, left)
+		takes = append(takes, take{lotID: lotID, amount: n})
+		left -= n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, t := range takes {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE gophermart.points_lots SET remaining = remaining - $2 WHERE id = $1",
+			t.lotID, t.amount); err != nil {
+			return fmt.Errorf("failed to consume points lot: %w", err)
+		}
+		if withdrawalID == 0 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO gophermart.lot_consumptions (lot_id, withdrawal_id, amount) VALUES ($1, $2, $3)",
+			t.lotID, withdrawalID, t.amount); err != nil {
+			return fmt.Errorf("failed to record lot consumption: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// restoreLots gives the points of a reversed withdrawal back to the lots it used. Points
+// withdrawn before lots were tracked go to a new lot.
+func restoreLots(ctx context.Context, tx *sql.Tx, userID, withdrawalID int64, amount model.Amount, orderNumber string) error {
+	var restored int64
+	if err := tx.QueryRowContext(ctx,
+		`WITH restored AS (
+			UPDATE gophermart.points_lots l SET remaining = l.remaining + c.amount
+			FROM gophermart.lot_consumptions c
+			WHERE c.lot_id = l.id AND c.withdrawal_id = $1
+			RETURNING c.amount
+		)
+		SELECT COALESCE(SUM(amount), 0) FROM restored`,
+		withdrawalID).Scan(&restored); err != nil {
+		return fmt.Errorf("failed to restore points lots: %w", err)
+	}
+
+	if missing := amount - model.FromInt64(restored); missing > 0 {
+		return addLot(ctx, tx, userID, missing, orderNumber)
+	}
+	return nil
+}
+
+// GetExpiringPoints returns the unused points of the user earned before earnedBefore
+func (r *PostgresRepository) GetExpiringPoints(ctx context.Context, userID int64, earnedBefore time.Time) (model.Amount, error) {
+	var amount int64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(remaining), 0) FROM gophermart.points_lots
+		WHERE user_id = $1 AND remaining > 0 AND earned_at < $2`,
+		userID, earnedBefore).Scan(&amount)
+	if err != nil {
+		return 0, err
+	}
+	return model.FromInt64(amount), nil
+}
+
+// ExpirePoints debits the unused points earned before earnedBefore, except those reserved by
+// active holds, one transaction per user, and returns the total expired
+func (r *PostgresRepository) ExpirePoints(ctx context.Context, earnedBefore time.Time) (model.Amount, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT DISTINCT user_id FROM gophermart.points_lots
+		WHERE remaining > 0 AND earned_at < $1`,
+		earnedBefore)
+	if err != nil {
+		return 0, err
+	}
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var total model.Amount
+	for _, userID := range userIDs {
+		expired, err := r.expireUserPoints(ctx, userID, earnedBefore)
+		if err != nil {
+			return total, fmt.Errorf("user %d: %w", userID, err)
+		}
+		total += expired
+	}
+	return total, nil
+}
+
+func (r *PostgresRepository) expireUserPoints(ctx context.Context, userID int64, earnedBefore time.Time) (model.Amount, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockUser(ctx, tx, userID); err != nil {
+		return 0, err
+	}
+
+	var expired int64
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(remaining), 0) FROM gophermart.points_lots
+		WHERE user_id = $1 AND remaining > 0 AND earned_at < $2`,
+		userID, earnedBefore).Scan(&expired); err != nil {
+		return 0, fmt.Errorf("failed to select expired points lots: %w", err)
+	}
+
+	// Holds do not take points from lots, so the points an active hold reserves stay unexpired
+	// until it is resolved: a capture spends the oldest lots, a release lets them expire next run
+	balance, err := ledgerBalance(ctx, tx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get balance: %w", err)
+	}
+	amount := min(model.FromInt64(expired), max(balance.Current, 0))
+	if amount == 0 {
+		return 0, tx.Commit()
+	}
+
+	if err := consumeLotsBefore(ctx, tx, userID, 0, amount, &earnedBefore); err != nil {
+		return 0, fmt.Errorf("failed to expire points lots: %w", err)
+	}
+	if err := postLedger(ctx, tx, model.LedgerEntryExpiration, ledgerRef{},
+		userLine(userID, -amount),
+		systemLine(model.LedgerAccountExpirations, amount)); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE gophermart.users SET balance = balance - $1 WHERE id = $2",
+		amount.Int64(), userID); err != nil {
+		return 0, fmt.Errorf("failed to update user balance: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return amount, nil
+}
@@ -32,6 +32,11 @@ var (
 	ErrUserAlreadyExists  = errors.New("user with this login already exists")
 	ErrOrderAlreadyExists = errors.New("order with this number already exists")
 	ErrInsufficientFunds  = errors.New("insufficient funds")
+	ErrOrderNotParked     = errors.New("order is not parked")
+
+	ErrWithdrawalExists   = errors.New("withdrawal for this order already exists")
+	ErrWithdrawalConflict = errors.New("order already has a withdrawal with a different sum")
+	ErrWithdrawalReversed = errors.New("withdrawal is already reversed")
 )
 
 type PostgresRepository struct {
@@ -44,7 +49,6 @@ type PostgresRepository struct {
 
 	createOrderStmt       *sql.Stmt
 	getOrderByNumberStmt  *sql.Stmt
-	getOrdersByUserIDStmt *sql.Stmt
 	getOrdersByStatusStmt *sql.Stmt
 }
 
@@ -141,15 +145,6 @@ func (r *PostgresRepository) prepareStatements(ctx context.Context) error {
 	r.getOrderByNumberStmt = getOrderByNumberStmt
 	prepared = append(prepared, getOrderByNumberStmt)
 
-	getOrdersByUserIDStmt, err := r.db.PrepareContext(ctx,
-		"SELECT id, user_id, number, status, accrual, uploaded_at FROM gophermart.orders WHERE user_id = $1 ORDER BY uploaded_at DESC")
-	if err != nil {
-		cleanup()
-		return fmt.Errorf("failed to prepare getOrdersByUserID statement: %w", err)
-	}
-	r.getOrdersByUserIDStmt = getOrdersByUserIDStmt
-	prepared = append(prepared, getOrdersByUserIDStmt)
-
 	getOrdersByStatusStmt, err := r.db.PrepareContext(ctx,
 		"SELECT id, user_id, number, status, accrual, uploaded_at FROM gophermart.orders WHERE status = $1 ORDER BY uploaded_at DESC")
 	if err != nil {
@@ -277,12 +272,6 @@ func (r *PostgresRepository) Close() error {
 		}
 	}
 
-	if r.getOrdersByUserIDStmt != nil {
-		if err := r.getOrdersByUserIDStmt.Close(); err != nil {
-			r.logger.Errorf("Failed to close getOrdersByUserID statement: %v", err)
-		}
-	}
-
 	if r.getOrdersByStatusStmt != nil {
 		if err := r.getOrdersByStatusStmt.Close(); err != nil {
 			r.logger.Errorf("Failed to close getOrdersByStatus statement: %v", err)
@@ -379,6 +368,11 @@ func (r *PostgresRepository) CreateOrder(ctx context.Context, userID int64, orde
 		return fmt.Errorf("failed to create order: %w", err)
 	}
 	r.logger.Debugf("Order created successfully with ID: %d, number: %s", id, orderNumber)
+
+	// Wake up pollers listening for uploads; the periodic poll picks the order up if this is lost
+	if _, err := r.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", NewOrdersChannel, orderNumber); err != nil {
+		r.logger.Warnf("Failed to notify about order %s: %v", orderNumber, err)
+	}
 	return nil
 }
 
@@ -407,8 +401,21 @@ func (r *PostgresRepository) GetOrderByNumber(ctx context.Context, orderNumber s
 	}, nil
 }
 
-func (r *PostgresRepository) GetOrdersByUserID(ctx context.Context, userID int64) ([]*model.Order, error) {
-	rows, err := r.getOrdersByUserIDStmt.QueryContext(ctx, userID)
+func (r *PostgresRepository) GetOrdersByUserID(ctx context.Context, userID int64, filter model.OrderFilter) ([]*model.Order, error) {
+	where := newWhereClause()
+	where.add("user_id = ?", userID)
+	statuses := make([]string, len(filter.Statuses))
+	for i, status := range filter.Statuses {
+		statuses[i] = string(status)
+	}
+	where.in("status", statuses)
+	where.between("uploaded_at", filter.From, filter.To)
+	where.after("uploaded_at", "id", filter.After)
+
+	query := "SELECT id, user_id, number, status, accrual, uploaded_at FROM gophermart.orders" +
+		where.String("WHERE") + " ORDER BY uploaded_at DESC, id DESC" + where.limit(filter.Limit)
+
+	rows, err := r.db.QueryContext(ctx, query, where.args...)
 	if err != nil {
 		return nil, err
 	}
@@ -465,6 +472,8 @@ func (r *PostgresRepository) GetOrdersByStatus(ctx context.Context, status model
 	return orders, nil
 }
 
+// UpdateOrderStatus stores an answer of the accrual system. For a processed order accrual is
+// credited to the user; for an unfinished one it is the expected accrual, 0 if unknown.
 func (r *PostgresRepository) UpdateOrderStatus(ctx context.Context, orderNumber string, status model.OrderStatus, accrual model.Amount) error {
 	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
@@ -472,54 +481,303 @@ func (r *PostgresRepository) UpdateOrderStatus(ctx context.Context, orderNumber
 	}
 	defer tx.Rollback()
 
-	var (
-		userID       int64
-		currentState string
-	)
+	userID, err := lockOrderUser(ctx, tx, orderNumber)
+	if err != nil {
+		return err
+	}
 
+	var currentState string
 	err = tx.QueryRowContext(ctx,
-		"SELECT user_id, status FROM gophermart.orders WHERE number = $1 FOR UPDATE",
-		orderNumber).Scan(&userID, &currentState)
+		"SELECT status FROM gophermart.orders WHERE number = $1 FOR UPDATE",
+		orderNumber).Scan(&currentState)
 	if err != nil {
 		return err
 	}
 
 	currentStatus := model.OrderStatus(currentState)
 	if currentStatus != model.OrderStatusProcessed && status == model.OrderStatusProcessed {
-		if _, err := tx.ExecContext(ctx,
-			"UPDATE gophermart.users SET balance = balance + $1 WHERE id = $2",
-			accrual.Int64(), userID); err != nil {
+		if err := creditUser(ctx, tx, userID, model.LedgerEntryAccrual, ledgerRef{orderNumber: orderNumber}, accrual); err != nil {
 			return fmt.Errorf("failed to add accrual to balance: %w", err)
 		}
 	}
 
+	// A successful answer from accrual resets the retry state of the order
 	if _, err := tx.ExecContext(ctx,
-		"UPDATE gophermart.orders SET status = $1, accrual = $2 WHERE number = $3",
-		string(status), accrual.Int64(), orderNumber); err != nil {
+		`UPDATE gophermart.orders
+		SET status = $1, poll_attempts = 0, next_poll_at = NOW(), last_poll_error = NULL,
+			lease_owner = NULL, lease_expires_at = NULL,
+			accrual = CASE WHEN $1 = $4 THEN $2::bigint ELSE 0 END,
+			expected_accrual = CASE WHEN $1 IN ($4, $5) OR $2::bigint = 0 THEN NULL ELSE $2::bigint END,
+			processed_at = CASE WHEN $1 = $4 THEN COALESCE(processed_at, NOW()) ELSE processed_at END
+		WHERE number = $3`,
+		string(status), accrual.Int64(), orderNumber, string(model.OrderStatusProcessed), string(model.OrderStatusInvalid)); err != nil {
 		return fmt.Errorf("failed to update order status: %w", err)
 	}
 
 	return tx.Commit()
 }
 
+const pollOrderColumns = "id, user_id, number, status, accrual, uploaded_at, poll_attempts, next_poll_at, COALESCE(last_poll_error, ''), parked_at, processed_at"
+
+// ClaimOrders leases a batch of due NEW and PROCESSING orders to owner, and of processed orders
+// due for a recheck when recheck.Window is set. Fresh orders go first.
+// Rows locked by a concurrent claim are skipped, and an order leased by another owner
+// is only claimed again after its lease expires, so replicas never poll the same order twice.
+func (r *PostgresRepository) ClaimOrders(ctx context.Context, owner string, limit int, lease time.Duration, recheck model.RecheckPolicy) ([]*model.Order, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`UPDATE gophermart.orders
+		SET lease_owner = $1, lease_expires_at = NOW() + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id FROM gophermart.orders
+			WHERE parked_at IS NULL AND (lease_expires_at IS NULL OR lease_expires_at <= NOW())
+				AND (
+					(status IN ($3, $4) AND next_poll_at <= NOW())
+					OR ($6 > 0 AND status = $7 AND processed_at > NOW() - make_interval(secs => $6)
+						AND next_poll_at <= NOW() - make_interval(secs => $8))
+				)
+			ORDER BY status = $7, next_poll_at
+			LIMIT $5
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+pollOrderColumns,
+		owner, lease.Seconds(), string(model.OrderStatusNew), string(model.OrderStatusProcessing), limit,
+		recheck.Window.Seconds(), string(model.OrderStatusProcessed), recheck.Interval.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPollOrders(rows)
+}
+
+func (r *PostgresRepository) ReleaseOrderLeases(ctx context.Context, owner string) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE gophermart.orders SET lease_owner = NULL, lease_expires_at = NULL WHERE lease_owner = $1",
+		owner)
+	if err != nil {
+		return fmt.Errorf("failed to release order leases: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ScheduleOrderRetry(ctx context.Context, orderNumber string, nextPollAt time.Time, lastError string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE gophermart.orders
+		SET poll_attempts = poll_attempts + 1, next_poll_at = $1, last_poll_error = $2,
+			lease_owner = NULL, lease_expires_at = NULL
+		WHERE number = $3`,
+		nextPollAt, lastError, orderNumber)
+	if err != nil {
+		return fmt.Errorf("failed to schedule order retry: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ParkOrder(ctx context.Context, orderNumber string, lastError string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE gophermart.orders
+		SET poll_attempts = poll_attempts + 1, last_poll_error = $1, parked_at = NOW(),
+			lease_owner = NULL, lease_expires_at = NULL
+		WHERE number = $2`,
+		lastError, orderNumber)
+	if err != nil {
+		return fmt.Errorf("failed to park order: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetParkedOrders(ctx context.Context) ([]*model.Order, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+pollOrderColumns+" FROM gophermart.orders WHERE parked_at IS NOT NULL ORDER BY parked_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPollOrders(rows)
+}
+
+func (r *PostgresRepository) RedriveOrder(ctx context.Context, orderNumber string) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE gophermart.orders
+		SET poll_attempts = 0, next_poll_at = NOW(), last_poll_error = NULL, parked_at = NULL,
+			lease_owner = NULL, lease_expires_at = NULL
+		WHERE number = $1 AND parked_at IS NOT NULL`,
+		orderNumber)
+	if err != nil {
+		return fmt.Errorf("failed to redrive order: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to redrive order: %w", err)
+	}
+	if affected == 0 {
+		return ErrOrderNotParked
+	}
+	return nil
+}
+
+// GetOrderForPoll returns the order with its poll state; sql.ErrNoRows if there is none
+func (r *PostgresRepository) GetOrderForPoll(ctx context.Context, orderNumber string) (*model.Order, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+pollOrderColumns+" FROM gophermart.orders WHERE number = $1", orderNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders, err := scanPollOrders(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return orders[0], nil
+}
+
+func (r *PostgresRepository) CountOrdersByStatus(ctx context.Context) (map[model.OrderStatus]int64, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT status, COUNT(*) FROM gophermart.orders GROUP BY status")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[model.OrderStatus]int64)
+	for rows.Next() {
+		var (
+			status string
+			count  int64
+		)
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[model.OrderStatus(status)] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+func scanPollOrders(rows *sql.Rows) ([]*model.Order, error) {
+	var orders []*model.Order
+	for rows.Next() {
+		var (
+			o           model.Order
+			accrual     int64
+			statusStr   string
+			parkedAt    sql.NullTime
+			processedAt sql.NullTime
+		)
+		if err := rows.Scan(&o.ID, &o.UserID, &o.Number, &statusStr, &accrual, &o.UploadedAt,
+			&o.PollAttempts, &o.NextPollAt, &o.LastPollError, &parkedAt, &processedAt); err != nil {
+			return nil, err
+		}
+		o.Status = model.OrderStatus(statusStr)
+		o.Accrual = model.FromInt64(accrual)
+		if parkedAt.Valid {
+			o.ParkedAt = &parkedAt.Time
+		}
+		if processedAt.Valid {
+			o.ProcessedAt = &processedAt.Time
+		}
+		orders = append(orders, &o)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// GetBalance derives the balance from the ledger; users.balance and users.withdrawn are
+// a projection kept in step with it for cheap reads.
 func (r *PostgresRepository) GetBalance(ctx context.Context, userID int64) (*model.Balance, error) {
-	var (
-		balance   model.Balance
-		current   int64
-		withdrawn int64
-	)
+	var exists bool
+	if err := r.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM gophermart.users WHERE id = $1)", userID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
 
-	err := r.db.QueryRowContext(ctx,
-		"SELECT balance, withdrawn FROM gophermart.users WHERE id = $1",
-		userID).Scan(&current, &withdrawn)
+	balance, err := ledgerBalance(ctx, r.db, userID)
 	if err != nil {
 		return nil, err
 	}
+	if err := r.addOrderSummary(ctx, userID, balance); err != nil {
+		return nil, err
+	}
+	return balance, nil
+}
 
-	balance.Current = model.FromInt64(current)
-	balance.Withdrawn = model.FromInt64(withdrawn)
+// addOrderSummary counts the orders of the user by status and sums the expected accrual of
+// the unfinished ones in a single aggregate query
+func (r *PostgresRepository) addOrderSummary(ctx context.Context, userID int64, balance *model.Balance) error {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT status, COUNT(*), COALESCE(SUM(expected_accrual), 0), COUNT(*) - COUNT(expected_accrual)
+		FROM gophermart.orders
+		WHERE user_id = $1
+		GROUP BY status`,
+		userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
 
-	return &balance, nil
+	balance.Orders = make(map[model.OrderStatus]int64)
+	for rows.Next() {
+		var (
+			status                   string
+			count, expected, unknown int64
+		)
+		if err := rows.Scan(&status, &count, &expected, &unknown); err != nil {
+			return err
+		}
+		balance.Orders[model.OrderStatus(status)] = count
+
+		switch model.OrderStatus(status) {
+		case model.OrderStatusNew, model.OrderStatusProcessing:
+			balance.Pending += model.FromInt64(expected)
+			balance.PendingUnknown += unknown
+		}
+	}
+
+	return rows.Err()
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func ledgerBalance(ctx context.Context, q queryRower, userID int64) (*model.Balance, error) {
+	var current, withdrawn, held, debt int64
+	err := q.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(amount), 0),
+			COALESCE(-SUM(amount) FILTER (WHERE entry_type IN ($2, $3)), 0),
+			(SELECT COALESCE(SUM(amount), 0) FROM gophermart.balance_holds
+				WHERE user_id = $1 AND status = $4 AND expires_at > NOW()),
+			(SELECT COALESCE(MAX(debt), 0) FROM gophermart.users WHERE id = $1)
+		FROM gophermart.ledger_entries
+		WHERE user_id = $1`,
+		userID, string(model.LedgerEntryWithdrawal), string(model.LedgerEntryReversal),
+		string(model.HoldStatusHeld)).Scan(&current, &withdrawn, &held, &debt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Balance{
+		Current:   model.FromInt64(current - held),
+		Withdrawn: model.FromInt64(withdrawn),
+		Held:      model.FromInt64(held),
+		Debt:      model.FromInt64(debt),
+	}, nil
 }
 
 func (r *PostgresRepository) WithdrawBalance(ctx context.Context, userID int64, orderNumber string, amount model.Amount) error {
@@ -529,45 +787,102 @@ func (r *PostgresRepository) WithdrawBalance(ctx context.Context, userID int64,
 	}
 	defer tx.Rollback()
 
+	if err := lockUser(ctx, tx, userID); err != nil {
+		return err
+	}
+	if _, err := withdraw(ctx, tx, userID, orderNumber, amount); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockUser takes the user row FOR UPDATE; it serialises balance changes of the user.
+// Every transaction that changes a balance locks the user first, then the rows it touches.
+func lockUser(ctx context.Context, tx *sql.Tx, userID int64) error {
+	var lockedID int64
+	return tx.QueryRowContext(ctx,
+		"SELECT id FROM gophermart.users WHERE id = $1 FOR UPDATE", userID).Scan(&lockedID)
+}
+
+// lockOrderUser locks the owner of the order, so that the order row can be locked after it
+func lockOrderUser(ctx context.Context, tx *sql.Tx, orderNumber string) (int64, error) {
+	var userID int64
+	if err := tx.QueryRowContext(ctx,
+		"SELECT user_id FROM gophermart.orders WHERE number = $1",
+		orderNumber).Scan(&userID); err != nil {
+		return 0, err
+	}
+	return userID, lockUser(ctx, tx, userID)
+}
+
+// withdraw debits amount for the order within tx, which must hold the user lock
+func withdraw(ctx context.Context, tx *sql.Tx, userID int64, orderNumber string, amount model.Amount) (int64, error) {
+	// One withdrawal per order: a repeat of it is a retry unless the withdrawal has been
+	// reversed since, anything else is a conflict
 	var (
-		balance   int64
-		withdrawn int64
+		existing   int64
+		reversedAt *time.Time
 	)
-
-	err = tx.QueryRowContext(ctx,
-		"SELECT balance, withdrawn FROM gophermart.users WHERE id = $1 FOR UPDATE",
-		userID).Scan(&balance, &withdrawn)
+	err := tx.QueryRowContext(ctx,
+		"SELECT sum, reversed_at FROM gophermart.balance_transactions WHERE user_id = $1 AND order_number = $2",
+		userID, orderNumber).Scan(&existing, &reversedAt)
+	switch {
+	case err == nil && reversedAt != nil:
+		return 0, ErrWithdrawalReversed
+	case err == nil && model.FromInt64(existing) == amount:
+		return 0, ErrWithdrawalExists
+	case err == nil:
+		return 0, ErrWithdrawalConflict
+	case !errors.Is(err, sql.ErrNoRows):
+		return 0, fmt.Errorf("failed to check existing withdrawal: %w", err)
+	}
+
+	balance, err := ledgerBalance(ctx, tx, userID)
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("failed to get balance: %w", err)
 	}
-
-	balanceAmount := model.FromInt64(balance)
-	if balanceAmount < amount {
-		return ErrInsufficientFunds
+	if balance.Current < amount {
+		return 0, ErrInsufficientFunds
 	}
 
-	newBalance := balanceAmount - amount
-	newWithdrawn := model.FromInt64(withdrawn) + amount
+	var withdrawalID int64
+	if err := tx.QueryRowContext(ctx,
+		"INSERT INTO gophermart.balance_transactions (user_id, order_number, sum) VALUES ($1, $2, $3) RETURNING id",
+		userID, orderNumber, amount.Int64()).Scan(&withdrawalID); err != nil {
+		return 0, fmt.Errorf("failed to insert balance transaction: %w", err)
+	}
 
-	if _, err := tx.ExecContext(ctx,
-		"UPDATE gophermart.users SET balance = $1, withdrawn = $2 WHERE id = $3",
-		newBalance.Int64(), newWithdrawn.Int64(), userID); err != nil {
-		return fmt.Errorf("failed to update user balance: %w", err)
+	ref := ledgerRef{orderNumber: orderNumber, withdrawalID: withdrawalID}
+	if err := postLedger(ctx, tx, model.LedgerEntryWithdrawal, ref,
+		userLine(userID, -amount),
+		systemLine(model.LedgerAccountRedemptions, amount)); err != nil {
+		return 0, err
+	}
+	// Oldest points are spent first
+	if err := consumeLots(ctx, tx, userID, withdrawalID, amount); err != nil {
+		return 0, err
 	}
 
 	if _, err := tx.ExecContext(ctx,
-		"INSERT INTO gophermart.balance_transactions (user_id, order_number, sum) VALUES ($1, $2, $3)",
-		userID, orderNumber, amount.Int64()); err != nil {
-		return fmt.Errorf("failed to insert balance transaction: %w", err)
+		"UPDATE gophermart.users SET balance = balance - $1, withdrawn = withdrawn + $1 WHERE id = $2",
+		amount.Int64(), userID); err != nil {
+		return 0, fmt.Errorf("failed to update user balance: %w", err)
 	}
 
-	return tx.Commit()
+	return withdrawalID, nil
 }
 
-func (r *PostgresRepository) GetWithdrawals(ctx context.Context, userID int64) ([]*model.Withdrawal, error) {
-	rows, err := r.db.QueryContext(ctx,
-		"SELECT order_number, sum, processed_at FROM gophermart.balance_transactions WHERE user_id = $1 ORDER BY processed_at DESC",
-		userID)
+func (r *PostgresRepository) GetWithdrawals(ctx context.Context, userID int64, filter model.WithdrawalFilter) ([]*model.Withdrawal, error) {
+	where := newWhereClause()
+	where.add("user_id = ?", userID)
+	where.between("processed_at", filter.From, filter.To)
+	where.after("processed_at", "id", filter.After)
+
+	query := "SELECT id, order_number, sum, processed_at, reversed_at, COALESCE(reversal_reason, '') FROM gophermart.balance_transactions" +
+		where.String("WHERE") + " ORDER BY processed_at DESC, id DESC" + where.limit(filter.Limit)
+
+	rows, err := r.db.QueryContext(ctx, query, where.args...)
 	if err != nil {
 		return nil, err
 	}
@@ -579,7 +894,7 @@ func (r *PostgresRepository) GetWithdrawals(ctx context.Context, userID int64) (
 			w   model.Withdrawal
 			sum int64
 		)
-		if err := rows.Scan(&w.OrderNumber, &sum, &w.ProcessedAt); err != nil {
+		if err := rows.Scan(&w.ID, &w.OrderNumber, &sum, &w.ProcessedAt, &w.ReversedAt, &w.ReversalReason); err != nil {
 			return nil, err
 		}
 		w.Sum = model.FromInt64(sum)
@@ -593,13 +908,85 @@ func (r *PostgresRepository) GetWithdrawals(ctx context.Context, userID int64) (
 	return withdrawals, nil
 }
 
+// ReverseWithdrawal credits the sum of a withdrawal back to the user, e.g. for a cancelled purchase.
+// It returns sql.ErrNoRows if the user has no withdrawal for the order.
+func (r *PostgresRepository) ReverseWithdrawal(ctx context.Context, userID int64, orderNumber, reason string) (*model.Withdrawal, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockUser(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	var (
+		w   = model.Withdrawal{OrderNumber: orderNumber}
+		sum int64
+	)
+	if err := tx.QueryRowContext(ctx,
+		`SELECT id, sum, processed_at, reversed_at FROM gophermart.balance_transactions
+		WHERE user_id = $1 AND order_number = $2`,
+		userID, orderNumber).Scan(&w.ID, &sum, &w.ProcessedAt, &w.ReversedAt); err != nil {
+		return nil, err
+	}
+	if w.ReversedAt != nil {
+		return nil, ErrWithdrawalReversed
+	}
+	w.Sum = model.FromInt64(sum)
+
+	ref := ledgerRef{orderNumber: orderNumber, withdrawalID: w.ID}
+	if err := postLedger(ctx, tx, model.LedgerEntryReversal, ref,
+		userLine(userID, w.Sum),
+		systemLine(model.LedgerAccountRedemptions, -w.Sum)); err != nil {
+		return nil, err
+	}
+	// The refund pays a debt off first, like any other credit
+	repay, err := repayDebt(ctx, tx, userID, ref, w.Sum)
+	if err != nil {
+		return nil, err
+	}
+	if err := restoreLots(ctx, tx, userID, w.ID, w.Sum, orderNumber); err != nil {
+		return nil, err
+	}
+	if repay > 0 {
+		if err := consumeLots(ctx, tx, userID, 0, repay); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.QueryRowContext(ctx,
+		`UPDATE gophermart.balance_transactions SET reversed_at = NOW(), reversal_reason = NULLIF($2, '')
+		WHERE id = $1 RETURNING reversed_at`,
+		w.ID, reason).Scan(&w.ReversedAt); err != nil {
+		return nil, fmt.Errorf("failed to mark withdrawal reversed: %w", err)
+	}
+	w.ReversalReason = reason
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE gophermart.users SET balance = balance + $1, withdrawn = withdrawn - $1 WHERE id = $2",
+		w.Sum.Int64(), userID); err != nil {
+		return nil, fmt.Errorf("failed to update user balance: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// AddAccrual credits points that are not tied to an order as an adjustment
 func (r *PostgresRepository) AddAccrual(ctx context.Context, userID int64, amount model.Amount) error {
-	_, err := r.db.ExecContext(ctx,
-		"UPDATE gophermart.users SET balance = balance + $1 WHERE id = $2",
-		amount.Int64(), userID)
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := creditUser(ctx, tx, userID, model.LedgerEntryAdjustment, ledgerRef{}, amount); err != nil {
 		return fmt.Errorf("failed to add accrual: %w", err)
 	}
 
-	return nil
+	return tx.Commit()
 }
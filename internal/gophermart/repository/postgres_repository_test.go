@@ -4,10 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"os"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
 
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/stretchr/testify/assert"
@@ -72,6 +76,100 @@ func setupTestDB(t *testing.T) (*PostgresRepository, func()) {
 	return repo.(*PostgresRepository), cleanup
 }
 
+func TestMigrations_ResolveDuplicateWithdrawals(t *testing.T) {
+	ctx := context.Background()
+
+	adminConfig, err := pgx.ParseConfig(defaultTestAdminDSN)
+	require.NoError(t, err, "Failed to parse admin DSN")
+	adminDB := stdlib.OpenDB(*adminConfig)
+	defer adminDB.Close()
+	require.NoError(t, adminDB.PingContext(ctx), "Failed to connect to admin database")
+
+	const dbName = "loyalty_migration_test"
+	_, err = adminDB.ExecContext(ctx, "DROP DATABASE IF EXISTS "+dbName+" WITH (FORCE)")
+	require.NoError(t, err)
+	_, err = adminDB.ExecContext(ctx, "CREATE DATABASE "+dbName)
+	require.NoError(t, err)
+
+	dbConfig := adminConfig.Copy()
+	dbConfig.Database = dbName
+	db := stdlib.OpenDB(*dbConfig)
+
+	driver, err := postgres.WithInstance(db, &postgres.Config{MigrationsTable: "schema_migrations_gophermart"})
+	require.NoError(t, err)
+	migrationsPath, err := getMigrationsPath()
+	require.NoError(t, err)
+	m, err := migrate.NewWithDatabaseInstance(migrationsPath, "postgres", driver)
+	require.NoError(t, err)
+	defer func() {
+		m.Close()
+		db.Close()
+		if _, err := adminDB.ExecContext(ctx, "DROP DATABASE IF EXISTS "+dbName+" WITH (FORCE)"); err != nil {
+			t.Logf("Failed to drop migration test database: %v", err)
+		}
+	}()
+
+	// Before the ledger and the unique index a retried request could withdraw twice for an order
+	require.NoError(t, m.Migrate(5))
+	var userID int64
+	require.NoError(t, db.QueryRowContext(ctx,
+		`INSERT INTO gophermart.users (login, password_hash, balance, withdrawn)
+		VALUES ('duplicate_user', '$2a$10$duplicatehash', 2000, 3000) RETURNING id`).Scan(&userID))
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO gophermart.orders (user_id, number, status, accrual) VALUES ($1, 'duplicate_accrual', 'PROCESSED', 5000)`,
+		userID)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO gophermart.balance_transactions (user_id, order_number, sum, processed_at) VALUES
+		($1, 'duplicate_order', 1000, NOW() - INTERVAL '2 hours'),
+		($1, 'duplicate_order', 1000, NOW() - INTERVAL '1 hour'),
+		($1, 'single_order', 1000, NOW())`,
+		userID)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Up())
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT order_number, reversed_at IS NOT NULL FROM gophermart.balance_transactions
+		WHERE user_id = $1 ORDER BY id`,
+		userID)
+	require.NoError(t, err)
+	type withdrawal struct {
+		orderNumber string
+		reversed    bool
+	}
+	var withdrawals []withdrawal
+	for rows.Next() {
+		var w withdrawal
+		require.NoError(t, rows.Scan(&w.orderNumber, &w.reversed))
+		withdrawals = append(withdrawals, w)
+	}
+	require.NoError(t, rows.Err())
+	rows.Close()
+	require.Len(t, withdrawals, 3)
+	assert.Equal(t, withdrawal{"duplicate_order", false}, withdrawals[0])
+	assert.True(t, withdrawals[1].reversed)
+	assert.NotEqual(t, "duplicate_order", withdrawals[1].orderNumber)
+	assert.Equal(t, withdrawal{"single_order", false}, withdrawals[2])
+
+	// The duplicate is credited back in the ledger and the projection follows it
+	var ledgerSum, balance, withdrawn int64
+	require.NoError(t, db.QueryRowContext(ctx,
+		`SELECT (SELECT SUM(amount) FROM gophermart.ledger_entries WHERE user_id = $1), balance, withdrawn
+		FROM gophermart.users WHERE id = $1`,
+		userID).Scan(&ledgerSum, &balance, &withdrawn))
+	assert.Equal(t, int64(3000), ledgerSum)
+	assert.Equal(t, int64(3000), balance)
+	assert.Equal(t, int64(2000), withdrawn)
+
+	var unbalanced int
+	require.NoError(t, db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM (
+			SELECT posting_id FROM gophermart.ledger_entries GROUP BY posting_id HAVING SUM(amount) <> 0
+		) p`).Scan(&unbalanced))
+	assert.Zero(t, unbalanced)
+}
+
 func TestCreateUser_Success(t *testing.T) {
 	repo, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -256,14 +354,14 @@ func TestGetOrdersByUserID(t *testing.T) {
 
 	require.NoError(t, repo.CreateOrder(ctx, otherUserID, "other_order"))
 
-	orders, err := repo.GetOrdersByUserID(ctx, userID)
+	orders, err := repo.GetOrdersByUserID(ctx, userID, model.OrderFilter{})
 	require.NoError(t, err)
 
 	require.Len(t, orders, 3)
 	receivedNumbers := []string{orders[0].Number, orders[1].Number, orders[2].Number}
 	assert.ElementsMatch(t, numbers, receivedNumbers)
 
-	orders, err = repo.GetOrdersByUserID(ctx, 99999)
+	orders, err = repo.GetOrdersByUserID(ctx, 99999, model.OrderFilter{})
 	require.NoError(t, err)
 	assert.Len(t, orders, 0)
 }
@@ -296,6 +394,175 @@ func TestGetOrdersByStatus(t *testing.T) {
 	assert.Equal(t, model.Amount(1050), ordersProcessed[0].Accrual)
 }
 
+func TestOrderPollRetryAndPark(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID, err := repo.CreateUser(ctx, "poll_user", "$2a$10$pollhash")
+	require.NoError(t, err)
+
+	require.NoError(t, repo.CreateOrder(ctx, userID, "poll_order1"))
+	require.NoError(t, repo.CreateOrder(ctx, userID, "poll_order2"))
+
+	due, err := repo.ClaimOrders(ctx, "owner-a", 10, time.Minute, model.RecheckPolicy{})
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+
+	// Leased orders are not handed out again until released or expired
+	claimed, err := repo.ClaimOrders(ctx, "owner-b", 10, time.Minute, model.RecheckPolicy{})
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	err = repo.ScheduleOrderRetry(ctx, "poll_order1", time.Now().Add(time.Hour), "order not registered")
+	require.NoError(t, err)
+	require.NoError(t, repo.ParkOrder(ctx, "poll_order2", "order not registered"))
+
+	due, err = repo.ClaimOrders(ctx, "owner-a", 10, time.Minute, model.RecheckPolicy{})
+	require.NoError(t, err)
+	assert.Empty(t, due)
+
+	parked, err := repo.GetParkedOrders(ctx)
+	require.NoError(t, err)
+	require.Len(t, parked, 1)
+	assert.Equal(t, "poll_order2", parked[0].Number)
+	assert.Equal(t, 1, parked[0].PollAttempts)
+	assert.Equal(t, "order not registered", parked[0].LastPollError)
+	assert.NotNil(t, parked[0].ParkedAt)
+
+	require.NoError(t, repo.RedriveOrder(ctx, "poll_order2"))
+	assert.ErrorIs(t, repo.RedriveOrder(ctx, "poll_order1"), ErrOrderNotParked)
+
+	due, err = repo.ClaimOrders(ctx, "owner-a", 10, time.Minute, model.RecheckPolicy{})
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, "poll_order2", due[0].Number)
+	assert.Equal(t, 0, due[0].PollAttempts)
+}
+
+func TestClaimOrders_ExpiredLeaseAndRelease(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID, err := repo.CreateUser(ctx, "lease_user", "$2a$10$leasehash")
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateOrder(ctx, userID, "lease_order"))
+
+	claimed, err := repo.ClaimOrders(ctx, "owner-a", 10, time.Millisecond, model.RecheckPolicy{})
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+
+	time.Sleep(10 * time.Millisecond)
+
+	claimed, err = repo.ClaimOrders(ctx, "owner-b", 10, time.Minute, model.RecheckPolicy{})
+	require.NoError(t, err)
+	require.Len(t, claimed, 1, "expired lease must be claimable by another owner")
+
+	require.NoError(t, repo.ReleaseOrderLeases(ctx, "owner-b"))
+
+	claimed, err = repo.ClaimOrders(ctx, "owner-a", 10, time.Minute, model.RecheckPolicy{})
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+}
+
+func TestGetOrderForPollAndCountByStatus(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID, err := repo.CreateUser(ctx, "count_user", "$2a$10$counthash")
+	require.NoError(t, err)
+
+	require.NoError(t, repo.CreateOrder(ctx, userID, "count_order1"))
+	require.NoError(t, repo.CreateOrder(ctx, userID, "count_order2"))
+	require.NoError(t, repo.UpdateOrderStatus(ctx, "count_order2", model.OrderStatusProcessed, model.FromFloat64(1)))
+	require.NoError(t, repo.ScheduleOrderRetry(ctx, "count_order1", time.Now().Add(time.Hour), "boom"))
+
+	order, err := repo.GetOrderForPoll(ctx, "count_order1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, order.PollAttempts)
+	assert.Equal(t, "boom", order.LastPollError)
+
+	_, err = repo.GetOrderForPoll(ctx, "missing_order")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	counts, err := repo.CountOrdersByStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), counts[model.OrderStatusNew])
+	assert.Equal(t, int64(1), counts[model.OrderStatusProcessed])
+}
+
+func TestOrderListener_NotifiedOnCreateOrder(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dsn := os.Getenv("TEST_DATABASE_URI")
+	if dsn == "" {
+		dsn = defaultTestDSN
+	}
+
+	numbers := make(chan string, 10)
+	listener := NewOrderListener(dsn, zaptest.NewLogger(t).Sugar())
+	go listener.Listen(ctx, func(number string) { numbers <- number })
+
+	// An empty number is sent once the listener is subscribed
+	select {
+	case number := <-numbers:
+		require.Empty(t, number)
+	case <-time.After(5 * time.Second):
+		t.Fatal("listener did not connect")
+	}
+
+	userID, err := repo.CreateUser(ctx, "listener_user", "$2a$10$listenerhash")
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateOrder(ctx, userID, "listener_order"))
+
+	select {
+	case number := <-numbers:
+		assert.Equal(t, "listener_order", number)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification for the created order")
+	}
+}
+
+func TestLeaderElector_SingleLeader(t *testing.T) {
+	_, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	dsn := os.Getenv("TEST_DATABASE_URI")
+	if dsn == "" {
+		dsn = defaultTestDSN
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := NewLeaderElector(dsn, "test_leader_lock", zaptest.NewLogger(t).Sugar())
+	second := NewLeaderElector(dsn, "test_leader_lock", zaptest.NewLogger(t).Sugar())
+
+	leaderCtx, release, err := first.Campaign(ctx)
+	require.NoError(t, err)
+	require.NoError(t, leaderCtx.Err())
+
+	// The second replica stays a follower while the lock is held
+	followerCtx, followerCancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer followerCancel()
+	_, _, err = second.Campaign(followerCtx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	release()
+	require.Error(t, leaderCtx.Err(), "leader context must end on release")
+
+	secondCtx, secondRelease, err := second.Campaign(ctx)
+	require.NoError(t, err)
+	require.NoError(t, secondCtx.Err())
+	secondRelease()
+}
+
 func TestUpdateOrderStatus_AddsAccrualOnce(t *testing.T) {
 	repo, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -389,6 +656,51 @@ func TestWithdrawBalance(t *testing.T) {
 	assert.Equal(t, int64(1000), dbSum)
 }
 
+func TestLedger_PostingsBalanceAndMatchProjection(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID, err := repo.CreateUser(ctx, "ledger_user", "$2a$10$ledgerhash")
+	require.NoError(t, err)
+
+	require.NoError(t, repo.CreateOrder(ctx, userID, "ledger_order"))
+	require.NoError(t, repo.UpdateOrderStatus(ctx, "ledger_order", model.OrderStatusProcessed, model.FromFloat64(40)))
+	require.NoError(t, repo.AddAccrual(ctx, userID, model.FromFloat64(5)))
+	require.NoError(t, repo.WithdrawBalance(ctx, userID, "ledger_wd", model.FromFloat64(15)))
+
+	// Every posting sums to zero
+	var unbalanced int
+	err = repo.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM (
+			SELECT posting_id FROM gophermart.ledger_entries GROUP BY posting_id HAVING SUM(amount) <> 0
+		) p`).Scan(&unbalanced)
+	require.NoError(t, err)
+	assert.Zero(t, unbalanced)
+
+	var accruals, withdrawals int
+	err = repo.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FILTER (WHERE entry_type = 'ACCRUAL' AND order_number = 'ledger_order'),
+			COUNT(*) FILTER (WHERE entry_type = 'WITHDRAWAL' AND withdrawal_id IS NOT NULL)
+		FROM gophermart.ledger_entries WHERE user_id = $1`, userID).Scan(&accruals, &withdrawals)
+	require.NoError(t, err)
+	assert.Equal(t, 1, accruals)
+	assert.Equal(t, 1, withdrawals)
+
+	balance, err := repo.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(30), balance.Current)
+	assert.Equal(t, model.FromFloat64(15), balance.Withdrawn)
+
+	user, err := repo.GetUserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, balance.Current, user.Balance)
+	assert.Equal(t, balance.Withdrawn, user.Withdrawn)
+
+	_, err = repo.db.ExecContext(ctx, "DELETE FROM gophermart.ledger_entries WHERE user_id = $1", userID)
+	assert.Error(t, err, "ledger entries must be append-only")
+}
+
 func TestWithdrawBalance_InsufficientFunds(t *testing.T) {
 	repo, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -414,7 +726,7 @@ func TestGetWithdrawals(t *testing.T) {
 	require.NoError(t, repo.WithdrawBalance(ctx, userID, "wd_order_a", model.FromFloat64(10)))
 	require.NoError(t, repo.WithdrawBalance(ctx, userID, "wd_order_b", model.FromFloat64(5)))
 
-	withdrawals, err := repo.GetWithdrawals(ctx, userID)
+	withdrawals, err := repo.GetWithdrawals(ctx, userID, model.WithdrawalFilter{})
 	require.NoError(t, err)
 	require.Len(t, withdrawals, 2)
 	assert.Equal(t, "wd_order_b", withdrawals[0].OrderNumber)
@@ -422,3 +734,683 @@ func TestGetWithdrawals(t *testing.T) {
 	assert.Equal(t, "wd_order_a", withdrawals[1].OrderNumber)
 	assert.Equal(t, model.Amount(1000), withdrawals[1].Sum)
 }
+
+func TestGetTransactions(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID, err := repo.CreateUser(ctx, "transactions_user", "$2a$10$transactionshash")
+	require.NoError(t, err)
+
+	require.NoError(t, repo.CreateOrder(ctx, userID, "tx_order"))
+	require.NoError(t, repo.UpdateOrderStatus(ctx, "tx_order", model.OrderStatusProcessed, model.FromFloat64(30)))
+	require.NoError(t, repo.WithdrawBalance(ctx, userID, "tx_wd", model.FromFloat64(10)))
+	require.NoError(t, repo.AddAccrual(ctx, userID, model.FromFloat64(2)))
+
+	all, err := repo.GetTransactions(ctx, userID, model.TransactionFilter{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, model.LedgerEntryAdjustment, all[0].Type)
+	assert.Equal(t, model.FromFloat64(22), all[0].Balance)
+	assert.Equal(t, model.LedgerEntryWithdrawal, all[1].Type)
+	assert.Equal(t, model.FromFloat64(-10), all[1].Amount)
+	assert.Equal(t, "tx_wd", all[1].OrderNumber)
+	assert.Equal(t, model.FromFloat64(20), all[1].Balance)
+	assert.Equal(t, model.LedgerEntryAccrual, all[2].Type)
+	assert.Equal(t, "tx_order", all[2].OrderNumber)
+
+	// The running balance covers the whole history even when filtered
+	withdrawals, err := repo.GetTransactions(ctx, userID, model.TransactionFilter{
+		Types: []model.LedgerEntryType{model.LedgerEntryWithdrawal},
+	})
+	require.NoError(t, err)
+	require.Len(t, withdrawals, 1)
+	assert.Equal(t, model.FromFloat64(20), withdrawals[0].Balance)
+
+	future, err := repo.GetTransactions(ctx, userID, model.TransactionFilter{From: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Empty(t, future)
+}
+
+func TestListPagination(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID, err := repo.CreateUser(ctx, "paging_user", "$2a$10$paginghash")
+	require.NoError(t, err)
+
+	for _, number := range []string{"page_a", "page_b", "page_c"} {
+		require.NoError(t, repo.CreateOrder(ctx, userID, number))
+	}
+	require.NoError(t, repo.UpdateOrderStatus(ctx, "page_b", model.OrderStatusInvalid, 0))
+
+	first, err := repo.GetOrdersByUserID(ctx, userID, model.OrderFilter{Page: model.Page{Limit: 2}})
+	require.NoError(t, err)
+	require.Len(t, first, 2)
+	assert.Equal(t, "page_c", first[0].Number)
+	assert.Equal(t, "page_b", first[1].Number)
+
+	cursor := model.Cursor{Time: first[1].UploadedAt, ID: first[1].ID}
+	second, err := repo.GetOrdersByUserID(ctx, userID, model.OrderFilter{Page: model.Page{Limit: 2, After: &cursor}})
+	require.NoError(t, err)
+	require.Len(t, second, 1)
+	assert.Equal(t, "page_a", second[0].Number)
+
+	invalid, err := repo.GetOrdersByUserID(ctx, userID, model.OrderFilter{Statuses: []model.OrderStatus{model.OrderStatusInvalid}})
+	require.NoError(t, err)
+	require.Len(t, invalid, 1)
+	assert.Equal(t, "page_b", invalid[0].Number)
+
+	require.NoError(t, repo.AddAccrual(ctx, userID, model.FromFloat64(10)))
+	require.NoError(t, repo.WithdrawBalance(ctx, userID, "page_wd_a", model.FromFloat64(1)))
+	require.NoError(t, repo.WithdrawBalance(ctx, userID, "page_wd_b", model.FromFloat64(1)))
+
+	withdrawals, err := repo.GetWithdrawals(ctx, userID, model.WithdrawalFilter{Page: model.Page{Limit: 1}})
+	require.NoError(t, err)
+	require.Len(t, withdrawals, 1)
+	assert.Equal(t, "page_wd_b", withdrawals[0].OrderNumber)
+
+	cursor = model.Cursor{Time: withdrawals[0].ProcessedAt, ID: withdrawals[0].ID}
+	withdrawals, err = repo.GetWithdrawals(ctx, userID, model.WithdrawalFilter{Page: model.Page{After: &cursor}})
+	require.NoError(t, err)
+	require.Len(t, withdrawals, 1)
+	assert.Equal(t, "page_wd_a", withdrawals[0].OrderNumber)
+
+	future, err := repo.GetWithdrawals(ctx, userID, model.WithdrawalFilter{From: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Empty(t, future)
+}
+
+func TestWithdrawBalance_OncePerOrder(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID, err := repo.CreateUser(ctx, "once_user", "$2a$10$oncehash")
+	require.NoError(t, err)
+	require.NoError(t, repo.AddAccrual(ctx, userID, model.FromFloat64(20)))
+
+	require.NoError(t, repo.WithdrawBalance(ctx, userID, "once_order", model.FromFloat64(10)))
+	assert.ErrorIs(t, repo.WithdrawBalance(ctx, userID, "once_order", model.FromFloat64(10)), ErrWithdrawalExists)
+	assert.ErrorIs(t, repo.WithdrawBalance(ctx, userID, "once_order", model.FromFloat64(5)), ErrWithdrawalConflict)
+
+	balance, err := repo.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(10), balance.Current)
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID, err := repo.CreateUser(ctx, "idempotency_user", "$2a$10$idempotencyhash")
+	require.NoError(t, err)
+
+	rec, err := repo.ReserveIdempotencyKey(ctx, userID, "key", "fp")
+	require.NoError(t, err)
+	assert.Nil(t, rec)
+
+	rec, err = repo.ReserveIdempotencyKey(ctx, userID, "key", "fp")
+	require.NoError(t, err)
+	require.NotNil(t, rec)
+	assert.Zero(t, rec.StatusCode, "first request is still in progress")
+
+	require.NoError(t, repo.CompleteIdempotencyKey(ctx, userID, "key", 402, "application/json", []byte(`{"error":"insufficient funds"}`)))
+	rec, err = repo.ReserveIdempotencyKey(ctx, userID, "key", "other")
+	require.NoError(t, err)
+	require.NotNil(t, rec)
+	assert.Equal(t, "fp", rec.Fingerprint)
+	assert.Equal(t, 402, rec.StatusCode)
+	assert.Equal(t, "application/json", rec.ContentType)
+	assert.JSONEq(t, `{"error":"insufficient funds"}`, string(rec.Body))
+
+	require.NoError(t, repo.ReleaseIdempotencyKey(ctx, userID, "key"))
+	rec, err = repo.ReserveIdempotencyKey(ctx, userID, "key", "fp")
+	require.NoError(t, err)
+	assert.Nil(t, rec)
+}
+
+func TestReverseWithdrawal(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID, err := repo.CreateUser(ctx, "reversal_user", "$2a$10$reversalhash")
+	require.NoError(t, err)
+	require.NoError(t, repo.AddAccrual(ctx, userID, model.FromFloat64(20)))
+	require.NoError(t, repo.WithdrawBalance(ctx, userID, "reversal_order", model.FromFloat64(15)))
+
+	_, err = repo.ReverseWithdrawal(ctx, userID, "missing_order", "")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	w, err := repo.ReverseWithdrawal(ctx, userID, "reversal_order", "purchase cancelled")
+	require.NoError(t, err)
+	require.NotNil(t, w.ReversedAt)
+	assert.Equal(t, model.FromFloat64(15), w.Sum)
+
+	_, err = repo.ReverseWithdrawal(ctx, userID, "reversal_order", "")
+	assert.ErrorIs(t, err, ErrWithdrawalReversed)
+
+	// A retry of the reversed withdrawal must not pass for a successful one
+	assert.ErrorIs(t, repo.WithdrawBalance(ctx, userID, "reversal_order", model.FromFloat64(15)), ErrWithdrawalReversed)
+
+	balance, err := repo.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(20), balance.Current)
+	assert.Equal(t, model.Amount(0), balance.Withdrawn)
+
+	user, err := repo.GetUserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, balance.Current, user.Balance)
+	assert.Equal(t, balance.Withdrawn, user.Withdrawn)
+
+	withdrawals, err := repo.GetWithdrawals(ctx, userID, model.WithdrawalFilter{})
+	require.NoError(t, err)
+	require.Len(t, withdrawals, 1)
+	require.NotNil(t, withdrawals[0].ReversedAt)
+	assert.Equal(t, "purchase cancelled", withdrawals[0].ReversalReason)
+
+	transactions, err := repo.GetTransactions(ctx, userID, model.TransactionFilter{
+		Types: []model.LedgerEntryType{model.LedgerEntryReversal},
+	})
+	require.NoError(t, err)
+	require.Len(t, transactions, 1)
+	assert.Equal(t, model.FromFloat64(15), transactions[0].Amount)
+	assert.Equal(t, "reversal_order", transactions[0].OrderNumber)
+}
+
+func TestBalanceHolds(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID, err := repo.CreateUser(ctx, "hold_user", "$2a$10$holdhash")
+	require.NoError(t, err)
+	require.NoError(t, repo.AddAccrual(ctx, userID, model.FromFloat64(20)))
+
+	hold, err := repo.HoldBalance(ctx, userID, "hold_a", model.FromFloat64(15), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, model.HoldStatusHeld, hold.Status)
+
+	_, err = repo.HoldBalance(ctx, userID, "hold_a", model.FromFloat64(1), time.Hour)
+	assert.ErrorIs(t, err, ErrHoldConflict)
+
+	balance, err := repo.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(5), balance.Current)
+	assert.Equal(t, model.FromFloat64(15), balance.Held)
+
+	// Held points cannot be spent elsewhere
+	assert.ErrorIs(t, repo.WithdrawBalance(ctx, userID, "hold_other", model.FromFloat64(10)), ErrInsufficientFunds)
+
+	captured, err := repo.CaptureHold(ctx, userID, hold.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.HoldStatusCaptured, captured.Status)
+	assert.NotZero(t, captured.WithdrawalID)
+
+	_, err = repo.ReleaseHold(ctx, userID, hold.ID)
+	assert.ErrorIs(t, err, ErrHoldNotActive)
+
+	balance, err = repo.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(5), balance.Current)
+	assert.Equal(t, model.Amount(0), balance.Held)
+	assert.Equal(t, model.FromFloat64(15), balance.Withdrawn)
+
+	released, err := repo.HoldBalance(ctx, userID, "hold_b", model.FromFloat64(5), time.Hour)
+	require.NoError(t, err)
+	released, err = repo.ReleaseHold(ctx, userID, released.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.HoldStatusReleased, released.Status)
+
+	_, err = repo.CaptureHold(ctx, userID, 999999)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// An expired hold stops reserving points before the sweeper records it
+	expired, err := repo.HoldBalance(ctx, userID, "hold_c", model.FromFloat64(5), time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+
+	balance, err = repo.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(5), balance.Current)
+
+	_, err = repo.CaptureHold(ctx, userID, expired.ID)
+	assert.ErrorIs(t, err, ErrHoldNotActive)
+
+	n, err := repo.ExpireHolds(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+func TestPointsExpiry(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID, err := repo.CreateUser(ctx, "expiry_user", "$2a$10$expiryhash")
+	require.NoError(t, err)
+	require.NoError(t, repo.AddAccrual(ctx, userID, model.FromFloat64(10)))
+	require.NoError(t, repo.AddAccrual(ctx, userID, model.FromFloat64(20)))
+
+	// The first credit was earned two years ago
+	_, err = repo.db.ExecContext(ctx,
+		`UPDATE gophermart.points_lots SET earned_at = NOW() - INTERVAL '2 years'
+		WHERE id = (SELECT MIN(id) FROM gophermart.points_lots WHERE user_id = $1)`,
+		userID)
+	require.NoError(t, err)
+
+	// Withdrawals spend the oldest points first
+	require.NoError(t, repo.WithdrawBalance(ctx, userID, "expiry_order", model.FromFloat64(4)))
+
+	cutoff := time.Now().AddDate(-1, 0, 0)
+	expiring, err := repo.GetExpiringPoints(ctx, userID, cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(6), expiring)
+
+	expired, err := repo.ExpirePoints(ctx, cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(6), expired)
+
+	balance, err := repo.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(20), balance.Current)
+	assert.Equal(t, model.FromFloat64(4), balance.Withdrawn)
+
+	// Nothing is left to expire twice
+	expired, err = repo.ExpirePoints(ctx, cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, model.Amount(0), expired)
+
+	// A reversal returns the points to the expired lot, where they stay expired
+	_, err = repo.ReverseWithdrawal(ctx, userID, "expiry_order", "")
+	require.NoError(t, err)
+	expired, err = repo.ExpirePoints(ctx, cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(4), expired)
+
+	balance, err = repo.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(20), balance.Current)
+}
+
+func TestPointsExpiry_ActiveHold(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID, err := repo.CreateUser(ctx, "expiry_hold_user", "$2a$10$expiryholdhash")
+	require.NoError(t, err)
+	require.NoError(t, repo.AddAccrual(ctx, userID, model.FromFloat64(10)))
+
+	_, err = repo.db.ExecContext(ctx,
+		"UPDATE gophermart.points_lots SET earned_at = NOW() - INTERVAL '2 years' WHERE user_id = $1",
+		userID)
+	require.NoError(t, err)
+
+	captured, err := repo.HoldBalance(ctx, userID, "expiry_hold_a", model.FromFloat64(5), time.Hour)
+	require.NoError(t, err)
+	released, err := repo.HoldBalance(ctx, userID, "expiry_hold_b", model.FromFloat64(3), time.Hour)
+	require.NoError(t, err)
+
+	// Only the points the holds leave available expire
+	cutoff := time.Now().AddDate(-1, 0, 0)
+	expired, err := repo.ExpirePoints(ctx, cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(2), expired)
+
+	balance, err := repo.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.Amount(0), balance.Current)
+	assert.Equal(t, model.FromFloat64(8), balance.Held)
+
+	_, err = repo.CaptureHold(ctx, userID, captured.ID)
+	require.NoError(t, err)
+
+	// A released hold gives its points back to be expired
+	_, err = repo.ReleaseHold(ctx, userID, released.ID)
+	require.NoError(t, err)
+	expired, err = repo.ExpirePoints(ctx, cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(3), expired)
+
+	balance, err = repo.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.Amount(0), balance.Current)
+	assert.Equal(t, model.FromFloat64(5), balance.Withdrawn)
+
+	user, err := repo.GetUserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, balance.Current, user.Balance)
+}
+
+func TestTransfer(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	aliceID, err := repo.CreateUser(ctx, "transfer_alice", "$2a$10$alicehash")
+	require.NoError(t, err)
+	bobID, err := repo.CreateUser(ctx, "transfer_bob", "$2a$10$bobhash")
+	require.NoError(t, err)
+	require.NoError(t, repo.AddAccrual(ctx, aliceID, model.FromFloat64(50)))
+
+	limits := model.TransferLimits{DailySum: model.FromFloat64(30), DailyCount: 2}
+
+	transfer, err := repo.Transfer(ctx, aliceID, "transfer_bob", model.FromFloat64(20), limits)
+	require.NoError(t, err)
+	assert.Equal(t, bobID, transfer.RecipientID)
+
+	_, err = repo.Transfer(ctx, aliceID, "transfer_alice", model.FromFloat64(1), limits)
+	assert.ErrorIs(t, err, ErrTransferToSelf)
+	_, err = repo.Transfer(ctx, aliceID, "transfer_nobody", model.FromFloat64(1), limits)
+	assert.ErrorIs(t, err, ErrRecipientNotFound)
+	_, err = repo.Transfer(ctx, aliceID, "transfer_bob", model.FromFloat64(15), limits)
+	assert.ErrorIs(t, err, ErrTransferLimitExceeded)
+	_, err = repo.Transfer(ctx, bobID, "transfer_alice", model.FromFloat64(25), model.TransferLimits{})
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// Opposite transfers lock the users in the same order and do not deadlock
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 5; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := repo.Transfer(ctx, aliceID, "transfer_bob", model.FromFloat64(1), model.TransferLimits{})
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := repo.Transfer(ctx, bobID, "transfer_alice", model.FromFloat64(1), model.TransferLimits{})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	alice, err := repo.GetBalance(ctx, aliceID)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(30), alice.Current)
+	assert.Equal(t, model.Amount(0), alice.Withdrawn)
+	bob, err := repo.GetBalance(ctx, bobID)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(20), bob.Current)
+
+	history, err := repo.GetTransactions(ctx, bobID, model.TransactionFilter{Types: []model.LedgerEntryType{model.LedgerEntryTransfer}})
+	require.NoError(t, err)
+	require.Len(t, history, 11)
+	assert.Equal(t, "transfer_alice", history[0].Counterparty)
+}
+
+func TestTransfer_RepaysRecipientDebt(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	senderID, err := repo.CreateUser(ctx, "transfer_debt_sender", "$2a$10$senderhash")
+	require.NoError(t, err)
+	recipientID, err := repo.CreateUser(ctx, "transfer_debt_recipient", "$2a$10$recipienthash")
+	require.NoError(t, err)
+	require.NoError(t, repo.AddAccrual(ctx, senderID, model.FromFloat64(20)))
+
+	_, err = repo.db.ExecContext(ctx, "UPDATE gophermart.users SET debt = $1 WHERE id = $2",
+		model.FromFloat64(5).Int64(), recipientID)
+	require.NoError(t, err)
+
+	_, err = repo.Transfer(ctx, senderID, "transfer_debt_recipient", model.FromFloat64(8), model.TransferLimits{})
+	require.NoError(t, err)
+
+	recipient, err := repo.GetBalance(ctx, recipientID)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(3), recipient.Current)
+	assert.Equal(t, model.Amount(0), recipient.Debt)
+	expiring, err := repo.GetExpiringPoints(ctx, recipientID, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(3), expiring)
+
+	sender, err := repo.GetBalance(ctx, senderID)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(12), sender.Current)
+
+	for _, id := range []int64{senderID, recipientID} {
+		user, err := repo.GetUserByID(ctx, id)
+		require.NoError(t, err)
+		balance, err := repo.GetBalance(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, balance.Current, user.Balance)
+	}
+}
+
+func TestRecheckOrder_ClawBack(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      model.NegativeBalancePolicy
+		wantBalance model.Amount
+		wantDebt    model.Amount
+		wantAfter   model.Amount // balance after a later accrual of 15
+	}{
+		{"allow", model.NegativeBalanceAllow, model.FromFloat64(-10), 0, model.FromFloat64(5)},
+		{"floor", model.NegativeBalanceFloor, 0, 0, model.FromFloat64(15)},
+		{"debt", model.NegativeBalanceDebt, 0, model.FromFloat64(10), model.FromFloat64(5)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, cleanup := setupTestDB(t)
+			defer cleanup()
+
+			ctx := context.Background()
+			userID, err := repo.CreateUser(ctx, "recheck_user", "$2a$10$recheckhash")
+			require.NoError(t, err)
+			require.NoError(t, repo.CreateOrder(ctx, userID, "recheck_order"))
+			require.NoError(t, repo.UpdateOrderStatus(ctx, "recheck_order", model.OrderStatusProcessed, model.FromFloat64(40)))
+			require.NoError(t, repo.WithdrawBalance(ctx, userID, "recheck_spent", model.FromFloat64(30)))
+
+			// Half the goods were returned: 20 of the 40 points come back, 10 more than the balance
+			diff, err := repo.RecheckOrder(ctx, "recheck_order", model.FromFloat64(20), tt.policy)
+			require.NoError(t, err)
+			assert.Equal(t, model.FromFloat64(-20), diff)
+
+			balance, err := repo.GetBalance(ctx, userID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantBalance, balance.Current)
+			assert.Equal(t, tt.wantDebt, balance.Debt)
+
+			// The next accrual pays the debt or the negative balance off first
+			require.NoError(t, repo.AddAccrual(ctx, userID, model.FromFloat64(15)))
+			balance, err = repo.GetBalance(ctx, userID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAfter, balance.Current)
+			assert.Equal(t, model.Amount(0), balance.Debt)
+
+			user, err := repo.GetUserByID(ctx, userID)
+			require.NoError(t, err)
+			assert.Equal(t, balance.Current, user.Balance)
+
+			order, err := repo.GetOrderByNumber(ctx, "recheck_order")
+			require.NoError(t, err)
+			assert.Equal(t, model.FromFloat64(20), order.Accrual)
+		})
+	}
+}
+
+func TestRecheckOrder_ClawBackWithActiveHold(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID, err := repo.CreateUser(ctx, "recheck_hold_user", "$2a$10$recheckholdhash")
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateOrder(ctx, userID, "recheck_hold_order"))
+	require.NoError(t, repo.UpdateOrderStatus(ctx, "recheck_hold_order", model.OrderStatusProcessed, model.FromFloat64(40)))
+	hold, err := repo.HoldBalance(ctx, userID, "recheck_hold_purchase", model.FromFloat64(30), time.Hour)
+	require.NoError(t, err)
+
+	// Only the 10 points outside the hold cover the claw-back of 20
+	_, err = repo.RecheckOrder(ctx, "recheck_hold_order", model.FromFloat64(20), model.NegativeBalanceFloor)
+	require.NoError(t, err)
+
+	balance, err := repo.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.Amount(0), balance.Current)
+	assert.Equal(t, model.FromFloat64(30), balance.Held)
+
+	_, err = repo.CaptureHold(ctx, userID, hold.ID)
+	require.NoError(t, err)
+
+	balance, err = repo.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.Amount(0), balance.Current)
+	assert.Equal(t, model.FromFloat64(30), balance.Withdrawn)
+
+	user, err := repo.GetUserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, balance.Current, user.Balance)
+}
+
+func TestRecheckOrder_DebtRepaidByAccrual(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID, err := repo.CreateUser(ctx, "recheck_debt_user", "$2a$10$recheckdebthash")
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateOrder(ctx, userID, "recheck_debt_order"))
+	require.NoError(t, repo.UpdateOrderStatus(ctx, "recheck_debt_order", model.OrderStatusProcessed, model.FromFloat64(40)))
+	require.NoError(t, repo.WithdrawBalance(ctx, userID, "recheck_debt_spent", model.FromFloat64(30)))
+
+	_, err = repo.RecheckOrder(ctx, "recheck_debt_order", model.FromFloat64(20), model.NegativeBalanceDebt)
+	require.NoError(t, err)
+
+	// The accrual of the next order pays the debt off before anything reaches the balance
+	require.NoError(t, repo.CreateOrder(ctx, userID, "recheck_debt_next"))
+	require.NoError(t, repo.UpdateOrderStatus(ctx, "recheck_debt_next", model.OrderStatusProcessed, model.FromFloat64(25)))
+
+	balance, err := repo.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(15), balance.Current)
+	assert.Equal(t, model.Amount(0), balance.Debt)
+
+	user, err := repo.GetUserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, balance.Current, user.Balance)
+
+	// Only what is left after the repayment can expire
+	expiring, err := repo.GetExpiringPoints(ctx, userID, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(15), expiring)
+
+	adjustments, err := repo.GetTransactions(ctx, userID, model.TransactionFilter{
+		Types: []model.LedgerEntryType{model.LedgerEntryAdjustment},
+	})
+	require.NoError(t, err)
+	byOrder := make(map[string]model.Amount)
+	for _, a := range adjustments {
+		byOrder[a.OrderNumber] += a.Amount
+	}
+	assert.Equal(t, map[string]model.Amount{
+		"recheck_debt_order": model.FromFloat64(-10),
+		"recheck_debt_next":  model.FromFloat64(-10),
+	}, byOrder)
+}
+
+func TestReverseWithdrawal_RepaysDebt(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID, err := repo.CreateUser(ctx, "reversal_debt_user", "$2a$10$reversaldebthash")
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateOrder(ctx, userID, "reversal_debt_order"))
+	require.NoError(t, repo.UpdateOrderStatus(ctx, "reversal_debt_order", model.OrderStatusProcessed, model.FromFloat64(40)))
+	require.NoError(t, repo.WithdrawBalance(ctx, userID, "reversal_debt_spent", model.FromFloat64(30)))
+
+	_, err = repo.RecheckOrder(ctx, "reversal_debt_order", model.FromFloat64(20), model.NegativeBalanceDebt)
+	require.NoError(t, err)
+
+	// The refund of 30 pays the debt of 10 off first
+	_, err = repo.ReverseWithdrawal(ctx, userID, "reversal_debt_spent", "")
+	require.NoError(t, err)
+
+	balance, err := repo.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(20), balance.Current)
+	assert.Equal(t, model.Amount(0), balance.Debt)
+	assert.Equal(t, model.Amount(0), balance.Withdrawn)
+
+	user, err := repo.GetUserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, balance.Current, user.Balance)
+
+	expiring, err := repo.GetExpiringPoints(ctx, userID, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(20), expiring)
+}
+
+func TestClaimOrders_Recheck(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID, err := repo.CreateUser(ctx, "claim_recheck_user", "$2a$10$claimrecheckhash")
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateOrder(ctx, userID, "claim_recheck_done"))
+	require.NoError(t, repo.UpdateOrderStatus(ctx, "claim_recheck_done", model.OrderStatusProcessed, model.FromFloat64(5)))
+
+	// Without a window processed orders are never claimed
+	claimed, err := repo.ClaimOrders(ctx, "owner-a", 10, time.Minute, model.RecheckPolicy{})
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	// Within the window an order is rechecked once the interval since the last answer passed
+	claimed, err = repo.ClaimOrders(ctx, "owner-a", 10, time.Minute, model.RecheckPolicy{Window: time.Hour, Interval: time.Hour})
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	claimed, err = repo.ClaimOrders(ctx, "owner-a", 10, time.Minute, model.RecheckPolicy{Window: time.Hour, Interval: time.Millisecond})
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, model.OrderStatusProcessed, claimed[0].Status)
+}
+
+func TestGetBalance_PendingAndOrderCounts(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	userID, err := repo.CreateUser(ctx, "pending_user", "$2a$10$pendinghash")
+	require.NoError(t, err)
+	for _, number := range []string{"pending_new", "pending_estimated", "pending_unknown", "pending_done", "pending_invalid"} {
+		require.NoError(t, repo.CreateOrder(ctx, userID, number))
+	}
+	require.NoError(t, repo.UpdateOrderStatus(ctx, "pending_estimated", model.OrderStatusProcessing, model.FromFloat64(7.5)))
+	require.NoError(t, repo.UpdateOrderStatus(ctx, "pending_unknown", model.OrderStatusProcessing, 0))
+	require.NoError(t, repo.UpdateOrderStatus(ctx, "pending_done", model.OrderStatusProcessed, model.FromFloat64(10)))
+	require.NoError(t, repo.UpdateOrderStatus(ctx, "pending_invalid", model.OrderStatusInvalid, 0))
+
+	balance, err := repo.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.FromFloat64(10), balance.Current)
+	assert.Equal(t, model.FromFloat64(7.5), balance.Pending)
+	assert.Equal(t, int64(2), balance.PendingUnknown)
+	assert.Equal(t, map[model.OrderStatus]int64{
+		model.OrderStatusNew:        1,
+		model.OrderStatusProcessing: 2,
+		model.OrderStatusProcessed:  1,
+		model.OrderStatusInvalid:    1,
+	}, balance.Orders)
+
+	// The estimate is dropped once the order is processed
+	require.NoError(t, repo.UpdateOrderStatus(ctx, "pending_estimated", model.OrderStatusProcessed, model.FromFloat64(8)))
+	balance, err = repo.GetBalance(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, model.Amount(0), balance.Pending)
+	assert.Equal(t, model.FromFloat64(18), balance.Current)
+}
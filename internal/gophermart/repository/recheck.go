@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
+)
+
+// RecheckOrder stores a new accrual of a processed order and applies the difference to the
+// user's balance as an adjustment; a lowered accrual is clawed back according to policy.
+// It returns the difference, or sql.ErrNoRows if the order is not processed.
+func (r *PostgresRepository) RecheckOrder(ctx context.Context, orderNumber string, accrual model.Amount, policy model.NegativeBalancePolicy) (model.Amount, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	userID, err := lockOrderUser(ctx, tx, orderNumber)
+	if err != nil {
+		return 0, err
+	}
+
+	var current int64
+	if err := tx.QueryRowContext(ctx,
+		"SELECT accrual FROM gophermart.orders WHERE number = $1 AND status = $2 FOR UPDATE",
+		orderNumber, string(model.OrderStatusProcessed)).Scan(&current); err != nil {
+		return 0, err
+	}
+
+	ref := ledgerRef{orderNumber: orderNumber}
+	diff := accrual - model.FromInt64(current)
+	switch {
+	case diff > 0:
+		if err := creditUser(ctx, tx, userID, model.LedgerEntryAdjustment, ref, diff); err != nil {
+			return 0, fmt.Errorf("failed to credit accrual increase: %w", err)
+		}
+	case diff < 0:
+		if err := r.clawBack(ctx, tx, userID, ref, -diff, policy); err != nil {
+			return 0, fmt.Errorf("failed to claw back accrual: %w", err)
+		}
+	}
+
+	// For a processed order next_poll_at is when accrual last answered about it
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE gophermart.orders
+		SET accrual = $1, poll_attempts = 0, next_poll_at = NOW(), last_poll_error = NULL,
+			lease_owner = NULL, lease_expires_at = NULL
+		WHERE number = $2`,
+		accrual.Int64(), orderNumber); err != nil {
+		return 0, fmt.Errorf("failed to update order accrual: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return diff, nil
+}
+
+// clawBack debits amount of a lowered accrual. Unless policy allows a negative balance, only
+// what the balance covers is debited; the rest is written off or, with the debt policy,
+// recorded as debt that later credits pay off.
+func (r *PostgresRepository) clawBack(ctx context.Context, tx *sql.Tx, userID int64, ref ledgerRef, amount model.Amount, policy model.NegativeBalancePolicy) error {
+	if err := lockUser(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	// Points reserved by active holds are not available to cover the claw-back
+	balance, err := ledgerBalance(ctx, tx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get balance: %w", err)
+	}
+
+	debit := amount
+	if policy != model.NegativeBalanceAllow {
+		debit = min(amount, max(balance.Current, 0))
+	}
+	rest := amount - debit
+
+	if debit > 0 {
+		if err := postLedger(ctx, tx, model.LedgerEntryAdjustment, ref,
+			userLine(userID, -debit),
+			systemLine(model.LedgerAccountAccruals, debit)); err != nil {
+			return err
+		}
+		if err := consumeLots(ctx, tx, userID, 0, debit); err != nil {
+			return err
+		}
+	}
+
+	var debt model.Amount
+	if rest > 0 {
+		if policy == model.NegativeBalanceFloor {
+			r.logger.Warnf("order %s: %.2f clawed back points exceed the balance of user %d and are written off",
+				ref.orderNumber, rest.ToFloat64(), userID)
+		} else {
+			debt = rest
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE gophermart.users SET balance = balance - $1, debt = debt + $2 WHERE id = $3",
+		debit.Int64(), debt.Int64(), userID); err != nil {
+		return fmt.Errorf("failed to update user balance: %w", err)
+	}
+	return nil
+}
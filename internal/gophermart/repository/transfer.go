@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
+)
+
+var (
+	ErrRecipientNotFound     = errors.New("recipient not found")
+	ErrTransferToSelf        = errors.New("cannot transfer points to yourself")
+	ErrTransferLimitExceeded = errors.New("daily transfer limit exceeded")
+)
+
+// Transfer moves amount from the sender to the user with recipientLogin. Both users are locked
+// in id order, so opposite transfers between the same users cannot deadlock.
+func (r *PostgresRepository) Transfer(ctx context.Context, senderID int64, recipientLogin string, amount model.Amount, limits model.TransferLimits) (*model.Transfer, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var recipientID int64
+	err = tx.QueryRowContext(ctx,
+		"SELECT id FROM gophermart.users WHERE login = $1", recipientLogin).Scan(&recipientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRecipientNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find recipient: %w", err)
+	}
+	if recipientID == senderID {
+		return nil, ErrTransferToSelf
+	}
+
+	first, second := senderID, recipientID
+	if second < first {
+		first, second = second, first
+	}
+	for _, id := range []int64{first, second} {
+		err := lockUser(ctx, tx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecipientNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The recipient was looked up before the locks; make sure the login still names them
+	var found bool
+	if err := tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM gophermart.users WHERE id = $1 AND login = $2)",
+		recipientID, recipientLogin).Scan(&found); err != nil {
+		return nil, fmt.Errorf("failed to check recipient: %w", err)
+	}
+	if !found {
+		return nil, ErrRecipientNotFound
+	}
+
+	if err := checkTransferLimits(ctx, tx, senderID, amount, limits); err != nil {
+		return nil, err
+	}
+
+	balance, err := ledgerBalance(ctx, tx, senderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance: %w", err)
+	}
+	if balance.Current < amount {
+		return nil, ErrInsufficientFunds
+	}
+
+	t := &model.Transfer{
+		SenderID:       senderID,
+		RecipientID:    recipientID,
+		RecipientLogin: recipientLogin,
+		Amount:         amount,
+	}
+	if err := tx.QueryRowContext(ctx,
+		`INSERT INTO gophermart.transfers (sender_id, recipient_id, amount)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`,
+		senderID, recipientID, amount.Int64()).Scan(&t.ID, &t.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to insert transfer: %w", err)
+	}
+
+	// For the recipient the points are newly earned: they pay off a debt first, like any credit
+	if err := creditUserFrom(ctx, tx, recipientID, model.LedgerEntryTransfer, ledgerRef{transferID: t.ID},
+		amount, userLine(senderID, -amount)); err != nil {
+		return nil, err
+	}
+	// The sender gives away the oldest points
+	if err := consumeLots(ctx, tx, senderID, 0, amount); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE gophermart.users SET balance = balance - $1 WHERE id = $2",
+		amount.Int64(), senderID); err != nil {
+		return nil, fmt.Errorf("failed to update sender balance: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// checkTransferLimits checks the transfers the sender made in the last 24 hours; tx must hold the sender lock
+func checkTransferLimits(ctx context.Context, tx *sql.Tx, senderID int64, amount model.Amount, limits model.TransferLimits) error {
+	if limits.DailySum <= 0 && limits.DailyCount <= 0 {
+		return nil
+	}
+
+	var count, sum int64
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*), COALESCE(SUM(amount), 0) FROM gophermart.transfers
+		WHERE sender_id = $1 AND created_at > NOW() - INTERVAL '1 day'`,
+		senderID).Scan(&count, &sum); err != nil {
+		return fmt.Errorf("failed to sum recent transfers: %w", err)
+	}
+
+	if limits.DailyCount > 0 && count >= int64(limits.DailyCount) {
+		return ErrTransferLimitExceeded
+	}
+	if limits.DailySum > 0 && model.FromInt64(sum)+amount > limits.DailySum {
+		return ErrTransferLimitExceeded
+	}
+	return nil
+}
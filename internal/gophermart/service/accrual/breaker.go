@@ -0,0 +1,177 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prbllm/go-loyalty-service/internal/config"
+	"github.com/prbllm/go-loyalty-service/internal/logger"
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+var ErrCircuitOpen = errors.New("accrual circuit breaker is open")
+
+type BreakerSettings struct {
+	FailureThreshold int           // consecutive failures that open the breaker
+	OpenTimeout      time.Duration // how long the breaker stays open before a probe request
+}
+
+func DefaultBreakerSettings() BreakerSettings {
+	return BreakerSettings{
+		FailureThreshold: config.DefaultBreakerFailureThreshold,
+		OpenTimeout:      config.DefaultBreakerOpenTimeout,
+	}
+}
+
+// CircuitBreaker stops calling the accrual service after repeated failures.
+// Closed: requests pass and consecutive failures are counted.
+// Open: requests fail fast with ErrCircuitOpen until OpenTimeout passes.
+// Half-open: a single probe request is let through; its outcome closes or reopens the breaker,
+// and a probe cancelled by its caller leaves it half-open.
+type CircuitBreaker struct {
+	client   Client
+	settings BreakerSettings
+	logger   logger.Logger
+	now      func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func NewCircuitBreaker(client Client, settings BreakerSettings, logger logger.Logger) *CircuitBreaker {
+	defaults := DefaultBreakerSettings()
+	if settings.FailureThreshold <= 0 {
+		settings.FailureThreshold = defaults.FailureThreshold
+	}
+	if settings.OpenTimeout <= 0 {
+		settings.OpenTimeout = defaults.OpenTimeout
+	}
+
+	return &CircuitBreaker{
+		client:   client,
+		settings: settings,
+		logger:   logger,
+		now:      time.Now,
+		state:    BreakerClosed,
+	}
+}
+
+func (b *CircuitBreaker) GetOrder(ctx context.Context, number string) (*Response, error) {
+	if !b.acquire() {
+		return nil, ErrCircuitOpen
+	}
+
+	resp, err := b.client.GetOrder(ctx, number)
+	b.record(ctx, err)
+	return resp, err
+}
+
+// State returns the current state; an open breaker whose timeout has passed reports half-open.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.settings.OpenTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// Ready reports whether a request would be let through, so the poller does not claim orders it cannot send.
+func (b *CircuitBreaker) Ready() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		return true
+	case BreakerOpen:
+		return b.now().Sub(b.openedAt) >= b.settings.OpenTimeout
+	default:
+		return !b.probing
+	}
+}
+
+func (b *CircuitBreaker) acquire() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		return true
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.settings.OpenTimeout {
+			return false
+		}
+		b.setState(BreakerHalfOpen)
+	}
+
+	if b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *CircuitBreaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasProbe := b.state == BreakerHalfOpen && b.probing
+	if wasProbe {
+		b.probing = false
+	}
+
+	// A call cut short by its own context says nothing about the accrual service:
+	// a cancelled probe leaves the breaker half-open for the next one
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+
+	if !isBreakerFailure(err) {
+		b.failures = 0
+		if b.state != BreakerClosed {
+			b.setState(BreakerClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if wasProbe || b.failures >= b.settings.FailureThreshold {
+		b.openedAt = b.now()
+		b.setState(BreakerOpen)
+	}
+}
+
+func (b *CircuitBreaker) setState(state BreakerState) {
+	if b.state == state {
+		return
+	}
+	b.logger.Warnf("accrual circuit breaker: %s -> %s", b.state, state)
+	b.state = state
+}
+
+// isBreakerFailure reports whether err means the accrual service is unavailable.
+// Answers about an order, including 204 and 429, prove the service is up.
+func isBreakerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrOrderNotRegistered) {
+		return false
+	}
+	var tmr *TooManyRequestsError
+	return !errors.As(err, &tmr)
+}
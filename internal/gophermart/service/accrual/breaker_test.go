@@ -0,0 +1,185 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
+	"github.com/prbllm/go-loyalty-service/internal/logger"
+	mocks "github.com/prbllm/go-loyalty-service/internal/mocks/gophermart"
+	"go.uber.org/mock/gomock"
+)
+
+var errAccrualDown = errors.New("connection refused")
+
+func newTestBreaker(client Client, now *time.Time) *CircuitBreaker {
+	b := NewCircuitBreaker(client, BreakerSettings{FailureThreshold: 2, OpenTimeout: time.Minute}, logger.NewNop())
+	b.now = func() time.Time { return *now }
+	return b
+}
+
+func TestCircuitBreaker_OpensAfterConsecutiveFailures(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	calls := 0
+	b := newTestBreaker(clientFunc(func(ctx context.Context, number string) (*Response, error) {
+		calls++
+		return nil, errAccrualDown
+	}), &now)
+
+	for i := 0; i < 2; i++ {
+		if _, err := b.GetOrder(context.Background(), "1"); !errors.Is(err, errAccrualDown) {
+			t.Fatalf("call %d: expected accrual error, got %v", i, err)
+		}
+	}
+	if b.State() != BreakerOpen || b.Ready() {
+		t.Fatalf("expected open breaker, got %s", b.State())
+	}
+
+	if _, err := b.GetOrder(context.Background(), "1"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 calls to accrual, got %d", calls)
+	}
+}
+
+func TestCircuitBreaker_AnswersDoNotCountAsFailures(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	answers := []error{ErrOrderNotRegistered, &TooManyRequestsError{RetryAfter: time.Second}, ErrOrderNotRegistered}
+	b := newTestBreaker(clientFunc(func(ctx context.Context, number string) (*Response, error) {
+		err := answers[0]
+		answers = answers[1:]
+		return nil, err
+	}), &now)
+
+	for i := 0; i < 3; i++ {
+		_, _ = b.GetOrder(context.Background(), "1")
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("expected closed breaker, got %s", b.State())
+	}
+}
+
+func TestCircuitBreaker_HalfOpenProbe(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fail := true
+	b := newTestBreaker(clientFunc(func(ctx context.Context, number string) (*Response, error) {
+		if fail {
+			return nil, errAccrualDown
+		}
+		return &Response{Order: number, Status: StatusProcessed}, nil
+	}), &now)
+
+	_, _ = b.GetOrder(context.Background(), "1")
+	_, _ = b.GetOrder(context.Background(), "1")
+
+	// A failed probe reopens the breaker for another timeout
+	now = now.Add(time.Minute)
+	if b.State() != BreakerHalfOpen || !b.Ready() {
+		t.Fatalf("expected half-open breaker, got %s", b.State())
+	}
+	if _, err := b.GetOrder(context.Background(), "1"); !errors.Is(err, errAccrualDown) {
+		t.Fatalf("expected probe to reach accrual, got %v", err)
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("expected breaker to reopen, got %s", b.State())
+	}
+
+	// A successful probe closes it
+	now = now.Add(time.Minute)
+	fail = false
+	if _, err := b.GetOrder(context.Background(), "1"); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("expected closed breaker, got %s", b.State())
+	}
+}
+
+func TestCircuitBreaker_CancelledProbeStaysHalfOpen(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := newTestBreaker(clientFunc(func(ctx context.Context, number string) (*Response, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errAccrualDown
+	}), &now)
+
+	_, _ = b.GetOrder(context.Background(), "1")
+	_, _ = b.GetOrder(context.Background(), "1")
+
+	now = now.Add(time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := b.GetOrder(ctx, "1"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected probe to be cancelled, got %v", err)
+	}
+
+	// Nothing was learnt about accrual: the next probe is let through
+	if b.State() != BreakerHalfOpen || !b.Ready() {
+		t.Fatalf("expected half-open breaker ready for a probe, got %s", b.State())
+	}
+}
+
+func TestCircuitBreaker_HalfOpenLetsOneProbeThrough(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	probeStarted := make(chan struct{})
+	finishProbe := make(chan struct{})
+	fail := true
+	b := newTestBreaker(clientFunc(func(ctx context.Context, number string) (*Response, error) {
+		if fail {
+			return nil, errAccrualDown
+		}
+		close(probeStarted)
+		<-finishProbe
+		return &Response{Order: number, Status: StatusProcessed}, nil
+	}), &now)
+
+	_, _ = b.GetOrder(context.Background(), "1")
+	_, _ = b.GetOrder(context.Background(), "1")
+
+	now = now.Add(time.Minute)
+	fail = false
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = b.GetOrder(context.Background(), "1")
+	}()
+	<-probeStarted
+
+	if _, err := b.GetOrder(context.Background(), "2"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected second request to be rejected during probe, got %v", err)
+	}
+	if b.Ready() {
+		t.Fatal("expected breaker not ready while probe is in flight")
+	}
+
+	close(finishProbe)
+	<-done
+	if b.State() != BreakerClosed {
+		t.Fatalf("expected closed breaker, got %s", b.State())
+	}
+}
+
+func TestWorkerPool_OpenBreakerSkipsClaim(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := newTestBreaker(clientFunc(func(ctx context.Context, number string) (*Response, error) {
+		return nil, errAccrualDown
+	}), &now)
+	_, _ = b.GetOrder(context.Background(), "1")
+	_, _ = b.GetOrder(context.Background(), "1")
+
+	// No ClaimOrders expectation: the pool must not touch the repository
+	repo := mocks.NewMockRepository(ctrl)
+	pool := NewWorkerPool(repo, b, logger.NewNop(), time.Second, 1, WithCircuitBreaker(b))
+
+	pool.fetchAndQueueOrders(context.Background())
+
+	// Rejected orders keep their poll state
+	pool.handleOrder(context.Background(), &model.Order{Number: "1"}, 0)
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math/rand/v2"
 	"net/http"
 	"strconv"
 	"strings"
@@ -18,8 +20,12 @@ const (
 	StatusProcessing = "PROCESSING"
 	StatusProcessed  = "PROCESSED"
 	StatusInvalid    = "INVALID"
+	// Returns after processing lower the accrual; it is 0 for a reversed order
+	StatusAdjusted = "ADJUSTED"
+	StatusReversed = "REVERSED"
 
 	defaultHTTPTimeout = 5 * time.Second
+	maxErrorBodySize   = 1024
 )
 
 var (
@@ -29,6 +35,7 @@ var (
 
 type TooManyRequestsError struct {
 	RetryAfter time.Duration
+	Limit      int // requests per minute stated by accrual, 0 if unknown
 }
 
 func (e *TooManyRequestsError) Error() string {
@@ -42,6 +49,51 @@ type Client interface {
 type client struct {
 	baseURL    string
 	httpClient *http.Client
+	retry      ClientRetryPolicy
+	onAttempt  AttemptHook
+	limiter    *RateLimiter
+}
+
+// ClientRetryPolicy controls retries of a single GetOrder call.
+// Only transport errors and 502/503/504 are retried: GET is idempotent, and other answers will not change soon.
+type ClientRetryPolicy struct {
+	MaxAttempts int           // attempts per call including the first one, 1 = no retries
+	BackoffBase time.Duration // delay before the first retry, doubled on every next one
+	BackoffMax  time.Duration // upper bound of the delay
+}
+
+// Attempt describes one HTTP request made by the client
+type Attempt struct {
+	Number     string
+	Attempt    int           // starting at 1
+	StatusCode int           // 0 when the request failed before a response
+	Duration   time.Duration // time spent on the request itself
+	Err        error
+	Retrying   bool // another attempt follows
+}
+
+// AttemptHook is called after every attempt, e.g. to feed metrics
+type AttemptHook func(Attempt)
+
+type ClientOption func(*client)
+
+func WithClientRetry(policy ClientRetryPolicy) ClientOption {
+	return func(c *client) {
+		c.retry = policy.withDefaults()
+	}
+}
+
+// WithRateLimiter paces every attempt through l and feeds it the 429 answers
+func WithRateLimiter(l *RateLimiter) ClientOption {
+	return func(c *client) {
+		c.limiter = l
+	}
+}
+
+func WithAttemptHook(hook AttemptHook) ClientOption {
+	return func(c *client) {
+		c.onAttempt = hook
+	}
 }
 
 type Response struct {
@@ -50,7 +102,7 @@ type Response struct {
 	Accrual float64 `json:"accrual,omitempty"`
 }
 
-func NewClient(baseURL string, httpClient *http.Client) Client {
+func NewClient(baseURL string, httpClient *http.Client, opts ...ClientOption) Client {
 	trimmed := strings.TrimRight(baseURL, "/")
 	if httpClient == nil {
 		httpClient = &http.Client{Timeout: defaultHTTPTimeout}
@@ -58,23 +110,64 @@ func NewClient(baseURL string, httpClient *http.Client) Client {
 		httpClient.Timeout = defaultHTTPTimeout
 	}
 
-	return &client{
+	c := &client{
 		baseURL:    trimmed,
 		httpClient: httpClient,
+		retry:      ClientRetryPolicy{MaxAttempts: 1},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 func (c *client) GetOrder(ctx context.Context, number string) (*Response, error) {
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		result, statusCode, err := c.getOrder(ctx, number)
+		retrying := attempt < c.retry.MaxAttempts && isRetryable(ctx, statusCode, err)
+
+		if c.onAttempt != nil {
+			c.onAttempt(Attempt{
+				Number:     number,
+				Attempt:    attempt,
+				StatusCode: statusCode,
+				Duration:   time.Since(start),
+				Err:        err,
+				Retrying:   retrying,
+			})
+		}
+		if !retrying {
+			return result, err
+		}
+
+		timer := time.NewTimer(c.retry.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("%w (last attempt: %v)", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *client) getOrder(ctx context.Context, number string) (*Response, int, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, 0, fmt.Errorf("wait for rate limiter: %w", err)
+		}
+	}
+
 	url := fmt.Sprintf("%s%s/%s", c.baseURL, config.AccrualOrdersPath, number)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+		return nil, 0, fmt.Errorf("create request: %w", err)
 	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("send request: %w", err)
+		return nil, 0, fmt.Errorf("send request: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -82,20 +175,77 @@ func (c *client) GetOrder(ctx context.Context, number string) (*Response, error)
 	case http.StatusOK:
 		var result Response
 		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return nil, fmt.Errorf("decode response: %w", err)
+			return nil, resp.StatusCode, fmt.Errorf("decode response: %w", err)
 		}
-		return &result, nil
+		return &result, resp.StatusCode, nil
 	case http.StatusNoContent:
-		return nil, ErrOrderNotRegistered
+		return nil, resp.StatusCode, ErrOrderNotRegistered
 	case http.StatusTooManyRequests:
-		retryAfter := parseRetryAfter(resp.Header.Get(config.HeaderRetryAfter))
-		return nil, &TooManyRequestsError{RetryAfter: retryAfter}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		tmr := &TooManyRequestsError{
+			RetryAfter: parseRetryAfter(resp.Header.Get(config.HeaderRetryAfter)),
+			Limit:      parseLimit(body),
+		}
+		if c.limiter != nil {
+			c.limiter.Learn(tmr.Limit, tmr.RetryAfter)
+		}
+		return nil, resp.StatusCode, tmr
 	default:
 		if resp.StatusCode >= http.StatusInternalServerError {
-			return nil, fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+			return nil, resp.StatusCode, fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
 		}
-		return nil, fmt.Errorf("accrual returned status %d", resp.StatusCode)
+		return nil, resp.StatusCode, fmt.Errorf("accrual returned status %d", resp.StatusCode)
+	}
+}
+
+// isRetryable reports whether a failed attempt may succeed if repeated right away
+func isRetryable(ctx context.Context, statusCode int, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	switch statusCode {
+	case 0:
+		// No response: connection refused or reset, timeout
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff returns a delay before the retry following the given attempt, jittered
+// into [d/2, d) so that workers failing together do not retry together.
+func (p ClientRetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BackoffBase
+	for i := 1; i < attempt && delay < p.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > p.BackoffMax {
+		delay = p.BackoffMax
+	}
+	if delay < 2 {
+		return delay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int64N(int64(delay-half)))
+}
+
+func (p ClientRetryPolicy) withDefaults() ClientRetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = config.DefaultAccrualMaxAttempts
+	}
+	if p.BackoffBase <= 0 {
+		p.BackoffBase = config.DefaultAccrualRetryBackoff
+	}
+	if p.BackoffMax <= 0 {
+		p.BackoffMax = config.DefaultAccrualRetryBackoffMax
+	}
+	if p.BackoffMax < p.BackoffBase {
+		p.BackoffMax = p.BackoffBase
 	}
+	return p
 }
 
 func parseRetryAfter(header string) time.Duration {
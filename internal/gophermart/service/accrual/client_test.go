@@ -90,3 +90,160 @@ func TestClient_GetOrder(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_GetOrderRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantCalls    int
+		wantErr      bool
+		wantRetrying []bool
+	}{
+		{
+			name:         "recovers after bad gateway",
+			statuses:     []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK},
+			wantCalls:    3,
+			wantRetrying: []bool{true, true, false},
+		},
+		{
+			name:         "gives up after max attempts",
+			statuses:     []int{http.StatusGatewayTimeout, http.StatusGatewayTimeout, http.StatusGatewayTimeout},
+			wantCalls:    3,
+			wantErr:      true,
+			wantRetrying: []bool{true, true, false},
+		},
+		{
+			name:         "internal server error is not retried",
+			statuses:     []int{http.StatusInternalServerError},
+			wantCalls:    1,
+			wantErr:      true,
+			wantRetrying: []bool{false},
+		},
+		{
+			name:         "too many requests is not retried",
+			statuses:     []int{http.StatusTooManyRequests},
+			wantCalls:    1,
+			wantErr:      true,
+			wantRetrying: []bool{false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[calls]
+				calls++
+				w.WriteHeader(status)
+				if status == http.StatusOK {
+					_, _ = w.Write([]byte(`{"order":"123","status":"PROCESSED","accrual":50}`))
+				}
+			}))
+			defer server.Close()
+
+			var attempts []Attempt
+			client := NewClient(server.URL, nil,
+				WithClientRetry(ClientRetryPolicy{MaxAttempts: 3, BackoffBase: time.Millisecond, BackoffMax: 2 * time.Millisecond}),
+				WithAttemptHook(func(a Attempt) { attempts = append(attempts, a) }))
+
+			_, err := client.GetOrder(context.Background(), "123")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if calls != tt.wantCalls {
+				t.Fatalf("expected %d calls, got %d", tt.wantCalls, calls)
+			}
+			if len(attempts) != len(tt.wantRetrying) {
+				t.Fatalf("expected %d reported attempts, got %d", len(tt.wantRetrying), len(attempts))
+			}
+			for i, a := range attempts {
+				if a.Attempt != i+1 || a.Retrying != tt.wantRetrying[i] || a.StatusCode != tt.statuses[i] {
+					t.Fatalf("unexpected attempt %d: %+v", i, a)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_GetOrderRetriesTransportErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	var attempts []Attempt
+	client := NewClient(url, nil,
+		WithClientRetry(ClientRetryPolicy{MaxAttempts: 2, BackoffBase: time.Millisecond}),
+		WithAttemptHook(func(a Attempt) { attempts = append(attempts, a) }))
+
+	if _, err := client.GetOrder(context.Background(), "123"); err == nil {
+		t.Fatal("expected transport error")
+	}
+	if len(attempts) != 2 || attempts[0].StatusCode != 0 || !attempts[0].Retrying {
+		t.Fatalf("unexpected attempts: %+v", attempts)
+	}
+}
+
+func TestClient_GetOrderStopsRetryingOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	calls := 0
+	client := NewClient(server.URL, nil,
+		WithClientRetry(ClientRetryPolicy{MaxAttempts: 5, BackoffBase: time.Hour}),
+		WithAttemptHook(func(Attempt) { calls++ }))
+
+	_, err := client.GetOrder(ctx, "123")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single attempt, got %d", calls)
+	}
+}
+
+func TestClientRetryPolicy_BackoffJitter(t *testing.T) {
+	policy := ClientRetryPolicy{MaxAttempts: 5, BackoffBase: 100 * time.Millisecond, BackoffMax: 300 * time.Millisecond}
+
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 300 * time.Millisecond} {
+		for i := 0; i < 20; i++ {
+			got := policy.backoff(attempt)
+			if got < want/2 || got >= want {
+				t.Fatalf("attempt %d: backoff %s out of [%s, %s)", attempt, got, want/2, want)
+			}
+		}
+	}
+}
+
+func TestClient_GetOrderTeachesRateLimiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "No more than 3 requests per minute allowed", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	limiter := NewRateLimiter(0)
+	client := NewClient(server.URL, nil, WithRateLimiter(limiter))
+
+	_, err := client.GetOrder(context.Background(), "123")
+	var tmr *TooManyRequestsError
+	if !errors.As(err, &tmr) {
+		t.Fatalf("expected TooManyRequestsError, got %v", err)
+	}
+	if tmr.Limit != 3 || tmr.RetryAfter != time.Minute {
+		t.Fatalf("unexpected rate limit error: %+v", tmr)
+	}
+	if limiter.Limit() != 3 {
+		t.Fatalf("expected limiter to learn 3 requests per minute, got %d", limiter.Limit())
+	}
+
+	// The limiter now holds further requests back for Retry-After
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetOrder(ctx, "123"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected request to wait for the limiter, got %v", err)
+	}
+}
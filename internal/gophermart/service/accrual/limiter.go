@@ -0,0 +1,122 @@
+package accrual
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// limitPattern matches the 429 body of the accrual service
+var limitPattern = regexp.MustCompile(`No more than (\d+) requests per minute allowed`)
+
+// RateLimiter paces requests to accrual with a token bucket shared by all workers, so the pool
+// stays under the accrual limit instead of finding it through 429s. The bucket holds a single
+// token: requests are spread evenly over the minute rather than sent in bursts.
+type RateLimiter struct {
+	mu          sync.Mutex
+	perMinute   int
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	now         func() time.Time
+}
+
+// NewRateLimiter creates a limiter for perMinute requests; 0 means unlimited until accrual reports its limit.
+func NewRateLimiter(perMinute int) *RateLimiter {
+	l := &RateLimiter{now: time.Now, tokens: 1}
+	l.last = l.now()
+	l.setLimit(perMinute)
+	return l
+}
+
+// Limit returns the current limit in requests per minute, 0 if unlimited
+func (l *RateLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.perMinute
+}
+
+// Wait blocks until a request may be sent or ctx is done
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		delay := l.reserve()
+		if delay <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Learn adjusts the limiter to a 429 answer: perMinute is the limit stated by accrual (0 if unknown),
+// retryAfter the pause it asked for.
+func (l *RateLimiter) Learn(perMinute int, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if perMinute > 0 {
+		l.setLimit(perMinute)
+	}
+	if until := l.now().Add(retryAfter); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+	// Start the next minute from an empty bucket so the resumed requests are spread out
+	l.tokens = 0
+}
+
+// reserve takes a token and returns 0, or returns how long to wait before trying again
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	if l.perMinute == 0 {
+		return 0
+	}
+
+	rate := float64(l.perMinute) / float64(time.Minute)
+	if l.last.Before(l.pausedUntil) {
+		l.last = l.pausedUntil
+	}
+	l.tokens = min(1, l.tokens+float64(now.Sub(l.last))*rate)
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / rate)
+}
+
+func (l *RateLimiter) setLimit(perMinute int) {
+	if perMinute < 0 {
+		perMinute = 0
+	}
+	l.perMinute = perMinute
+}
+
+// parseLimit extracts the requests per minute limit from a 429 body, 0 if there is none
+func parseLimit(body []byte) int {
+	match := limitPattern.FindSubmatch(body)
+	if match == nil {
+		return 0
+	}
+
+	limit, err := strconv.Atoi(string(match[1]))
+	if err != nil {
+		return 0
+	}
+	return limit
+}
@@ -0,0 +1,94 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(perMinute int, now *time.Time) *RateLimiter {
+	l := NewRateLimiter(perMinute)
+	l.now = func() time.Time { return *now }
+	l.last = *now
+	return l
+}
+
+func TestRateLimiter_SpreadsRequestsOverTheMinute(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newTestLimiter(6, &now)
+
+	if delay := l.reserve(); delay != 0 {
+		t.Fatalf("expected first request to pass, got delay %s", delay)
+	}
+	if delay := l.reserve(); delay != 10*time.Second {
+		t.Fatalf("expected 10s until the next token, got %s", delay)
+	}
+
+	now = now.Add(10 * time.Second)
+	if delay := l.reserve(); delay != 0 {
+		t.Fatalf("expected request after 10s to pass, got delay %s", delay)
+	}
+
+	// Idle time does not build up a burst
+	now = now.Add(time.Minute)
+	l.reserve()
+	if delay := l.reserve(); delay == 0 {
+		t.Fatal("expected second request in a row to wait")
+	}
+}
+
+func TestRateLimiter_UnlimitedUntilLearned(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newTestLimiter(0, &now)
+
+	for i := 0; i < 100; i++ {
+		if delay := l.reserve(); delay != 0 {
+			t.Fatalf("expected unlimited requests, got delay %s", delay)
+		}
+	}
+
+	l.Learn(2, 30*time.Second)
+	if l.Limit() != 2 {
+		t.Fatalf("expected learned limit 2, got %d", l.Limit())
+	}
+	if delay := l.reserve(); delay != 30*time.Second {
+		t.Fatalf("expected Retry-After pause, got %s", delay)
+	}
+
+	// After the pause requests resume at the learned pace
+	now = now.Add(30 * time.Second)
+	if delay := l.reserve(); delay != 30*time.Second {
+		t.Fatalf("expected 30s between requests, got %s", delay)
+	}
+}
+
+func TestRateLimiter_WaitRespectsContext(t *testing.T) {
+	l := NewRateLimiter(0)
+	l.Learn(0, time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		body string
+		want int
+	}{
+		{"No more than 10 requests per minute allowed\n", 10},
+		{"No more than 250 requests per minute allowed", 250},
+		{"Too Many Requests", 0},
+		{"", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseLimit([]byte(tt.body)); got != tt.want {
+			t.Errorf("parseLimit(%q) = %d, want %d", tt.body, got, tt.want)
+		}
+	}
+}
@@ -2,10 +2,16 @@ package accrual
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/prbllm/go-loyalty-service/internal/config"
 	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
 	"github.com/prbllm/go-loyalty-service/internal/gophermart/repository"
 	"github.com/prbllm/go-loyalty-service/internal/logger"
@@ -14,20 +20,136 @@ import (
 const (
 	defaultPollingInterval = 1 * time.Second
 	DefaultWorkers         = 5
+	defaultBatchSize       = 100
+	releaseLeasesTimeout   = 5 * time.Second
+	defaultScaleInterval   = 5 * time.Second
+	// adminWorkerID marks log lines of polls forced by an operator
+	adminWorkerID = -1
+)
+
+var (
+	ErrOrderInFlight = errors.New("order is already being polled")
+	ErrOrderFinal    = errors.New("order already has a final status")
 )
 
 type WorkerPool struct {
-	repo          repository.Repository
-	client        Client
-	logger        logger.Logger
-	interval      time.Duration
-	workers       int
-	jobs          chan *model.Order
-	rateLimitChan chan time.Duration
-	wg            sync.WaitGroup
+	repo     repository.Repository
+	client   Client
+	logger   logger.Logger
+	interval time.Duration
+	jobs     chan *model.Order
+	wake     chan struct{}
+	shrink   chan struct{}
+	wg       sync.WaitGroup
+
+	// Worker count moves between minWorkers and maxWorkers, see scale
+	minWorkers    int
+	maxWorkers    int
+	scaleInterval time.Duration
+
+	retry RetryPolicy
+	now   func() time.Time
+
+	// owner identifies this pool in order leases; lease is how long claimed orders stay reserved
+	owner string
+	lease time.Duration
+
+	// recheck makes processed orders polled again; negative is the claw back policy for them
+	recheck  model.RecheckPolicy
+	negative model.NegativeBalancePolicy
+
+	// breaker, when set, keeps the puller from claiming orders while accrual is unavailable
+	breaker *CircuitBreaker
+
+	// elector, when set, lets the puller run only on the replica holding leadership
+	elector Elector
+	leading atomic.Bool
+
+	// inFlight holds numbers of orders that are queued or being handled by a worker
+	mu               sync.Mutex
+	inFlight         map[string]struct{}
+	rateLimitedUntil time.Time
+	lastPollAt       time.Time
+	workers          int
+	nextWorkerID     int
+	rateLimitHits    int // 429s since the last scaling decision
+}
+
+// PoolStats is a snapshot of the pool load
+type PoolStats struct {
+	Workers    int // running workers
+	QueueDepth int // orders waiting in the jobs queue
+	InFlight   int // orders queued or being handled, each counted once
+	Leader     bool
+
+	RateLimitBackoff time.Duration // time left until the puller resumes after a 429
+	LastPoll         time.Time     // last time accrual answered about an order, zero if never
+	Breaker          BreakerState  // empty when the pool has no circuit breaker
 }
 
-func NewWorkerPool(repo repository.Repository, client Client, logger logger.Logger, interval time.Duration, workers int) *WorkerPool {
+// Elector decides which replica runs the puller, see repository.LeaderElector.
+// Campaign blocks until leadership is acquired; the returned context ends when it is lost.
+type Elector interface {
+	Campaign(ctx context.Context) (context.Context, func(), error)
+}
+
+type WorkerPoolOption func(*WorkerPool)
+
+func WithLease(lease time.Duration) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		if lease > 0 {
+			wp.lease = lease
+		}
+	}
+}
+
+// WithWorkerBounds lets the pool scale between min and max workers.
+// Without it the pool keeps the worker count passed to NewWorkerPool.
+func WithWorkerBounds(min, max int) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		if min > 0 {
+			wp.minWorkers = min
+		}
+		if max > 0 {
+			wp.maxWorkers = max
+		}
+	}
+}
+
+// WithLeaderElection makes the pool claim orders only while it is the leader.
+// Workers keep running on every replica and finish orders claimed before leadership was lost.
+func WithLeaderElection(elector Elector) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.elector = elector
+	}
+}
+
+// WithCircuitBreaker makes the pool stop claiming orders while b is open.
+// The pool's client is expected to be b itself.
+func WithCircuitBreaker(b *CircuitBreaker) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.breaker = b
+	}
+}
+
+// WithRecheck makes the pool poll processed orders again within policy.Window and apply
+// changed accruals, clawing back lowered ones according to negative
+func WithRecheck(policy model.RecheckPolicy, negative model.NegativeBalancePolicy) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.recheck = policy
+		if negative != "" {
+			wp.negative = negative
+		}
+	}
+}
+
+func WithRetryPolicy(policy RetryPolicy) WorkerPoolOption {
+	return func(wp *WorkerPool) {
+		wp.retry = policy.withDefaults()
+	}
+}
+
+func NewWorkerPool(repo repository.Repository, client Client, logger logger.Logger, interval time.Duration, workers int, opts ...WorkerPoolOption) *WorkerPool {
 	if interval <= 0 {
 		interval = defaultPollingInterval
 	}
@@ -35,24 +157,51 @@ func NewWorkerPool(repo repository.Repository, client Client, logger logger.Logg
 		workers = DefaultWorkers
 	}
 
-	return &WorkerPool{
+	wp := &WorkerPool{
 		repo:          repo,
 		client:        client,
 		logger:        logger,
 		interval:      interval,
 		workers:       workers,
-		jobs:          make(chan *model.Order, workers*2),
-		rateLimitChan: make(chan time.Duration, workers),
+		minWorkers:    workers,
+		maxWorkers:    workers,
+		scaleInterval: defaultScaleInterval,
+		wake:          make(chan struct{}, 1),
+		retry:         DefaultRetryPolicy(),
+		now:           time.Now,
+		owner:         newLeaseOwner(),
+		lease:         config.DefaultPollLease,
+		negative:      model.NegativeBalancePolicy(config.DefaultNegativeBalance),
+		inFlight:      make(map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(wp)
+	}
+
+	if wp.maxWorkers < wp.minWorkers {
+		wp.maxWorkers = wp.minWorkers
 	}
+	wp.workers = min(max(wp.workers, wp.minWorkers), wp.maxWorkers)
+
+	// Channels are sized for the largest pool so that scaling never blocks on them
+	wp.jobs = make(chan *model.Order, wp.maxWorkers*2)
+	wp.shrink = make(chan struct{}, wp.maxWorkers)
+	return wp
 }
 
 func (wp *WorkerPool) Run(ctx context.Context) {
 	wp.wg.Add(1)
 	go wp.runPuller(ctx)
 
+	wp.mu.Lock()
 	for i := 0; i < wp.workers; i++ {
+		wp.startWorker(ctx)
+	}
+	wp.mu.Unlock()
+
+	if wp.minWorkers < wp.maxWorkers {
 		wp.wg.Add(1)
-		go wp.runWorker(ctx, i)
+		go wp.runScaler(ctx)
 	}
 }
 
@@ -60,37 +209,72 @@ func (wp *WorkerPool) Wait() {
 	wp.wg.Wait()
 }
 
+// Wake makes the puller claim orders now instead of on the next tick.
+// Calls made while a wake-up is already pending are coalesced.
+func (wp *WorkerPool) Wake() {
+	select {
+	case wp.wake <- struct{}{}:
+	default:
+	}
+}
+
+// newLeaseOwner builds an owner id unique across replicas and pools within one process
+func newLeaseOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "gophermart"
+	}
+
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
 func (wp *WorkerPool) runPuller(ctx context.Context) {
 	defer wp.wg.Done()
+	defer wp.releaseLeases()
 	defer close(wp.jobs)
 
-	delay := time.Duration(0)
+	if wp.elector == nil {
+		wp.leading.Store(true)
+		wp.pull(ctx)
+		return
+	}
+
+	for ctx.Err() == nil {
+		leaderCtx, release, err := wp.elector.Campaign(ctx)
+		if err != nil {
+			// Campaign gives up only when ctx is cancelled
+			return
+		}
+
+		wp.leading.Store(true)
+		wp.pull(leaderCtx)
+		wp.leading.Store(false)
+		release()
+	}
+}
+
+func (wp *WorkerPool) pull(ctx context.Context) {
 	ticker := time.NewTicker(wp.interval)
 	defer ticker.Stop()
 
 	for {
-		if delay > 0 {
-			if !wp.wait(ctx, delay) {
-				return
-			}
-			delay = 0
-		}
-
 		select {
 		case <-ctx.Done():
 			return
-		case retryAfter := <-wp.rateLimitChan:
-			if retryAfter > delay {
-				delay = retryAfter
-			}
-			continue
 		case <-ticker.C:
+		case <-wp.wake:
 		}
 
-		backoff := wp.fetchAndQueueOrders(ctx)
-		if backoff > delay {
-			delay = backoff
+		// Orders claimed while accrual asks to back off could not be sent anyway
+		if left := wp.rateLimitLeft(); left > 0 {
+			if !wp.wait(ctx, left) {
+				return
+			}
 		}
+
+		wp.fetchAndQueueOrders(ctx)
 	}
 }
 
@@ -106,26 +290,207 @@ func (wp *WorkerPool) wait(ctx context.Context, delay time.Duration) bool {
 	}
 }
 
-func (wp *WorkerPool) fetchAndQueueOrders(ctx context.Context) time.Duration {
-	statuses := []model.OrderStatus{model.OrderStatusNew, model.OrderStatusProcessing}
+// fetchAndQueueOrders claims due NEW and PROCESSING orders, and processed orders due for
+// a recheck, and queues them to the workers.
+// Claimed orders are leased to this pool, so other replicas skip them; orders backing off
+// after failed polls and parked orders are not claimed at all.
+func (wp *WorkerPool) fetchAndQueueOrders(ctx context.Context) {
+	// Claimed orders would only sit in the queue until their lease expires
+	if wp.breaker != nil && !wp.breaker.Ready() {
+		return
+	}
 
-	for _, status := range statuses {
-		orders, err := wp.repo.GetOrdersByStatus(ctx, status)
-		if err != nil {
-			wp.logger.Errorf("poller: get orders by status %s: %v", status, err)
+	orders, err := wp.repo.ClaimOrders(ctx, wp.owner, defaultBatchSize, wp.lease, wp.recheck)
+	if err != nil {
+		wp.logger.Errorf("poller: claim orders: %v", err)
+		return
+	}
+
+	for _, order := range orders {
+		// The order is still queued or handled since an earlier refill
+		if !wp.acquire(order.Number) {
 			continue
 		}
 
-		for _, order := range orders {
-			select {
-			case <-ctx.Done():
-				return 0
-			case wp.jobs <- order:
-			}
+		select {
+		case <-ctx.Done():
+			wp.release(order.Number)
+			return
+		case wp.jobs <- order:
 		}
 	}
+}
+
+// Stats returns a snapshot of the pool load and of its view of the accrual service
+func (wp *WorkerPool) Stats() PoolStats {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
 
-	return 0
+	stats := PoolStats{
+		Workers:    wp.workers,
+		QueueDepth: len(wp.jobs),
+		InFlight:   len(wp.inFlight),
+		Leader:     wp.leading.Load(),
+		LastPoll:   wp.lastPollAt,
+	}
+	if left := wp.rateLimitedUntil.Sub(wp.now()); left > 0 {
+		stats.RateLimitBackoff = left
+	}
+	if wp.breaker != nil {
+		stats.Breaker = wp.breaker.State()
+	}
+	return stats
+}
+
+// setRateLimited records a 429: the puller pauses for delay and the scaler takes a worker away.
+// A later answer can only extend the pause, so no signal is lost however many workers get a 429.
+func (wp *WorkerPool) setRateLimited(delay time.Duration) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	wp.rateLimitHits++
+	if until := wp.now().Add(delay); until.After(wp.rateLimitedUntil) {
+		wp.rateLimitedUntil = until
+	}
+}
+
+func (wp *WorkerPool) rateLimitLeft() time.Duration {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	return max(wp.rateLimitedUntil.Sub(wp.now()), 0)
+}
+
+func (wp *WorkerPool) setPolled() {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	wp.lastPollAt = wp.now()
+}
+
+// PollNow polls the order right away, ignoring its schedule, backoff and parking,
+// and returns the order as stored afterwards. A processed order is rechecked if it is still
+// within the recheck window. An error means the order was not polled.
+func (wp *WorkerPool) PollNow(ctx context.Context, number string) (*model.Order, error) {
+	order, err := wp.repo.GetOrderForPoll(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status == model.OrderStatusInvalid ||
+		order.Status == model.OrderStatusProcessed && !wp.inRecheckWindow(order) {
+		return nil, ErrOrderFinal
+	}
+
+	if !wp.acquire(number) {
+		return nil, ErrOrderInFlight
+	}
+	defer wp.release(number)
+
+	if err := wp.handleOrder(ctx, order, adminWorkerID); err != nil {
+		return nil, err
+	}
+	return wp.repo.GetOrderForPoll(ctx, number)
+}
+
+// inRecheckWindow reports whether the processed order may still be rechecked
+func (wp *WorkerPool) inRecheckWindow(order *model.Order) bool {
+	return wp.recheck.Window > 0 && order.ProcessedAt != nil &&
+		wp.now().Sub(*order.ProcessedAt) < wp.recheck.Window
+}
+
+// acquire marks the order as in flight; false means it is already queued or being handled
+func (wp *WorkerPool) acquire(number string) bool {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	if _, ok := wp.inFlight[number]; ok {
+		return false
+	}
+	wp.inFlight[number] = struct{}{}
+	return true
+}
+
+func (wp *WorkerPool) release(number string) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	delete(wp.inFlight, number)
+}
+
+// releaseLeases hands unprocessed orders back on shutdown instead of waiting for the lease to expire
+func (wp *WorkerPool) releaseLeases() {
+	ctx, cancel := context.WithTimeout(context.Background(), releaseLeasesTimeout)
+	defer cancel()
+
+	if err := wp.repo.ReleaseOrderLeases(ctx, wp.owner); err != nil {
+		wp.logger.Errorf("poller: release order leases: %v", err)
+	}
+}
+
+// startWorker must be called with wp.mu held
+func (wp *WorkerPool) startWorker(ctx context.Context) {
+	id := wp.nextWorkerID
+	wp.nextWorkerID++
+
+	wp.wg.Add(1)
+	go wp.runWorker(ctx, id)
+}
+
+// stopWorker must be called with wp.mu held. Some idle worker exits once it is done with its
+// current order; queued orders stay in the channel for the rest.
+func (wp *WorkerPool) stopWorker() {
+	wp.workers--
+	select {
+	case wp.shrink <- struct{}{}:
+	default:
+	}
+}
+
+func (wp *WorkerPool) runScaler(ctx context.Context) {
+	defer wp.wg.Done()
+
+	ticker := time.NewTicker(wp.scaleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			wp.scale(ctx)
+		}
+	}
+}
+
+// scale changes the worker count by one: down after 429s from accrual, up while orders wait
+// in the queue, and down again when most workers sit idle.
+func (wp *WorkerPool) scale(ctx context.Context) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	queued := len(wp.jobs)
+	busy := len(wp.inFlight) - queued
+	rateLimited := wp.rateLimitHits > 0
+	wp.rateLimitHits = 0
+
+	before := wp.workers
+	switch {
+	case rateLimited:
+		if wp.workers > wp.minWorkers {
+			wp.stopWorker()
+		}
+	case queued > 0:
+		if wp.workers < wp.maxWorkers {
+			wp.workers++
+			wp.startWorker(ctx)
+		}
+	case busy < wp.workers/2 && wp.workers > wp.minWorkers:
+		wp.stopWorker()
+	}
+
+	if wp.workers != before {
+		wp.logger.Infof("poller: workers %d -> %d (queued %d, busy %d, rate limited %t)", before, wp.workers, queued, busy, rateLimited)
+	}
 }
 
 func (wp *WorkerPool) runWorker(ctx context.Context, id int) {
@@ -135,53 +500,111 @@ func (wp *WorkerPool) runWorker(ctx context.Context, id int) {
 		select {
 		case <-ctx.Done():
 			return
+		case <-wp.shrink:
+			return
 		case order, ok := <-wp.jobs:
 			if !ok {
 				return
 			}
-			wp.handleOrder(ctx, order, id)
+			_ = wp.handleOrder(ctx, order, id)
+			wp.release(order.Number)
 		}
 	}
 }
 
-func (wp *WorkerPool) handleOrder(ctx context.Context, order *model.Order, workerID int) {
+// handleOrder polls accrual about the order and stores the outcome.
+// It returns an error only when the order was not polled: the pool is rate limited or the breaker is open.
+func (wp *WorkerPool) handleOrder(ctx context.Context, order *model.Order, workerID int) error {
 	resp, err := wp.client.GetOrder(ctx, order.Number)
 	if err != nil {
-		switch {
-		case errors.Is(err, ErrOrderNotRegistered):
-			return
-		default:
-			var tmr *TooManyRequestsError
-			if errors.As(err, &tmr) {
-				select {
-				case wp.rateLimitChan <- tmr.RetryAfter:
-				case <-ctx.Done():
-				default:
-				}
-				return
-			}
+		// Rate limiting slows down the whole pool and is not the order's fault
+		var tmr *TooManyRequestsError
+		if errors.As(err, &tmr) {
+			wp.setRateLimited(tmr.RetryAfter)
+			return err
+		}
+		// The breaker rejected the call, the order was not polled; its lease hands it back later
+		if errors.Is(err, ErrCircuitOpen) {
+			return err
+		}
+		if errors.Is(err, ErrOrderNotRegistered) {
+			wp.setPolled()
+		} else {
 			wp.logger.Errorf("worker %d: get order %s from accrual: %v", workerID, order.Number, err)
-			return
 		}
+		if order.Status == model.OrderStatusProcessed {
+			// A failed recheck is not retried sooner; the order already has its accrual
+			if err := wp.repo.ScheduleOrderRetry(ctx, order.Number, wp.now(), err.Error()); err != nil {
+				wp.logger.Errorf("worker %d: schedule recheck of order %s: %v", workerID, order.Number, err)
+			}
+			return nil
+		}
+		wp.scheduleRetry(ctx, order, err, workerID)
+		return nil
 	}
+	wp.setPolled()
 
 	targetStatus, accrualAmount := mapStatus(resp.Status, resp.Accrual)
+	if order.Status == model.OrderStatusProcessed {
+		wp.applyRecheck(ctx, order, targetStatus, accrualAmount, workerID)
+		return nil
+	}
 	if targetStatus == "" {
-		return
+		return nil
 	}
 
 	if err := wp.repo.UpdateOrderStatus(ctx, order.Number, targetStatus, accrualAmount); err != nil {
 		wp.logger.Errorf("worker %d: update order %s status to %s: %v", workerID, order.Number, targetStatus, err)
 	}
+	return nil
+}
+
+// applyRecheck stores the accrual of a processed order polled again. Accrual only lowers it
+// after returns, but whatever changed is applied to the balance.
+func (wp *WorkerPool) applyRecheck(ctx context.Context, order *model.Order, status model.OrderStatus, accrual model.Amount, workerID int) {
+	if status != model.OrderStatusProcessed {
+		wp.logger.Warnf("worker %d: processed order %s is %s in accrual now, keeping its accrual", workerID, order.Number, status)
+		accrual = order.Accrual
+	}
+
+	diff, err := wp.repo.RecheckOrder(ctx, order.Number, accrual, wp.negative)
+	if err != nil {
+		wp.logger.Errorf("worker %d: recheck order %s: %v", workerID, order.Number, err)
+		return
+	}
+	if diff != 0 {
+		wp.logger.Infof("worker %d: accrual of order %s changed by %.2f", workerID, order.Number, diff.ToFloat64())
+	}
+}
+
+// scheduleRetry backs the order off exponentially or parks it once the retry policy is exhausted.
+func (wp *WorkerPool) scheduleRetry(ctx context.Context, order *model.Order, cause error, workerID int) {
+	attempts := order.PollAttempts + 1
+	now := wp.now()
+
+	if wp.retry.Exhausted(attempts, now.Sub(order.UploadedAt)) {
+		wp.logger.Warnf("worker %d: order %s parked after %d failed polls: %v", workerID, order.Number, attempts, cause)
+		if err := wp.repo.ParkOrder(ctx, order.Number, cause.Error()); err != nil {
+			wp.logger.Errorf("worker %d: park order %s: %v", workerID, order.Number, err)
+		}
+		return
+	}
+
+	nextPollAt := now.Add(wp.retry.Backoff(attempts))
+	if err := wp.repo.ScheduleOrderRetry(ctx, order.Number, nextPollAt, cause.Error()); err != nil {
+		wp.logger.Errorf("worker %d: schedule retry of order %s: %v", workerID, order.Number, err)
+	}
 }
 
+// mapStatus maps an accrual answer to the order status and its accrual. For an unfinished
+// order the accrual is the expected one, if accrual already reported it.
 func mapStatus(accrualStatus string, accrual float64) (model.OrderStatus, model.Amount) {
 	switch accrualStatus {
 	case StatusRegistered, StatusProcessing:
-		return model.OrderStatusProcessing, model.Amount(0)
+		return model.OrderStatusProcessing, model.FromFloat64(accrual)
 	case StatusInvalid:
 		return model.OrderStatusInvalid, model.Amount(0)
-	case StatusProcessed:
+	case StatusProcessed, StatusAdjusted, StatusReversed:
 		return model.OrderStatusProcessed, model.FromFloat64(accrual)
 	default:
 		return "", model.Amount(0)
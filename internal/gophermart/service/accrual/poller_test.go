@@ -3,6 +3,7 @@ package accrual
 import (
 	"context"
 	"errors"
+	"sync"
 	"testing"
 	"time"
 
@@ -27,20 +28,12 @@ func TestWorkerPool_FetchAndQueueOrders(t *testing.T) {
 	orderNew := &model.Order{Number: "1", Status: model.OrderStatusNew}
 	orderProcessing := &model.Order{Number: "2", Status: model.OrderStatusProcessing}
 
-	repo.EXPECT().GetOrdersByStatus(gomock.Any(), model.OrderStatusNew).Return([]*model.Order{orderNew}, nil)
-	repo.EXPECT().GetOrdersByStatus(gomock.Any(), model.OrderStatusProcessing).Return([]*model.Order{orderProcessing}, nil)
-
-	client := clientFunc(func(ctx context.Context, number string) (*Response, error) {
-		return nil, ErrOrderNotRegistered
-	})
-
-	pool := NewWorkerPool(repo, client, logger.NewNop(), time.Second, 2)
+	pool := NewWorkerPool(repo, nil, logger.NewNop(), time.Second, 2, WithLease(30*time.Second))
 	pool.jobs = make(chan *model.Order, 10)
 
-	backoff := pool.fetchAndQueueOrders(context.Background())
-	if backoff != 0 {
-		t.Fatalf("expected no backoff, got %s", backoff)
-	}
+	repo.EXPECT().ClaimOrders(gomock.Any(), pool.owner, defaultBatchSize, 30*time.Second, model.RecheckPolicy{}).Return([]*model.Order{orderNew, orderProcessing}, nil)
+
+	pool.fetchAndQueueOrders(context.Background())
 
 	select {
 	case order := <-pool.jobs:
@@ -52,6 +45,219 @@ func TestWorkerPool_FetchAndQueueOrders(t *testing.T) {
 	}
 }
 
+func TestWorkerPool_FetchSkipsInFlightOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockRepository(ctrl)
+
+	orders := []*model.Order{{Number: "1"}, {Number: "2"}}
+	repo.EXPECT().ClaimOrders(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(orders, nil).Times(3)
+
+	pool := NewWorkerPool(repo, nil, logger.NewNop(), time.Second, 2)
+	pool.jobs = make(chan *model.Order, 10)
+
+	pool.fetchAndQueueOrders(context.Background())
+	pool.fetchAndQueueOrders(context.Background())
+
+	stats := pool.Stats()
+	if stats.QueueDepth != 2 || stats.InFlight != 2 {
+		t.Fatalf("expected 2 queued and 2 in flight, got %+v", stats)
+	}
+
+	// Once a worker is done with the order it can be queued again
+	done := <-pool.jobs
+	pool.release(done.Number)
+	pool.fetchAndQueueOrders(context.Background())
+
+	stats = pool.Stats()
+	if stats.QueueDepth != 2 || stats.InFlight != 2 {
+		t.Fatalf("expected finished order to be requeued, got %+v", stats)
+	}
+}
+
+// TestWorkerPool_ConcurrentRefillHandlesOrderOnce refills the queue every millisecond while workers
+// are blocked in accrual calls; run with -race to check the in-flight bookkeeping.
+func TestWorkerPool_ConcurrentRefillHandlesOrderOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockRepository(ctrl)
+
+	orders := []*model.Order{{Number: "1"}, {Number: "2"}, {Number: "3"}}
+	repo.EXPECT().ClaimOrders(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(orders, nil).AnyTimes()
+	repo.EXPECT().ScheduleOrderRetry(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	repo.EXPECT().ParkOrder(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	repo.EXPECT().ReleaseOrderLeases(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	client := clientFunc(func(ctx context.Context, number string) (*Response, error) {
+		mu.Lock()
+		calls[number]++
+		mu.Unlock()
+
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := NewWorkerPool(repo, client, logger.NewNop(), time.Millisecond, 4)
+	pool.Run(ctx)
+
+	var statsWG sync.WaitGroup
+	statsWG.Add(1)
+	go func() {
+		defer statsWG.Done()
+		for ctx.Err() == nil {
+			pool.Stats()
+		}
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for pool.Stats().InFlight < len(orders) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	// Let the puller tick many more times while all orders are still in flight
+	time.Sleep(50 * time.Millisecond)
+
+	stats := pool.Stats()
+	mu.Lock()
+	for _, order := range orders {
+		if calls[order.Number] != 1 {
+			t.Errorf("order %s sent to accrual %d times, want 1", order.Number, calls[order.Number])
+		}
+	}
+	mu.Unlock()
+
+	cancel()
+	pool.Wait()
+	statsWG.Wait()
+
+	if stats.InFlight != len(orders) || stats.QueueDepth != 0 {
+		t.Fatalf("expected %d in flight and empty queue, got %+v", len(orders), stats)
+	}
+}
+
+func TestWorkerPool_WakeClaimsWithoutTick(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockRepository(ctrl)
+
+	claimed := make(chan struct{}, 1)
+	repo.EXPECT().ClaimOrders(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(context.Context, string, int, time.Duration, model.RecheckPolicy) ([]*model.Order, error) {
+			claimed <- struct{}{}
+			return nil, nil
+		})
+	repo.EXPECT().ReleaseOrderLeases(gomock.Any(), gomock.Any()).Return(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := NewWorkerPool(repo, nil, logger.NewNop(), time.Hour, 1)
+	pool.Run(ctx)
+
+	pool.Wake()
+	pool.Wake()
+
+	select {
+	case <-claimed:
+	case <-time.After(time.Second):
+		t.Fatal("expected wake-up to claim orders before the tick")
+	}
+
+	cancel()
+	pool.Wait()
+}
+
+type testElector struct {
+	grants   chan struct{}
+	revoke   chan context.CancelFunc
+	released chan struct{}
+}
+
+func (e *testElector) Campaign(ctx context.Context) (context.Context, func(), error) {
+	select {
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	case <-e.grants:
+	}
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	e.revoke <- cancel
+	return leaderCtx, func() {
+		cancel()
+		e.released <- struct{}{}
+	}, nil
+}
+
+func TestWorkerPool_ClaimsOnlyWhileLeader(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockRepository(ctrl)
+
+	claimed := make(chan struct{}, 1)
+	repo.EXPECT().ClaimOrders(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(context.Context, string, int, time.Duration, model.RecheckPolicy) ([]*model.Order, error) {
+			claimed <- struct{}{}
+			return nil, nil
+		})
+	repo.EXPECT().ReleaseOrderLeases(gomock.Any(), gomock.Any()).Return(nil)
+
+	elector := &testElector{
+		grants:   make(chan struct{}),
+		revoke:   make(chan context.CancelFunc, 1),
+		released: make(chan struct{}, 1),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := NewWorkerPool(repo, nil, logger.NewNop(), time.Hour, 1, WithLeaderElection(elector))
+	pool.Run(ctx)
+
+	pool.Wake()
+	select {
+	case <-claimed:
+		t.Fatal("follower must not claim orders")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if pool.Stats().Leader {
+		t.Fatal("expected pool not to be leader")
+	}
+
+	// The wake-up left pending while following is served once leadership is acquired
+	elector.grants <- struct{}{}
+	select {
+	case <-claimed:
+	case <-time.After(time.Second):
+		t.Fatal("expected leader to claim orders")
+	}
+	if !pool.Stats().Leader {
+		t.Fatal("expected pool to be leader")
+	}
+
+	revoke := <-elector.revoke
+	revoke()
+	select {
+	case <-elector.released:
+	case <-time.After(time.Second):
+		t.Fatal("expected leadership to be released")
+	}
+	if pool.Stats().Leader {
+		t.Fatal("expected pool to step down")
+	}
+
+	pool.Wake()
+	select {
+	case <-claimed:
+		t.Fatal("pool claimed orders after losing leadership")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	pool.Wait()
+}
+
 func TestWorkerPool_HandleOrder_Processed(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -88,6 +294,61 @@ func TestWorkerPool_HandleOrder_Invalid(t *testing.T) {
 	pool.handleOrder(context.Background(), order, 0)
 }
 
+func TestWorkerPool_HandleOrder_Recheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      string
+		accrual     float64
+		wantAccrual model.Amount
+	}{
+		{"adjusted", StatusAdjusted, 4, model.Amount(400)},
+		{"reversed", StatusReversed, 0, model.Amount(0)},
+		{"unchanged", StatusProcessed, 12.5, model.Amount(1250)},
+		{"unexpected status keeps the accrual", StatusProcessing, 0, model.Amount(1250)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mocks.NewMockRepository(ctrl)
+			order := &model.Order{Number: "4", Status: model.OrderStatusProcessed, Accrual: model.Amount(1250)}
+
+			repo.EXPECT().RecheckOrder(gomock.Any(), order.Number, tt.wantAccrual, model.NegativeBalanceFloor).
+				Return(tt.wantAccrual-order.Accrual, nil)
+
+			client := clientFunc(func(ctx context.Context, number string) (*Response, error) {
+				return &Response{Order: number, Status: tt.status, Accrual: tt.accrual}, nil
+			})
+
+			pool := NewWorkerPool(repo, client, logger.NewNop(), time.Second, 1,
+				WithRecheck(model.RecheckPolicy{Window: time.Hour, Interval: time.Minute}, model.NegativeBalanceFloor))
+			pool.handleOrder(context.Background(), order, 0)
+		})
+	}
+}
+
+func TestWorkerPool_HandleOrder_RecheckFailureDoesNotPark(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockRepository(ctrl)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Long past the retry policy's max age, a pending order would be parked
+	order := &model.Order{Number: "5", Status: model.OrderStatusProcessed, UploadedAt: now.Add(-30 * 24 * time.Hour)}
+
+	repo.EXPECT().ScheduleOrderRetry(gomock.Any(), order.Number, now, gomock.Any()).Return(nil)
+
+	client := clientFunc(func(ctx context.Context, number string) (*Response, error) {
+		return nil, errors.New("connection reset")
+	})
+
+	pool := NewWorkerPool(repo, client, logger.NewNop(), time.Second, 1)
+	pool.now = func() time.Time { return now }
+	pool.handleOrder(context.Background(), order, 0)
+}
+
 func TestWorkerPool_HandleOrder_TooManyRequests(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -100,17 +361,24 @@ func TestWorkerPool_HandleOrder_TooManyRequests(t *testing.T) {
 		return nil, &TooManyRequestsError{RetryAfter: 2 * time.Second}
 	})
 
-	pool := NewWorkerPool(repo, client, logger.NewNop(), time.Second, 1)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pool := NewWorkerPool(repo, client, logger.NewNop(), time.Second, 2)
+	pool.now = func() time.Time { return now }
 
+	// Concurrent 429s extend the pause instead of being dropped
+	pool.handleOrder(context.Background(), order, 0)
+	client = clientFunc(func(ctx context.Context, number string) (*Response, error) {
+		return nil, &TooManyRequestsError{RetryAfter: 5 * time.Second}
+	})
+	pool.client = client
+	pool.handleOrder(context.Background(), order, 1)
 	pool.handleOrder(context.Background(), order, 0)
 
-	select {
-	case retryAfter := <-pool.rateLimitChan:
-		if retryAfter != 2*time.Second {
-			t.Fatalf("expected retry after 2s, got %s", retryAfter)
-		}
-	case <-time.After(100 * time.Millisecond):
-		t.Fatal("expected rate limit signal")
+	if left := pool.rateLimitLeft(); left != 5*time.Second {
+		t.Fatalf("expected 5s pause, got %s", left)
+	}
+	if pool.rateLimitHits != 3 {
+		t.Fatalf("expected 3 rate limit hits, got %d", pool.rateLimitHits)
 	}
 }
 
@@ -120,13 +388,61 @@ func TestWorkerPool_HandleOrder_OrderNotRegistered(t *testing.T) {
 
 	repo := mocks.NewMockRepository(ctrl)
 
-	order := &model.Order{Number: "4"}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	order := &model.Order{Number: "4", PollAttempts: 2, UploadedAt: now.Add(-time.Minute)}
+
+	// Third failed poll: base 1s doubled twice
+	repo.EXPECT().ScheduleOrderRetry(gomock.Any(), order.Number, now.Add(4*time.Second), ErrOrderNotRegistered.Error()).Return(nil)
 
 	client := clientFunc(func(ctx context.Context, number string) (*Response, error) {
 		return nil, ErrOrderNotRegistered
 	})
 
-	pool := NewWorkerPool(repo, client, logger.NewNop(), time.Second, 1)
+	pool := NewWorkerPool(repo, client, logger.NewNop(), time.Second, 1,
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 10, BackoffBase: time.Second, BackoffMax: time.Minute}))
+	pool.now = func() time.Time { return now }
+	pool.handleOrder(context.Background(), order, 0)
+}
+
+func TestWorkerPool_HandleOrder_ParkedAfterMaxAttempts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockRepository(ctrl)
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	order := &model.Order{Number: "6", PollAttempts: 2, UploadedAt: now.Add(-time.Minute)}
+
+	repo.EXPECT().ParkOrder(gomock.Any(), order.Number, ErrOrderNotRegistered.Error()).Return(nil)
+
+	client := clientFunc(func(ctx context.Context, number string) (*Response, error) {
+		return nil, ErrOrderNotRegistered
+	})
+
+	pool := NewWorkerPool(repo, client, logger.NewNop(), time.Second, 1,
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BackoffBase: time.Second, BackoffMax: time.Minute}))
+	pool.now = func() time.Time { return now }
+	pool.handleOrder(context.Background(), order, 0)
+}
+
+func TestWorkerPool_HandleOrder_ParkedAfterMaxAge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockRepository(ctrl)
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	order := &model.Order{Number: "7", UploadedAt: now.Add(-48 * time.Hour)}
+
+	repo.EXPECT().ParkOrder(gomock.Any(), order.Number, gomock.Any()).Return(nil)
+
+	client := clientFunc(func(ctx context.Context, number string) (*Response, error) {
+		return nil, errors.New("connection refused")
+	})
+
+	pool := NewWorkerPool(repo, client, logger.NewNop(), time.Second, 1,
+		WithRetryPolicy(RetryPolicy{MaxAge: 24 * time.Hour}))
+	pool.now = func() time.Time { return now }
 	pool.handleOrder(context.Background(), order, 0)
 }
 
@@ -136,7 +452,9 @@ func TestWorkerPool_HandleOrder_UnknownError(t *testing.T) {
 
 	repo := mocks.NewMockRepository(ctrl)
 
-	order := &model.Order{Number: "5"}
+	order := &model.Order{Number: "5", UploadedAt: time.Now()}
+
+	repo.EXPECT().ScheduleOrderRetry(gomock.Any(), order.Number, gomock.Any(), "some error").Return(nil)
 
 	client := clientFunc(func(ctx context.Context, number string) (*Response, error) {
 		return nil, errors.New("some error")
@@ -146,6 +464,27 @@ func TestWorkerPool_HandleOrder_UnknownError(t *testing.T) {
 	pool.handleOrder(context.Background(), order, 0)
 }
 
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{BackoffBase: time.Second, BackoffMax: 10 * time.Second}
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{100, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := policy.Backoff(tt.attempt); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
 func TestMapStatus(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -156,8 +495,11 @@ func TestMapStatus(t *testing.T) {
 	}{
 		{"registered", StatusRegistered, 0, model.OrderStatusProcessing, model.Amount(0)},
 		{"processing", StatusProcessing, 0, model.OrderStatusProcessing, model.Amount(0)},
+		{"processing with expected accrual", StatusProcessing, 7.5, model.OrderStatusProcessing, model.FromFloat64(7.5)},
 		{"invalid", StatusInvalid, 0, model.OrderStatusInvalid, model.Amount(0)},
 		{"processed", StatusProcessed, 12.5, model.OrderStatusProcessed, model.FromFloat64(12.5)},
+		{"adjusted", StatusAdjusted, 4, model.OrderStatusProcessed, model.FromFloat64(4)},
+		{"reversed", StatusReversed, 0, model.OrderStatusProcessed, model.Amount(0)},
 		{"unknown", "UNKNOWN", 0, "", model.Amount(0)},
 	}
 
@@ -173,3 +515,215 @@ func TestMapStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestWorkerPool_PollNow(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockRepository(ctrl)
+
+	parkedAt := time.Now()
+	parked := &model.Order{Number: "1", Status: model.OrderStatusNew, PollAttempts: 20, ParkedAt: &parkedAt}
+	processed := &model.Order{Number: "1", Status: model.OrderStatusProcessed, Accrual: model.Amount(500)}
+
+	gomock.InOrder(
+		repo.EXPECT().GetOrderForPoll(gomock.Any(), "1").Return(parked, nil),
+		repo.EXPECT().UpdateOrderStatus(gomock.Any(), "1", model.OrderStatusProcessed, model.Amount(500)).Return(nil),
+		repo.EXPECT().GetOrderForPoll(gomock.Any(), "1").Return(processed, nil),
+	)
+
+	client := clientFunc(func(ctx context.Context, number string) (*Response, error) {
+		return &Response{Order: number, Status: StatusProcessed, Accrual: 5}, nil
+	})
+	pool := NewWorkerPool(repo, client, logger.NewNop(), time.Second, 1)
+
+	order, err := pool.PollNow(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if order.Status != model.OrderStatusProcessed {
+		t.Fatalf("expected processed order, got %s", order.Status)
+	}
+	if stats := pool.Stats(); stats.InFlight != 0 || stats.LastPoll.IsZero() {
+		t.Fatalf("unexpected stats after poll: %+v", stats)
+	}
+}
+
+func TestWorkerPool_PollNowRecheck(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockRepository(ctrl)
+
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	processedAt := now.Add(-24 * time.Hour)
+	processed := &model.Order{Number: "1", Status: model.OrderStatusProcessed, Accrual: model.Amount(500), ProcessedAt: &processedAt}
+	rechecked := &model.Order{Number: "1", Status: model.OrderStatusProcessed, Accrual: model.Amount(300), ProcessedAt: &processedAt}
+
+	gomock.InOrder(
+		repo.EXPECT().GetOrderForPoll(gomock.Any(), "1").Return(processed, nil),
+		repo.EXPECT().RecheckOrder(gomock.Any(), "1", model.Amount(300), model.NegativeBalanceDebt).Return(model.Amount(-200), nil),
+		repo.EXPECT().GetOrderForPoll(gomock.Any(), "1").Return(rechecked, nil),
+	)
+
+	client := clientFunc(func(ctx context.Context, number string) (*Response, error) {
+		return &Response{Order: number, Status: StatusAdjusted, Accrual: 3}, nil
+	})
+	pool := NewWorkerPool(repo, client, logger.NewNop(), time.Second, 1,
+		WithRecheck(model.RecheckPolicy{Window: 7 * 24 * time.Hour, Interval: time.Hour}, model.NegativeBalanceDebt))
+	pool.now = func() time.Time { return now }
+
+	order, err := pool.PollNow(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if order.Accrual != model.Amount(300) {
+		t.Fatalf("expected rechecked accrual, got %d", order.Accrual)
+	}
+}
+
+func TestWorkerPool_PollNowRejected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockRepository(ctrl)
+	repo.EXPECT().GetOrderForPoll(gomock.Any(), "1").Return(&model.Order{Number: "1", Status: model.OrderStatusInvalid}, nil)
+	processedAt := time.Now().Add(-30 * 24 * time.Hour)
+	repo.EXPECT().GetOrderForPoll(gomock.Any(), "4").Return(&model.Order{Number: "4", Status: model.OrderStatusProcessed, ProcessedAt: &processedAt}, nil)
+	repo.EXPECT().GetOrderForPoll(gomock.Any(), "2").Return(&model.Order{Number: "2", Status: model.OrderStatusProcessing}, nil)
+	repo.EXPECT().GetOrderForPoll(gomock.Any(), "3").Return(&model.Order{Number: "3", Status: model.OrderStatusProcessing}, nil)
+
+	client := clientFunc(func(ctx context.Context, number string) (*Response, error) {
+		return nil, &TooManyRequestsError{RetryAfter: time.Minute}
+	})
+	pool := NewWorkerPool(repo, client, logger.NewNop(), time.Second, 1,
+		WithRecheck(model.RecheckPolicy{Window: 7 * 24 * time.Hour, Interval: time.Hour}, model.NegativeBalanceDebt))
+
+	if _, err := pool.PollNow(context.Background(), "1"); !errors.Is(err, ErrOrderFinal) {
+		t.Fatalf("expected ErrOrderFinal, got %v", err)
+	}
+	// A processed order past the recheck window is final as well
+	if _, err := pool.PollNow(context.Background(), "4"); !errors.Is(err, ErrOrderFinal) {
+		t.Fatalf("expected ErrOrderFinal, got %v", err)
+	}
+
+	pool.acquire("2")
+	if _, err := pool.PollNow(context.Background(), "2"); !errors.Is(err, ErrOrderInFlight) {
+		t.Fatalf("expected ErrOrderInFlight, got %v", err)
+	}
+
+	var tmr *TooManyRequestsError
+	if _, err := pool.PollNow(context.Background(), "3"); !errors.As(err, &tmr) {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+}
+
+func TestWorkerPool_StatsRateLimitBackoff(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pool := NewWorkerPool(nil, nil, logger.NewNop(), time.Second, 1)
+	pool.now = func() time.Time { return now }
+
+	pool.setRateLimited(time.Minute)
+	now = now.Add(20 * time.Second)
+	if got := pool.Stats().RateLimitBackoff; got != 40*time.Second {
+		t.Fatalf("expected 40s backoff left, got %s", got)
+	}
+
+	now = now.Add(time.Minute)
+	if got := pool.Stats().RateLimitBackoff; got != 0 {
+		t.Fatalf("expected no backoff, got %s", got)
+	}
+}
+
+func TestWorkerPool_ScalesWithoutDroppingJobs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockRepository(ctrl)
+
+	var (
+		mu      sync.Mutex
+		updated = make(map[string]bool)
+	)
+	repo.EXPECT().UpdateOrderStatus(gomock.Any(), gomock.Any(), model.OrderStatusProcessed, gomock.Any()).
+		DoAndReturn(func(ctx context.Context, number string, status model.OrderStatus, accrual model.Amount) error {
+			mu.Lock()
+			updated[number] = true
+			mu.Unlock()
+			return nil
+		}).Times(4)
+	repo.EXPECT().ReleaseOrderLeases(gomock.Any(), gomock.Any()).Return(nil)
+
+	gate := make(chan struct{})
+	started := make(chan struct{}, 4)
+	client := clientFunc(func(ctx context.Context, number string) (*Response, error) {
+		started <- struct{}{}
+		<-gate
+		return &Response{Order: number, Status: StatusProcessed, Accrual: 1}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := NewWorkerPool(repo, client, logger.NewNop(), time.Hour, 1, WithWorkerBounds(1, 3))
+	pool.scaleInterval = time.Hour
+	pool.Run(ctx)
+
+	for _, number := range []string{"1", "2", "3", "4"} {
+		pool.acquire(number)
+		pool.jobs <- &model.Order{Number: number}
+	}
+	<-started
+
+	// Backlog adds workers up to the maximum
+	for i := 0; i < 3; i++ {
+		pool.scale(ctx)
+	}
+	if workers := pool.Stats().Workers; workers != 3 {
+		t.Fatalf("expected 3 workers, got %d", workers)
+	}
+	<-started
+	<-started
+
+	// A 429 takes one away even with a backlog
+	pool.mu.Lock()
+	pool.rateLimitHits++
+	pool.mu.Unlock()
+	pool.scale(ctx)
+	if workers := pool.Stats().Workers; workers != 2 {
+		t.Fatalf("expected 2 workers after rate limiting, got %d", workers)
+	}
+
+	close(gate)
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats().InFlight > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("orders left in flight: %+v", pool.Stats())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Idle workers are removed down to the minimum
+	pool.scale(ctx)
+	pool.scale(ctx)
+	if workers := pool.Stats().Workers; workers != 1 {
+		t.Fatalf("expected 1 idle worker, got %d", workers)
+	}
+
+	cancel()
+	pool.Wait()
+
+	if len(updated) != 4 {
+		t.Fatalf("expected all 4 orders handled, got %v", updated)
+	}
+}
+
+func TestNewWorkerPool_WorkerBounds(t *testing.T) {
+	pool := NewWorkerPool(nil, nil, logger.NewNop(), time.Second, 1, WithWorkerBounds(2, 8))
+	if pool.minWorkers != 2 || pool.maxWorkers != 8 || pool.workers != 2 || cap(pool.jobs) != 16 {
+		t.Fatalf("unexpected bounds: min %d max %d workers %d queue %d", pool.minWorkers, pool.maxWorkers, pool.workers, cap(pool.jobs))
+	}
+
+	pool = NewWorkerPool(nil, nil, logger.NewNop(), time.Second, 3)
+	if pool.minWorkers != 3 || pool.maxWorkers != 3 {
+		t.Fatalf("expected fixed pool of 3, got min %d max %d", pool.minWorkers, pool.maxWorkers)
+	}
+}
@@ -0,0 +1,60 @@
+package accrual
+
+import (
+	"time"
+
+	"github.com/prbllm/go-loyalty-service/internal/config"
+)
+
+// RetryPolicy controls how a failed accrual poll of an order is rescheduled.
+// An order that runs out of attempts or gets too old is parked until an operator re-drives it.
+type RetryPolicy struct {
+	MaxAttempts int           // failed polls before the order is parked, 0 = unlimited
+	MaxAge      time.Duration // order age after which a failed order is parked, 0 = unlimited
+	BackoffBase time.Duration // delay after the first failed poll
+	BackoffMax  time.Duration // upper bound of the delay
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: config.DefaultPollMaxAttempts,
+		MaxAge:      config.DefaultPollMaxAge,
+		BackoffBase: config.DefaultPollBackoffBase,
+		BackoffMax:  config.DefaultPollBackoffMax,
+	}
+}
+
+// Backoff returns the delay before the given failed attempt (starting at 1) is retried:
+// BackoffBase doubled on every attempt, capped at BackoffMax.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BackoffBase
+	for i := 1; i < attempt && delay < p.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > p.BackoffMax {
+		delay = p.BackoffMax
+	}
+	return delay
+}
+
+// Exhausted reports whether an order with the given failed attempts and age must be parked.
+func (p RetryPolicy) Exhausted(attempts int, age time.Duration) bool {
+	if p.MaxAttempts > 0 && attempts >= p.MaxAttempts {
+		return true
+	}
+	return p.MaxAge > 0 && age >= p.MaxAge
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	defaults := DefaultRetryPolicy()
+	if p.BackoffBase <= 0 {
+		p.BackoffBase = defaults.BackoffBase
+	}
+	if p.BackoffMax <= 0 {
+		p.BackoffMax = defaults.BackoffMax
+	}
+	if p.BackoffMax < p.BackoffBase {
+		p.BackoffMax = p.BackoffBase
+	}
+	return p
+}
@@ -10,6 +10,14 @@ import (
 
 type Service interface {
 	GetBalance(ctx context.Context, userID int64) (*model.Balance, error)
-	GetWithdrawals(ctx context.Context, userID int64) ([]*model.Withdrawal, error)
+	GetWithdrawals(ctx context.Context, userID int64, filter model.WithdrawalFilter) ([]*model.Withdrawal, error)
 	Withdraw(ctx context.Context, userID int64, orderNumber string, amount model.Amount) error
+	Reverse(ctx context.Context, userID int64, orderNumber, reason string) (*model.Withdrawal, error)
+	Transfer(ctx context.Context, senderID int64, recipientLogin string, amount model.Amount) (*model.Transfer, error)
+	Hold(ctx context.Context, userID int64, orderNumber string, amount model.Amount) (*model.Hold, error)
+	Capture(ctx context.Context, userID, holdID int64) (*model.Hold, error)
+	Release(ctx context.Context, userID, holdID int64) (*model.Hold, error)
+	ExpireHolds(ctx context.Context) (int64, error)
+	ExpirePoints(ctx context.Context) (model.Amount, error)
+	GetTransactions(ctx context.Context, userID int64, filter model.TransactionFilter) ([]*model.Transaction, error)
 }
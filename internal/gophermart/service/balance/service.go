@@ -2,32 +2,150 @@ package balance
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"github.com/prbllm/go-loyalty-service/internal/config"
 	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
 	"github.com/prbllm/go-loyalty-service/internal/gophermart/repository"
 	"github.com/prbllm/go-loyalty-service/internal/logger"
 )
 
 type service struct {
-	repo   repository.Repository
-	logger logger.Logger
+	repo    repository.Repository
+	logger  logger.Logger
+	holdTTL time.Duration
+
+	validityMonths int
+	expiringSoon   time.Duration
+	now            func() time.Time
+
+	transferLimits model.TransferLimits
+}
+
+type Option func(*service)
+
+// WithHoldTTL sets how long a hold reserves points; zero keeps the default
+func WithHoldTTL(ttl time.Duration) Option {
+	return func(s *service) {
+		if ttl > 0 {
+			s.holdTTL = ttl
+		}
+	}
+}
+
+// WithPointsExpiry sets how many months points stay valid and how early they are reported as
+// expiring soon; zero values keep the defaults
+func WithPointsExpiry(validityMonths int, expiringSoon time.Duration) Option {
+	return func(s *service) {
+		if validityMonths > 0 {
+			s.validityMonths = validityMonths
+		}
+		if expiringSoon > 0 {
+			s.expiringSoon = expiringSoon
+		}
+	}
+}
+
+// WithTransferLimits sets the daily transfer limits of a user; zero fields disable a limit
+func WithTransferLimits(limits model.TransferLimits) Option {
+	return func(s *service) {
+		s.transferLimits = limits
+	}
 }
 
-func New(repo repository.Repository, logger logger.Logger) Service {
-	return &service{
-		repo:   repo,
-		logger: logger,
+func New(repo repository.Repository, logger logger.Logger, opts ...Option) Service {
+	s := &service{
+		repo:           repo,
+		logger:         logger,
+		holdTTL:        config.DefaultHoldTTL,
+		validityMonths: config.DefaultPointsValidityMonths,
+		expiringSoon:   config.DefaultPointsExpiringSoon,
+		now:            time.Now,
+		transferLimits: model.TransferLimits{
+			DailySum:   model.FromFloat64(config.DefaultTransferDailyLimit),
+			DailyCount: config.DefaultTransferDailyCount,
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 func (s *service) GetBalance(ctx context.Context, userID int64) (*model.Balance, error) {
-	return s.repo.GetBalance(ctx, userID)
+	bal, err := s.repo.GetBalance(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Points earned before this moment expire within the warning window
+	bal.ExpiringSoon, err = s.repo.GetExpiringPoints(ctx, userID, s.expiryCutoff().Add(s.expiringSoon))
+	if err != nil {
+		return nil, err
+	}
+	return bal, nil
 }
 
-func (s *service) GetWithdrawals(ctx context.Context, userID int64) ([]*model.Withdrawal, error) {
-	return s.repo.GetWithdrawals(ctx, userID)
+func (s *service) GetWithdrawals(ctx context.Context, userID int64, filter model.WithdrawalFilter) ([]*model.Withdrawal, error) {
+	return s.repo.GetWithdrawals(ctx, userID, filter)
 }
 
 func (s *service) Withdraw(ctx context.Context, userID int64, orderNumber string, amount model.Amount) error {
-	return s.repo.WithdrawBalance(ctx, userID, orderNumber, amount)
+	err := s.repo.WithdrawBalance(ctx, userID, orderNumber, amount)
+	if errors.Is(err, repository.ErrWithdrawalExists) {
+		// A retry of a withdrawal that already went through succeeds without debiting again
+		s.logger.Infof("withdrawal for order %s of user %d already exists", orderNumber, userID)
+		return nil
+	}
+	return err
+}
+
+func (s *service) Reverse(ctx context.Context, userID int64, orderNumber, reason string) (*model.Withdrawal, error) {
+	w, err := s.repo.ReverseWithdrawal(ctx, userID, orderNumber, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Infof("withdrawal %d for order %s of user %d reversed, %.2f credited back", w.ID, orderNumber, userID, w.Sum.ToFloat64())
+	return w, nil
+}
+
+func (s *service) Transfer(ctx context.Context, senderID int64, recipientLogin string, amount model.Amount) (*model.Transfer, error) {
+	t, err := s.repo.Transfer(ctx, senderID, recipientLogin, amount, s.transferLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Infof("user %d transferred %.2f to user %d", senderID, amount.ToFloat64(), t.RecipientID)
+	return t, nil
+}
+
+func (s *service) Hold(ctx context.Context, userID int64, orderNumber string, amount model.Amount) (*model.Hold, error) {
+	return s.repo.HoldBalance(ctx, userID, orderNumber, amount, s.holdTTL)
+}
+
+func (s *service) Capture(ctx context.Context, userID, holdID int64) (*model.Hold, error) {
+	return s.repo.CaptureHold(ctx, userID, holdID)
+}
+
+func (s *service) Release(ctx context.Context, userID, holdID int64) (*model.Hold, error) {
+	return s.repo.ReleaseHold(ctx, userID, holdID)
+}
+
+func (s *service) ExpireHolds(ctx context.Context) (int64, error) {
+	return s.repo.ExpireHolds(ctx)
+}
+
+func (s *service) ExpirePoints(ctx context.Context) (model.Amount, error) {
+	return s.repo.ExpirePoints(ctx, s.expiryCutoff())
+}
+
+// expiryCutoff is the earning time before which points are no longer valid
+func (s *service) expiryCutoff() time.Time {
+	return s.now().AddDate(0, -s.validityMonths, 0)
+}
+
+func (s *service) GetTransactions(ctx context.Context, userID int64, filter model.TransactionFilter) ([]*model.Transaction, error) {
+	return s.repo.GetTransactions(ctx, userID, filter)
 }
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/prbllm/go-loyalty-service/internal/config"
 	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
 	"github.com/prbllm/go-loyalty-service/internal/gophermart/repository"
 	mocks "github.com/prbllm/go-loyalty-service/internal/mocks/gophermart"
@@ -21,7 +23,8 @@ func TestGetBalanceAndWithdrawals(t *testing.T) {
 	svc := New(mockRepo, log)
 
 	mockRepo.EXPECT().GetBalance(gomock.Any(), int64(1)).Return(&model.Balance{Current: model.Amount(1000), Withdrawn: model.Amount(200)}, nil)
-	mockRepo.EXPECT().GetWithdrawals(gomock.Any(), int64(1)).Return([]*model.Withdrawal{
+	mockRepo.EXPECT().GetExpiringPoints(gomock.Any(), int64(1), gomock.Any()).Return(model.Amount(300), nil)
+	mockRepo.EXPECT().GetWithdrawals(gomock.Any(), int64(1), model.WithdrawalFilter{}).Return([]*model.Withdrawal{
 		{OrderNumber: "1", Sum: model.Amount(200)},
 	}, nil)
 
@@ -29,11 +32,11 @@ func TestGetBalanceAndWithdrawals(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if bal.Current != model.Amount(1000) || bal.Withdrawn != model.Amount(200) {
+	if bal.Current != model.Amount(1000) || bal.Withdrawn != model.Amount(200) || bal.ExpiringSoon != model.Amount(300) {
 		t.Fatalf("unexpected balance: %+v", bal)
 	}
 
-	list, err := svc.GetWithdrawals(context.Background(), 1)
+	list, err := svc.GetWithdrawals(context.Background(), 1, model.WithdrawalFilter{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -72,3 +75,93 @@ func TestWithdraw_InsufficientFunds(t *testing.T) {
 		t.Fatalf("expected insufficient funds, got %v", err)
 	}
 }
+
+func TestWithdraw_RepeatedOrderSucceeds(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockRepository(ctrl)
+	log := zaptest.NewLogger(t).Sugar()
+	svc := New(mockRepo, log)
+
+	mockRepo.EXPECT().WithdrawBalance(gomock.Any(), int64(1), "order", model.Amount(500)).Return(repository.ErrWithdrawalExists)
+
+	if err := svc.Withdraw(context.Background(), 1, "order", model.Amount(500)); err != nil {
+		t.Fatalf("expected repeated withdrawal to succeed, got %v", err)
+	}
+}
+
+func TestHold_UsesConfiguredTTL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockRepository(ctrl)
+	log := zaptest.NewLogger(t).Sugar()
+
+	mockRepo.EXPECT().HoldBalance(gomock.Any(), int64(1), "order", model.Amount(500), config.DefaultHoldTTL).Return(&model.Hold{ID: 1}, nil)
+	mockRepo.EXPECT().HoldBalance(gomock.Any(), int64(1), "order", model.Amount(500), time.Hour).Return(&model.Hold{ID: 2}, nil)
+
+	if _, err := New(mockRepo, log).Hold(context.Background(), 1, "order", model.Amount(500)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := New(mockRepo, log, WithHoldTTL(time.Hour)).Hold(context.Background(), 1, "order", model.Amount(500)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestPointsExpiry_Cutoffs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockRepository(ctrl)
+	log := zaptest.NewLogger(t).Sugar()
+	svc := New(mockRepo, log, WithPointsExpiry(6, 7*24*time.Hour)).(*service)
+	now := time.Date(2024, 8, 31, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	// Points earned six months ago expire now; a week later the next ones will
+	cutoff := now.AddDate(0, -6, 0)
+	mockRepo.EXPECT().GetBalance(gomock.Any(), int64(1)).Return(&model.Balance{Current: model.Amount(1000)}, nil)
+	mockRepo.EXPECT().GetExpiringPoints(gomock.Any(), int64(1), cutoff.Add(7*24*time.Hour)).Return(model.Amount(400), nil)
+	mockRepo.EXPECT().ExpirePoints(gomock.Any(), cutoff).Return(model.Amount(250), nil)
+
+	bal, err := svc.GetBalance(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bal.ExpiringSoon != model.Amount(400) {
+		t.Fatalf("unexpected expiring points: %+v", bal)
+	}
+
+	expired, err := svc.ExpirePoints(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expired != model.Amount(250) {
+		t.Fatalf("expected 250 expired, got %d", expired)
+	}
+}
+
+func TestTransfer_UsesConfiguredLimits(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockRepository(ctrl)
+	log := zaptest.NewLogger(t).Sugar()
+
+	defaults := model.TransferLimits{
+		DailySum:   model.FromFloat64(config.DefaultTransferDailyLimit),
+		DailyCount: config.DefaultTransferDailyCount,
+	}
+	unlimited := model.TransferLimits{}
+	mockRepo.EXPECT().Transfer(gomock.Any(), int64(1), "bob", model.Amount(500), defaults).Return(&model.Transfer{ID: 1, RecipientID: 2}, nil)
+	mockRepo.EXPECT().Transfer(gomock.Any(), int64(1), "bob", model.Amount(500), unlimited).Return(nil, repository.ErrTransferToSelf)
+
+	if _, err := New(mockRepo, log).Transfer(context.Background(), 1, "bob", model.Amount(500)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err := New(mockRepo, log, WithTransferLimits(unlimited)).Transfer(context.Background(), 1, "bob", model.Amount(500))
+	if !errors.Is(err, repository.ErrTransferToSelf) {
+		t.Fatalf("expected ErrTransferToSelf, got %v", err)
+	}
+}
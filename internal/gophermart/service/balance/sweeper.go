@@ -0,0 +1,85 @@
+package balance
+
+import (
+	"context"
+	"time"
+
+	"github.com/prbllm/go-loyalty-service/internal/config"
+	"github.com/prbllm/go-loyalty-service/internal/logger"
+)
+
+// Sweeper runs a balance maintenance task every interval until its context is cancelled
+type Sweeper struct {
+	task     func(ctx context.Context)
+	interval time.Duration
+	done     chan struct{}
+}
+
+// NewHoldSweeper releases holds that were neither captured nor released in time.
+// Expired holds already stop reserving points; the sweeper records them as expired.
+func NewHoldSweeper(service Service, interval time.Duration, logger logger.Logger) *Sweeper {
+	if interval <= 0 {
+		interval = config.DefaultHoldSweepInterval
+	}
+	return newSweeper(interval, func(ctx context.Context) {
+		expired, err := service.ExpireHolds(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Errorf("hold sweeper: %v", err)
+			}
+			return
+		}
+		if expired > 0 {
+			logger.Infof("hold sweeper: released %d expired holds", expired)
+		}
+	})
+}
+
+// NewPointsExpirer debits the points that outlived their validity
+func NewPointsExpirer(service Service, interval time.Duration, logger logger.Logger) *Sweeper {
+	if interval <= 0 {
+		interval = config.DefaultPointsExpiryInterval
+	}
+	return newSweeper(interval, func(ctx context.Context) {
+		expired, err := service.ExpirePoints(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Errorf("points expirer: %v", err)
+			}
+			return
+		}
+		if expired > 0 {
+			logger.Infof("points expirer: expired %.2f points", expired.ToFloat64())
+		}
+	})
+}
+
+func newSweeper(interval time.Duration, task func(ctx context.Context)) *Sweeper {
+	return &Sweeper{
+		task:     task,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+// Run runs the task every interval until ctx is cancelled
+func (s *Sweeper) Run(ctx context.Context) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.task(ctx)
+		}
+	}
+}
+
+// Wait blocks until Run has returned
+func (s *Sweeper) Wait() {
+	<-s.done
+}
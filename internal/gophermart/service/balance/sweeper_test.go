@@ -0,0 +1,69 @@
+package balance
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prbllm/go-loyalty-service/internal/gophermart/model"
+	mocks "github.com/prbllm/go-loyalty-service/internal/mocks/gophermart"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestHoldSweeper_ExpiresUntilCancelled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mockService := mocks.NewMockBalanceService(ctrl)
+	mockService.EXPECT().ExpireHolds(gomock.Any()).Return(int64(2), nil)
+	mockService.EXPECT().ExpireHolds(gomock.Any()).DoAndReturn(func(context.Context) (int64, error) {
+		cancel()
+		return 0, nil
+	})
+
+	sweeper := NewHoldSweeper(mockService, time.Millisecond, zaptest.NewLogger(t).Sugar())
+	go sweeper.Run(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		sweeper.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sweeper did not stop after cancel")
+	}
+}
+
+func TestPointsExpirer_ExpiresUntilCancelled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mockService := mocks.NewMockBalanceService(ctrl)
+	mockService.EXPECT().ExpirePoints(gomock.Any()).Return(model.Amount(1500), nil)
+	mockService.EXPECT().ExpirePoints(gomock.Any()).DoAndReturn(func(context.Context) (model.Amount, error) {
+		cancel()
+		return 0, errors.New("connection reset")
+	})
+
+	expirer := NewPointsExpirer(mockService, time.Millisecond, zaptest.NewLogger(t).Sugar())
+	go expirer.Run(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		expirer.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expirer did not stop after cancel")
+	}
+}
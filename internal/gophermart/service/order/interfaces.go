@@ -10,5 +10,9 @@ import (
 
 type Service interface {
 	Upload(ctx context.Context, userID int64, number string) error
-	List(ctx context.Context, userID int64) ([]*model.Order, error)
+	List(ctx context.Context, userID int64, filter model.OrderFilter) ([]*model.Order, error)
+
+	ListParked(ctx context.Context) ([]*model.Order, error)
+	Redrive(ctx context.Context, number string) error
+	CountByStatus(ctx context.Context) (map[model.OrderStatus]int64, error)
 }
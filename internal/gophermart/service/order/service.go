@@ -68,6 +68,22 @@ func (s *service) resolveExistingOrder(ctx context.Context, userID int64, number
 	return ErrOrderUploadedByAnotherUser
 }
 
-func (s *service) List(ctx context.Context, userID int64) ([]*model.Order, error) {
-	return s.repo.GetOrdersByUserID(ctx, userID)
+func (s *service) List(ctx context.Context, userID int64, filter model.OrderFilter) ([]*model.Order, error) {
+	return s.repo.GetOrdersByUserID(ctx, userID, filter)
+}
+
+func (s *service) ListParked(ctx context.Context) ([]*model.Order, error) {
+	return s.repo.GetParkedOrders(ctx)
+}
+
+func (s *service) Redrive(ctx context.Context, number string) error {
+	if err := s.repo.RedriveOrder(ctx, number); err != nil {
+		return err
+	}
+	s.logger.Infof("order %s re-driven by operator", number)
+	return nil
+}
+
+func (s *service) CountByStatus(ctx context.Context) (map[model.OrderStatus]int64, error) {
+	return s.repo.CountOrdersByStatus(ctx)
 }
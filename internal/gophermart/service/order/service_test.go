@@ -136,9 +136,9 @@ func TestListOrders(t *testing.T) {
 		{ID: 1, UserID: 1},
 	}
 
-	repo.EXPECT().GetOrdersByUserID(gomock.Any(), int64(1)).Return(orders, nil)
+	repo.EXPECT().GetOrdersByUserID(gomock.Any(), int64(1), model.OrderFilter{}).Return(orders, nil)
 
-	result, err := svc.List(context.Background(), 1)
+	result, err := svc.List(context.Background(), 1, model.OrderFilter{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -41,6 +41,20 @@ func (m *MockOrderRepository) EXPECT() *MockOrderRepositoryMockRecorder {
 	return m.recorder
 }
 
+// ApplyReturn mocks base method.
+func (m *MockOrderRepository) ApplyReturn(ctx context.Context, order model.Order, prevReturned []model.Good) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApplyReturn", ctx, order, prevReturned)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ApplyReturn indicates an expected call of ApplyReturn.
+func (mr *MockOrderRepositoryMockRecorder) ApplyReturn(ctx, order, prevReturned any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplyReturn", reflect.TypeOf((*MockOrderRepository)(nil).ApplyReturn), ctx, order, prevReturned)
+}
+
 // Create mocks base method.
 func (m *MockOrderRepository) Create(ctx context.Context, order model.Order) error {
 	m.ctrl.T.Helper()
@@ -56,45 +70,60 @@ func (mr *MockOrderRepositoryMockRecorder) Create(ctx, order any) *gomock.Call {
 }
 
 // GetByNumber mocks base method.
-func (m *MockOrderRepository) GetByNumber(ctx context.Context, number string) (*model.Order, error) {
+func (m *MockOrderRepository) GetByNumber(ctx context.Context, program, number string) (*model.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetByNumber", ctx, number)
+	ret := m.ctrl.Call(m, "GetByNumber", ctx, program, number)
 	ret0, _ := ret[0].(*model.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetByNumber indicates an expected call of GetByNumber.
-func (mr *MockOrderRepositoryMockRecorder) GetByNumber(ctx, number any) *gomock.Call {
+func (mr *MockOrderRepositoryMockRecorder) GetByNumber(ctx, program, number any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByNumber", reflect.TypeOf((*MockOrderRepository)(nil).GetByNumber), ctx, number)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByNumber", reflect.TypeOf((*MockOrderRepository)(nil).GetByNumber), ctx, program, number)
 }
 
-// IsOrderExists mocks base method.
-func (m *MockOrderRepository) IsOrderExists(ctx context.Context, number string) (bool, error) {
+// GetStats mocks base method.
+func (m *MockOrderRepository) GetStats(ctx context.Context, program string) (map[model.OrderStatus]int64, int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "IsOrderExists", ctx, number)
-	ret0, _ := ret[0].(bool)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "GetStats", ctx, program)
+	ret0, _ := ret[0].(map[model.OrderStatus]int64)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetStats indicates an expected call of GetStats.
+func (mr *MockOrderRepositoryMockRecorder) GetStats(ctx, program any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStats", reflect.TypeOf((*MockOrderRepository)(nil).GetStats), ctx, program)
+}
+
+// SetProcessed mocks base method.
+func (m *MockOrderRepository) SetProcessed(ctx context.Context, program, number string, accrual int64, breakdown []model.AccrualItem) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetProcessed", ctx, program, number, accrual, breakdown)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// IsOrderExists indicates an expected call of IsOrderExists.
-func (mr *MockOrderRepositoryMockRecorder) IsOrderExists(ctx, number any) *gomock.Call {
+// SetProcessed indicates an expected call of SetProcessed.
+func (mr *MockOrderRepositoryMockRecorder) SetProcessed(ctx, program, number, accrual, breakdown any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsOrderExists", reflect.TypeOf((*MockOrderRepository)(nil).IsOrderExists), ctx, number)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetProcessed", reflect.TypeOf((*MockOrderRepository)(nil).SetProcessed), ctx, program, number, accrual, breakdown)
 }
 
 // UpdateStatusAndAccrual mocks base method.
-func (m *MockOrderRepository) UpdateStatusAndAccrual(ctx context.Context, number string, status model.OrderStatus, accrual *int64) error {
+func (m *MockOrderRepository) UpdateStatusAndAccrual(ctx context.Context, program, number string, status model.OrderStatus, accrual *int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateStatusAndAccrual", ctx, number, status, accrual)
+	ret := m.ctrl.Call(m, "UpdateStatusAndAccrual", ctx, program, number, status, accrual)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateStatusAndAccrual indicates an expected call of UpdateStatusAndAccrual.
-func (mr *MockOrderRepositoryMockRecorder) UpdateStatusAndAccrual(ctx, number, status, accrual any) *gomock.Call {
+func (mr *MockOrderRepositoryMockRecorder) UpdateStatusAndAccrual(ctx, program, number, status, accrual any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStatusAndAccrual", reflect.TypeOf((*MockOrderRepository)(nil).UpdateStatusAndAccrual), ctx, number, status, accrual)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStatusAndAccrual", reflect.TypeOf((*MockOrderRepository)(nil).UpdateStatusAndAccrual), ctx, program, number, status, accrual)
 }
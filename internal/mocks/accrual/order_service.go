@@ -12,6 +12,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	model "github.com/prbllm/go-loyalty-service/internal/accrual/model"
 	gomock "go.uber.org/mock/gomock"
@@ -42,30 +43,112 @@ func (m *MockOrderService) EXPECT() *MockOrderServiceMockRecorder {
 }
 
 // GetOrder mocks base method.
-func (m *MockOrderService) GetOrder(ctx context.Context, number string) (*model.Order, error) {
+func (m *MockOrderService) GetOrder(ctx context.Context, program, number string) (*model.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrder", ctx, number)
+	ret := m.ctrl.Call(m, "GetOrder", ctx, program, number)
 	ret0, _ := ret[0].(*model.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetOrder indicates an expected call of GetOrder.
-func (mr *MockOrderServiceMockRecorder) GetOrder(ctx, number any) *gomock.Call {
+func (mr *MockOrderServiceMockRecorder) GetOrder(ctx, program, number any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockOrderService)(nil).GetOrder), ctx, number)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockOrderService)(nil).GetOrder), ctx, program, number)
+}
+
+// GetStats mocks base method.
+func (m *MockOrderService) GetStats(ctx context.Context, program string) (*model.ProgramStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStats", ctx, program)
+	ret0, _ := ret[0].(*model.ProgramStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStats indicates an expected call of GetStats.
+func (mr *MockOrderServiceMockRecorder) GetStats(ctx, program any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStats", reflect.TypeOf((*MockOrderService)(nil).GetStats), ctx, program)
 }
 
 // RegisterOrder mocks base method.
-func (m *MockOrderService) RegisterOrder(ctx context.Context, reqOrder model.RegisterOrderRequest) error {
+func (m *MockOrderService) RegisterOrder(ctx context.Context, program string, reqOrder model.RegisterOrderRequest) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "RegisterOrder", ctx, reqOrder)
+	ret := m.ctrl.Call(m, "RegisterOrder", ctx, program, reqOrder)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // RegisterOrder indicates an expected call of RegisterOrder.
-func (mr *MockOrderServiceMockRecorder) RegisterOrder(ctx, reqOrder any) *gomock.Call {
+func (mr *MockOrderServiceMockRecorder) RegisterOrder(ctx, program, reqOrder any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterOrder", reflect.TypeOf((*MockOrderService)(nil).RegisterOrder), ctx, program, reqOrder)
+}
+
+// ReturnGoods mocks base method.
+func (m *MockOrderService) ReturnGoods(ctx context.Context, program, number string, reqReturn model.ReturnGoodsRequest) (*model.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReturnGoods", ctx, program, number, reqReturn)
+	ret0, _ := ret[0].(*model.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReturnGoods indicates an expected call of ReturnGoods.
+func (mr *MockOrderServiceMockRecorder) ReturnGoods(ctx, program, number, reqReturn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReturnGoods", reflect.TypeOf((*MockOrderService)(nil).ReturnGoods), ctx, program, number, reqReturn)
+}
+
+// MockProcessingFaults is a mock of ProcessingFaults interface.
+type MockProcessingFaults struct {
+	ctrl     *gomock.Controller
+	recorder *MockProcessingFaultsMockRecorder
+	isgomock struct{}
+}
+
+// MockProcessingFaultsMockRecorder is the mock recorder for MockProcessingFaults.
+type MockProcessingFaultsMockRecorder struct {
+	mock *MockProcessingFaults
+}
+
+// NewMockProcessingFaults creates a new mock instance.
+func NewMockProcessingFaults(ctrl *gomock.Controller) *MockProcessingFaults {
+	mock := &MockProcessingFaults{ctrl: ctrl}
+	mock.recorder = &MockProcessingFaultsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProcessingFaults) EXPECT() *MockProcessingFaultsMockRecorder {
+	return m.recorder
+}
+
+// ProcessingDelay mocks base method.
+func (m *MockProcessingFaults) ProcessingDelay() time.Duration {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProcessingDelay")
+	ret0, _ := ret[0].(time.Duration)
+	return ret0
+}
+
+// ProcessingDelay indicates an expected call of ProcessingDelay.
+func (mr *MockProcessingFaultsMockRecorder) ProcessingDelay() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessingDelay", reflect.TypeOf((*MockProcessingFaults)(nil).ProcessingDelay))
+}
+
+// ShouldInvalidate mocks base method.
+func (m *MockProcessingFaults) ShouldInvalidate() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ShouldInvalidate")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// ShouldInvalidate indicates an expected call of ShouldInvalidate.
+func (mr *MockProcessingFaultsMockRecorder) ShouldInvalidate() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterOrder", reflect.TypeOf((*MockOrderService)(nil).RegisterOrder), ctx, reqOrder)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShouldInvalidate", reflect.TypeOf((*MockProcessingFaults)(nil).ShouldInvalidate))
 }
@@ -41,6 +41,21 @@ func (m *MockRewardRepository) EXPECT() *MockRewardRepositoryMockRecorder {
 	return m.recorder
 }
 
+// Count mocks base method.
+func (m *MockRewardRepository) Count(ctx context.Context, program string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Count", ctx, program)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Count indicates an expected call of Count.
+func (mr *MockRewardRepositoryMockRecorder) Count(ctx, program any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockRewardRepository)(nil).Count), ctx, program)
+}
+
 // Create mocks base method.
 func (m *MockRewardRepository) Create(ctx context.Context, rule model.RewardRule) error {
 	m.ctrl.T.Helper()
@@ -56,31 +71,31 @@ func (mr *MockRewardRepositoryMockRecorder) Create(ctx, rule any) *gomock.Call {
 }
 
 // ExistsByMatch mocks base method.
-func (m *MockRewardRepository) ExistsByMatch(ctx context.Context, match string) (bool, error) {
+func (m *MockRewardRepository) ExistsByMatch(ctx context.Context, program, match, segment string) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ExistsByMatch", ctx, match)
+	ret := m.ctrl.Call(m, "ExistsByMatch", ctx, program, match, segment)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ExistsByMatch indicates an expected call of ExistsByMatch.
-func (mr *MockRewardRepositoryMockRecorder) ExistsByMatch(ctx, match any) *gomock.Call {
+func (mr *MockRewardRepositoryMockRecorder) ExistsByMatch(ctx, program, match, segment any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExistsByMatch", reflect.TypeOf((*MockRewardRepository)(nil).ExistsByMatch), ctx, match)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExistsByMatch", reflect.TypeOf((*MockRewardRepository)(nil).ExistsByMatch), ctx, program, match, segment)
 }
 
-// GetAll mocks base method.
-func (m *MockRewardRepository) GetAll(ctx context.Context) ([]model.RewardRule, error) {
+// GetBySegment mocks base method.
+func (m *MockRewardRepository) GetBySegment(ctx context.Context, program, segment string) ([]model.RewardRule, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAll", ctx)
+	ret := m.ctrl.Call(m, "GetBySegment", ctx, program, segment)
 	ret0, _ := ret[0].([]model.RewardRule)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAll indicates an expected call of GetAll.
-func (mr *MockRewardRepositoryMockRecorder) GetAll(ctx any) *gomock.Call {
+// GetBySegment indicates an expected call of GetBySegment.
+func (mr *MockRewardRepositoryMockRecorder) GetBySegment(ctx, program, segment any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockRewardRepository)(nil).GetAll), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBySegment", reflect.TypeOf((*MockRewardRepository)(nil).GetBySegment), ctx, program, segment)
 }
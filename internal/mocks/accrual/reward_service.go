@@ -42,15 +42,15 @@ func (m *MockRewardService) EXPECT() *MockRewardServiceMockRecorder {
 }
 
 // RegisterReward mocks base method.
-func (m *MockRewardService) RegisterReward(ctx context.Context, reward model.RewardRule) error {
+func (m *MockRewardService) RegisterReward(ctx context.Context, program string, reward model.RewardRule) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "RegisterReward", ctx, reward)
+	ret := m.ctrl.Call(m, "RegisterReward", ctx, program, reward)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // RegisterReward indicates an expected call of RegisterReward.
-func (mr *MockRewardServiceMockRecorder) RegisterReward(ctx, reward any) *gomock.Call {
+func (mr *MockRewardServiceMockRecorder) RegisterReward(ctx, program, reward any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterReward", reflect.TypeOf((*MockRewardService)(nil).RegisterReward), ctx, reward)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterReward", reflect.TypeOf((*MockRewardService)(nil).RegisterReward), ctx, program, reward)
 }
@@ -41,6 +41,51 @@ func (m *MockBalanceService) EXPECT() *MockBalanceServiceMockRecorder {
 	return m.recorder
 }
 
+// Capture mocks base method.
+func (m *MockBalanceService) Capture(ctx context.Context, userID, holdID int64) (*model.Hold, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Capture", ctx, userID, holdID)
+	ret0, _ := ret[0].(*model.Hold)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Capture indicates an expected call of Capture.
+func (mr *MockBalanceServiceMockRecorder) Capture(ctx, userID, holdID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Capture", reflect.TypeOf((*MockBalanceService)(nil).Capture), ctx, userID, holdID)
+}
+
+// ExpireHolds mocks base method.
+func (m *MockBalanceService) ExpireHolds(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireHolds", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireHolds indicates an expected call of ExpireHolds.
+func (mr *MockBalanceServiceMockRecorder) ExpireHolds(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireHolds", reflect.TypeOf((*MockBalanceService)(nil).ExpireHolds), ctx)
+}
+
+// ExpirePoints mocks base method.
+func (m *MockBalanceService) ExpirePoints(ctx context.Context) (model.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpirePoints", ctx)
+	ret0, _ := ret[0].(model.Amount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpirePoints indicates an expected call of ExpirePoints.
+func (mr *MockBalanceServiceMockRecorder) ExpirePoints(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpirePoints", reflect.TypeOf((*MockBalanceService)(nil).ExpirePoints), ctx)
+}
+
 // GetBalance mocks base method.
 func (m *MockBalanceService) GetBalance(ctx context.Context, userID int64) (*model.Balance, error) {
 	m.ctrl.T.Helper()
@@ -56,19 +101,94 @@ func (mr *MockBalanceServiceMockRecorder) GetBalance(ctx, userID any) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalance", reflect.TypeOf((*MockBalanceService)(nil).GetBalance), ctx, userID)
 }
 
+// GetTransactions mocks base method.
+func (m *MockBalanceService) GetTransactions(ctx context.Context, userID int64, filter model.TransactionFilter) ([]*model.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransactions", ctx, userID, filter)
+	ret0, _ := ret[0].([]*model.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransactions indicates an expected call of GetTransactions.
+func (mr *MockBalanceServiceMockRecorder) GetTransactions(ctx, userID, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactions", reflect.TypeOf((*MockBalanceService)(nil).GetTransactions), ctx, userID, filter)
+}
+
 // GetWithdrawals mocks base method.
-func (m *MockBalanceService) GetWithdrawals(ctx context.Context, userID int64) ([]*model.Withdrawal, error) {
+func (m *MockBalanceService) GetWithdrawals(ctx context.Context, userID int64, filter model.WithdrawalFilter) ([]*model.Withdrawal, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetWithdrawals", ctx, userID)
+	ret := m.ctrl.Call(m, "GetWithdrawals", ctx, userID, filter)
 	ret0, _ := ret[0].([]*model.Withdrawal)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetWithdrawals indicates an expected call of GetWithdrawals.
-func (mr *MockBalanceServiceMockRecorder) GetWithdrawals(ctx, userID any) *gomock.Call {
+func (mr *MockBalanceServiceMockRecorder) GetWithdrawals(ctx, userID, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawals", reflect.TypeOf((*MockBalanceService)(nil).GetWithdrawals), ctx, userID, filter)
+}
+
+// Hold mocks base method.
+func (m *MockBalanceService) Hold(ctx context.Context, userID int64, orderNumber string, amount model.Amount) (*model.Hold, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Hold", ctx, userID, orderNumber, amount)
+	ret0, _ := ret[0].(*model.Hold)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Hold indicates an expected call of Hold.
+func (mr *MockBalanceServiceMockRecorder) Hold(ctx, userID, orderNumber, amount any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Hold", reflect.TypeOf((*MockBalanceService)(nil).Hold), ctx, userID, orderNumber, amount)
+}
+
+// Release mocks base method.
+func (m *MockBalanceService) Release(ctx context.Context, userID, holdID int64) (*model.Hold, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Release", ctx, userID, holdID)
+	ret0, _ := ret[0].(*model.Hold)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Release indicates an expected call of Release.
+func (mr *MockBalanceServiceMockRecorder) Release(ctx, userID, holdID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockBalanceService)(nil).Release), ctx, userID, holdID)
+}
+
+// Reverse mocks base method.
+func (m *MockBalanceService) Reverse(ctx context.Context, userID int64, orderNumber, reason string) (*model.Withdrawal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reverse", ctx, userID, orderNumber, reason)
+	ret0, _ := ret[0].(*model.Withdrawal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reverse indicates an expected call of Reverse.
+func (mr *MockBalanceServiceMockRecorder) Reverse(ctx, userID, orderNumber, reason any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reverse", reflect.TypeOf((*MockBalanceService)(nil).Reverse), ctx, userID, orderNumber, reason)
+}
+
+// Transfer mocks base method.
+func (m *MockBalanceService) Transfer(ctx context.Context, senderID int64, recipientLogin string, amount model.Amount) (*model.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Transfer", ctx, senderID, recipientLogin, amount)
+	ret0, _ := ret[0].(*model.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Transfer indicates an expected call of Transfer.
+func (mr *MockBalanceServiceMockRecorder) Transfer(ctx, senderID, recipientLogin, amount any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawals", reflect.TypeOf((*MockBalanceService)(nil).GetWithdrawals), ctx, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transfer", reflect.TypeOf((*MockBalanceService)(nil).Transfer), ctx, senderID, recipientLogin, amount)
 }
 
 // Withdraw mocks base method.
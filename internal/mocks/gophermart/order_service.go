@@ -41,19 +41,63 @@ func (m *MockOrderService) EXPECT() *MockOrderServiceMockRecorder {
 	return m.recorder
 }
 
+// CountByStatus mocks base method.
+func (m *MockOrderService) CountByStatus(ctx context.Context) (map[model.OrderStatus]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountByStatus", ctx)
+	ret0, _ := ret[0].(map[model.OrderStatus]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountByStatus indicates an expected call of CountByStatus.
+func (mr *MockOrderServiceMockRecorder) CountByStatus(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountByStatus", reflect.TypeOf((*MockOrderService)(nil).CountByStatus), ctx)
+}
+
 // List mocks base method.
-func (m *MockOrderService) List(ctx context.Context, userID int64) ([]*model.Order, error) {
+func (m *MockOrderService) List(ctx context.Context, userID int64, filter model.OrderFilter) ([]*model.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "List", ctx, userID)
+	ret := m.ctrl.Call(m, "List", ctx, userID, filter)
 	ret0, _ := ret[0].([]*model.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // List indicates an expected call of List.
-func (mr *MockOrderServiceMockRecorder) List(ctx, userID any) *gomock.Call {
+func (mr *MockOrderServiceMockRecorder) List(ctx, userID, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockOrderService)(nil).List), ctx, userID, filter)
+}
+
+// ListParked mocks base method.
+func (m *MockOrderService) ListParked(ctx context.Context) ([]*model.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListParked", ctx)
+	ret0, _ := ret[0].([]*model.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListParked indicates an expected call of ListParked.
+func (mr *MockOrderServiceMockRecorder) ListParked(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListParked", reflect.TypeOf((*MockOrderService)(nil).ListParked), ctx)
+}
+
+// Redrive mocks base method.
+func (m *MockOrderService) Redrive(ctx context.Context, number string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Redrive", ctx, number)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Redrive indicates an expected call of Redrive.
+func (mr *MockOrderServiceMockRecorder) Redrive(ctx, number any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockOrderService)(nil).List), ctx, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Redrive", reflect.TypeOf((*MockOrderService)(nil).Redrive), ctx, number)
 }
 
 // Upload mocks base method.
@@ -12,6 +12,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	model "github.com/prbllm/go-loyalty-service/internal/gophermart/model"
 	gomock "go.uber.org/mock/gomock"
@@ -55,6 +56,36 @@ func (mr *MockRepositoryMockRecorder) AddAccrual(ctx, userID, amount any) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAccrual", reflect.TypeOf((*MockRepository)(nil).AddAccrual), ctx, userID, amount)
 }
 
+// CaptureHold mocks base method.
+func (m *MockRepository) CaptureHold(ctx context.Context, userID, holdID int64) (*model.Hold, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CaptureHold", ctx, userID, holdID)
+	ret0, _ := ret[0].(*model.Hold)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CaptureHold indicates an expected call of CaptureHold.
+func (mr *MockRepositoryMockRecorder) CaptureHold(ctx, userID, holdID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CaptureHold", reflect.TypeOf((*MockRepository)(nil).CaptureHold), ctx, userID, holdID)
+}
+
+// ClaimOrders mocks base method.
+func (m *MockRepository) ClaimOrders(ctx context.Context, owner string, limit int, lease time.Duration, recheck model.RecheckPolicy) ([]*model.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimOrders", ctx, owner, limit, lease, recheck)
+	ret0, _ := ret[0].([]*model.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimOrders indicates an expected call of ClaimOrders.
+func (mr *MockRepositoryMockRecorder) ClaimOrders(ctx, owner, limit, lease, recheck any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimOrders", reflect.TypeOf((*MockRepository)(nil).ClaimOrders), ctx, owner, limit, lease, recheck)
+}
+
 // Close mocks base method.
 func (m *MockRepository) Close() error {
 	m.ctrl.T.Helper()
@@ -69,6 +100,35 @@ func (mr *MockRepositoryMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockRepository)(nil).Close))
 }
 
+// CompleteIdempotencyKey mocks base method.
+func (m *MockRepository) CompleteIdempotencyKey(ctx context.Context, userID int64, key string, statusCode int, contentType string, body []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteIdempotencyKey", ctx, userID, key, statusCode, contentType, body)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteIdempotencyKey indicates an expected call of CompleteIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) CompleteIdempotencyKey(ctx, userID, key, statusCode, contentType, body any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).CompleteIdempotencyKey), ctx, userID, key, statusCode, contentType, body)
+}
+
+// CountOrdersByStatus mocks base method.
+func (m *MockRepository) CountOrdersByStatus(ctx context.Context) (map[model.OrderStatus]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountOrdersByStatus", ctx)
+	ret0, _ := ret[0].(map[model.OrderStatus]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountOrdersByStatus indicates an expected call of CountOrdersByStatus.
+func (mr *MockRepositoryMockRecorder) CountOrdersByStatus(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountOrdersByStatus", reflect.TypeOf((*MockRepository)(nil).CountOrdersByStatus), ctx)
+}
+
 // CreateOrder mocks base method.
 func (m *MockRepository) CreateOrder(ctx context.Context, userID int64, orderNumber string) error {
 	m.ctrl.T.Helper()
@@ -98,6 +158,36 @@ func (mr *MockRepositoryMockRecorder) CreateUser(ctx, login, passwordHash any) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockRepository)(nil).CreateUser), ctx, login, passwordHash)
 }
 
+// ExpireHolds mocks base method.
+func (m *MockRepository) ExpireHolds(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireHolds", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireHolds indicates an expected call of ExpireHolds.
+func (mr *MockRepositoryMockRecorder) ExpireHolds(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireHolds", reflect.TypeOf((*MockRepository)(nil).ExpireHolds), ctx)
+}
+
+// ExpirePoints mocks base method.
+func (m *MockRepository) ExpirePoints(ctx context.Context, earnedBefore time.Time) (model.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpirePoints", ctx, earnedBefore)
+	ret0, _ := ret[0].(model.Amount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpirePoints indicates an expected call of ExpirePoints.
+func (mr *MockRepositoryMockRecorder) ExpirePoints(ctx, earnedBefore any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpirePoints", reflect.TypeOf((*MockRepository)(nil).ExpirePoints), ctx, earnedBefore)
+}
+
 // GetBalance mocks base method.
 func (m *MockRepository) GetBalance(ctx context.Context, userID int64) (*model.Balance, error) {
 	m.ctrl.T.Helper()
@@ -113,6 +203,21 @@ func (mr *MockRepositoryMockRecorder) GetBalance(ctx, userID any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalance", reflect.TypeOf((*MockRepository)(nil).GetBalance), ctx, userID)
 }
 
+// GetExpiringPoints mocks base method.
+func (m *MockRepository) GetExpiringPoints(ctx context.Context, userID int64, earnedBefore time.Time) (model.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExpiringPoints", ctx, userID, earnedBefore)
+	ret0, _ := ret[0].(model.Amount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExpiringPoints indicates an expected call of GetExpiringPoints.
+func (mr *MockRepositoryMockRecorder) GetExpiringPoints(ctx, userID, earnedBefore any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExpiringPoints", reflect.TypeOf((*MockRepository)(nil).GetExpiringPoints), ctx, userID, earnedBefore)
+}
+
 // GetOrderByNumber mocks base method.
 func (m *MockRepository) GetOrderByNumber(ctx context.Context, orderNumber string) (*model.Order, error) {
 	m.ctrl.T.Helper()
@@ -128,6 +233,21 @@ func (mr *MockRepositoryMockRecorder) GetOrderByNumber(ctx, orderNumber any) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderByNumber", reflect.TypeOf((*MockRepository)(nil).GetOrderByNumber), ctx, orderNumber)
 }
 
+// GetOrderForPoll mocks base method.
+func (m *MockRepository) GetOrderForPoll(ctx context.Context, orderNumber string) (*model.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrderForPoll", ctx, orderNumber)
+	ret0, _ := ret[0].(*model.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrderForPoll indicates an expected call of GetOrderForPoll.
+func (mr *MockRepositoryMockRecorder) GetOrderForPoll(ctx, orderNumber any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderForPoll", reflect.TypeOf((*MockRepository)(nil).GetOrderForPoll), ctx, orderNumber)
+}
+
 // GetOrdersByStatus mocks base method.
 func (m *MockRepository) GetOrdersByStatus(ctx context.Context, status model.OrderStatus) ([]*model.Order, error) {
 	m.ctrl.T.Helper()
@@ -144,18 +264,48 @@ func (mr *MockRepositoryMockRecorder) GetOrdersByStatus(ctx, status any) *gomock
 }
 
 // GetOrdersByUserID mocks base method.
-func (m *MockRepository) GetOrdersByUserID(ctx context.Context, userID int64) ([]*model.Order, error) {
+func (m *MockRepository) GetOrdersByUserID(ctx context.Context, userID int64, filter model.OrderFilter) ([]*model.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrdersByUserID", ctx, userID)
+	ret := m.ctrl.Call(m, "GetOrdersByUserID", ctx, userID, filter)
 	ret0, _ := ret[0].([]*model.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetOrdersByUserID indicates an expected call of GetOrdersByUserID.
-func (mr *MockRepositoryMockRecorder) GetOrdersByUserID(ctx, userID any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetOrdersByUserID(ctx, userID, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersByUserID", reflect.TypeOf((*MockRepository)(nil).GetOrdersByUserID), ctx, userID, filter)
+}
+
+// GetParkedOrders mocks base method.
+func (m *MockRepository) GetParkedOrders(ctx context.Context) ([]*model.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetParkedOrders", ctx)
+	ret0, _ := ret[0].([]*model.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetParkedOrders indicates an expected call of GetParkedOrders.
+func (mr *MockRepositoryMockRecorder) GetParkedOrders(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrdersByUserID", reflect.TypeOf((*MockRepository)(nil).GetOrdersByUserID), ctx, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetParkedOrders", reflect.TypeOf((*MockRepository)(nil).GetParkedOrders), ctx)
+}
+
+// GetTransactions mocks base method.
+func (m *MockRepository) GetTransactions(ctx context.Context, userID int64, filter model.TransactionFilter) ([]*model.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransactions", ctx, userID, filter)
+	ret0, _ := ret[0].([]*model.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransactions indicates an expected call of GetTransactions.
+func (mr *MockRepositoryMockRecorder) GetTransactions(ctx, userID, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactions", reflect.TypeOf((*MockRepository)(nil).GetTransactions), ctx, userID, filter)
 }
 
 // GetUserByID mocks base method.
@@ -189,18 +339,178 @@ func (mr *MockRepositoryMockRecorder) GetUserByLogin(ctx, login any) *gomock.Cal
 }
 
 // GetWithdrawals mocks base method.
-func (m *MockRepository) GetWithdrawals(ctx context.Context, userID int64) ([]*model.Withdrawal, error) {
+func (m *MockRepository) GetWithdrawals(ctx context.Context, userID int64, filter model.WithdrawalFilter) ([]*model.Withdrawal, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetWithdrawals", ctx, userID)
+	ret := m.ctrl.Call(m, "GetWithdrawals", ctx, userID, filter)
 	ret0, _ := ret[0].([]*model.Withdrawal)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetWithdrawals indicates an expected call of GetWithdrawals.
-func (mr *MockRepositoryMockRecorder) GetWithdrawals(ctx, userID any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetWithdrawals(ctx, userID, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawals", reflect.TypeOf((*MockRepository)(nil).GetWithdrawals), ctx, userID, filter)
+}
+
+// HoldBalance mocks base method.
+func (m *MockRepository) HoldBalance(ctx context.Context, userID int64, orderNumber string, amount model.Amount, ttl time.Duration) (*model.Hold, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HoldBalance", ctx, userID, orderNumber, amount, ttl)
+	ret0, _ := ret[0].(*model.Hold)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HoldBalance indicates an expected call of HoldBalance.
+func (mr *MockRepositoryMockRecorder) HoldBalance(ctx, userID, orderNumber, amount, ttl any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HoldBalance", reflect.TypeOf((*MockRepository)(nil).HoldBalance), ctx, userID, orderNumber, amount, ttl)
+}
+
+// ParkOrder mocks base method.
+func (m *MockRepository) ParkOrder(ctx context.Context, orderNumber, lastError string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ParkOrder", ctx, orderNumber, lastError)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ParkOrder indicates an expected call of ParkOrder.
+func (mr *MockRepositoryMockRecorder) ParkOrder(ctx, orderNumber, lastError any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParkOrder", reflect.TypeOf((*MockRepository)(nil).ParkOrder), ctx, orderNumber, lastError)
+}
+
+// RecheckOrder mocks base method.
+func (m *MockRepository) RecheckOrder(ctx context.Context, orderNumber string, accrual model.Amount, policy model.NegativeBalancePolicy) (model.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecheckOrder", ctx, orderNumber, accrual, policy)
+	ret0, _ := ret[0].(model.Amount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecheckOrder indicates an expected call of RecheckOrder.
+func (mr *MockRepositoryMockRecorder) RecheckOrder(ctx, orderNumber, accrual, policy any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecheckOrder", reflect.TypeOf((*MockRepository)(nil).RecheckOrder), ctx, orderNumber, accrual, policy)
+}
+
+// RedriveOrder mocks base method.
+func (m *MockRepository) RedriveOrder(ctx context.Context, orderNumber string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RedriveOrder", ctx, orderNumber)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RedriveOrder indicates an expected call of RedriveOrder.
+func (mr *MockRepositoryMockRecorder) RedriveOrder(ctx, orderNumber any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RedriveOrder", reflect.TypeOf((*MockRepository)(nil).RedriveOrder), ctx, orderNumber)
+}
+
+// ReleaseHold mocks base method.
+func (m *MockRepository) ReleaseHold(ctx context.Context, userID, holdID int64) (*model.Hold, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseHold", ctx, userID, holdID)
+	ret0, _ := ret[0].(*model.Hold)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReleaseHold indicates an expected call of ReleaseHold.
+func (mr *MockRepositoryMockRecorder) ReleaseHold(ctx, userID, holdID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseHold", reflect.TypeOf((*MockRepository)(nil).ReleaseHold), ctx, userID, holdID)
+}
+
+// ReleaseIdempotencyKey mocks base method.
+func (m *MockRepository) ReleaseIdempotencyKey(ctx context.Context, userID int64, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseIdempotencyKey", ctx, userID, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseIdempotencyKey indicates an expected call of ReleaseIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) ReleaseIdempotencyKey(ctx, userID, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).ReleaseIdempotencyKey), ctx, userID, key)
+}
+
+// ReleaseOrderLeases mocks base method.
+func (m *MockRepository) ReleaseOrderLeases(ctx context.Context, owner string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseOrderLeases", ctx, owner)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseOrderLeases indicates an expected call of ReleaseOrderLeases.
+func (mr *MockRepositoryMockRecorder) ReleaseOrderLeases(ctx, owner any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseOrderLeases", reflect.TypeOf((*MockRepository)(nil).ReleaseOrderLeases), ctx, owner)
+}
+
+// ReserveIdempotencyKey mocks base method.
+func (m *MockRepository) ReserveIdempotencyKey(ctx context.Context, userID int64, key, fingerprint string) (*model.IdempotencyRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReserveIdempotencyKey", ctx, userID, key, fingerprint)
+	ret0, _ := ret[0].(*model.IdempotencyRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReserveIdempotencyKey indicates an expected call of ReserveIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) ReserveIdempotencyKey(ctx, userID, key, fingerprint any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReserveIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).ReserveIdempotencyKey), ctx, userID, key, fingerprint)
+}
+
+// ReverseWithdrawal mocks base method.
+func (m *MockRepository) ReverseWithdrawal(ctx context.Context, userID int64, orderNumber, reason string) (*model.Withdrawal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReverseWithdrawal", ctx, userID, orderNumber, reason)
+	ret0, _ := ret[0].(*model.Withdrawal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReverseWithdrawal indicates an expected call of ReverseWithdrawal.
+func (mr *MockRepositoryMockRecorder) ReverseWithdrawal(ctx, userID, orderNumber, reason any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReverseWithdrawal", reflect.TypeOf((*MockRepository)(nil).ReverseWithdrawal), ctx, userID, orderNumber, reason)
+}
+
+// ScheduleOrderRetry mocks base method.
+func (m *MockRepository) ScheduleOrderRetry(ctx context.Context, orderNumber string, nextPollAt time.Time, lastError string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScheduleOrderRetry", ctx, orderNumber, nextPollAt, lastError)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ScheduleOrderRetry indicates an expected call of ScheduleOrderRetry.
+func (mr *MockRepositoryMockRecorder) ScheduleOrderRetry(ctx, orderNumber, nextPollAt, lastError any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScheduleOrderRetry", reflect.TypeOf((*MockRepository)(nil).ScheduleOrderRetry), ctx, orderNumber, nextPollAt, lastError)
+}
+
+// Transfer mocks base method.
+func (m *MockRepository) Transfer(ctx context.Context, senderID int64, recipientLogin string, amount model.Amount, limits model.TransferLimits) (*model.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Transfer", ctx, senderID, recipientLogin, amount, limits)
+	ret0, _ := ret[0].(*model.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Transfer indicates an expected call of Transfer.
+func (mr *MockRepositoryMockRecorder) Transfer(ctx, senderID, recipientLogin, amount, limits any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawals", reflect.TypeOf((*MockRepository)(nil).GetWithdrawals), ctx, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transfer", reflect.TypeOf((*MockRepository)(nil).Transfer), ctx, senderID, recipientLogin, amount, limits)
 }
 
 // UpdateOrderStatus mocks base method.
@@ -0,0 +1,3 @@
+ALTER TABLE accrual.orders
+    DROP COLUMN IF EXISTS adjustment,
+    DROP COLUMN IF EXISTS returned;
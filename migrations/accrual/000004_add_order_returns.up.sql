@@ -0,0 +1,4 @@
+-- Возвраты товаров и корректировка начисления
+ALTER TABLE accrual.orders
+    ADD COLUMN IF NOT EXISTS returned   JSONB  NOT NULL DEFAULT '[]', -- возвращённые товары
+    ADD COLUMN IF NOT EXISTS adjustment BIGINT;                       -- NULL = возвратов не было
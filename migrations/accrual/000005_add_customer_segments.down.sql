@@ -0,0 +1,8 @@
+DELETE FROM accrual.reward_rules WHERE customer_segment <> '';
+ALTER TABLE accrual.reward_rules DROP CONSTRAINT IF EXISTS reward_rules_pkey;
+ALTER TABLE accrual.reward_rules ADD PRIMARY KEY (match);
+ALTER TABLE accrual.reward_rules DROP COLUMN IF EXISTS customer_segment;
+
+ALTER TABLE accrual.orders
+    DROP COLUMN IF EXISTS breakdown,
+    DROP COLUMN IF EXISTS customer_segment;
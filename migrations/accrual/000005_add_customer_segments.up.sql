@@ -0,0 +1,11 @@
+-- Сегменты покупателей для заказов и правил вознаграждений
+ALTER TABLE accrual.orders
+    ADD COLUMN IF NOT EXISTS customer_segment TEXT  NOT NULL DEFAULT '', -- '' = без сегмента
+    ADD COLUMN IF NOT EXISTS breakdown        JSONB;                     -- расшифровка начисления по товарам
+
+ALTER TABLE accrual.reward_rules
+    ADD COLUMN IF NOT EXISTS customer_segment TEXT NOT NULL DEFAULT ''; -- '' = правило для всех
+
+-- Один и тот же match может иметь отдельное правило для каждого сегмента
+ALTER TABLE accrual.reward_rules DROP CONSTRAINT IF EXISTS reward_rules_pkey;
+ALTER TABLE accrual.reward_rules ADD PRIMARY KEY (match, customer_segment);
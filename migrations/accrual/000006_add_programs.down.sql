@@ -0,0 +1,9 @@
+DELETE FROM accrual.reward_rules WHERE program <> 'default';
+ALTER TABLE accrual.reward_rules DROP CONSTRAINT IF EXISTS reward_rules_pkey;
+ALTER TABLE accrual.reward_rules ADD PRIMARY KEY (match, customer_segment);
+ALTER TABLE accrual.reward_rules DROP COLUMN IF EXISTS program;
+
+DELETE FROM accrual.orders WHERE program <> 'default';
+ALTER TABLE accrual.orders DROP CONSTRAINT IF EXISTS orders_pkey;
+ALTER TABLE accrual.orders ADD PRIMARY KEY (number);
+ALTER TABLE accrual.orders DROP COLUMN IF EXISTS program;
@@ -0,0 +1,12 @@
+-- Программы лояльности: у каждого бренда свои правила и заказы
+ALTER TABLE accrual.orders
+    ADD COLUMN IF NOT EXISTS program TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE accrual.orders DROP CONSTRAINT IF EXISTS orders_pkey;
+ALTER TABLE accrual.orders ADD PRIMARY KEY (program, number);
+
+ALTER TABLE accrual.reward_rules
+    ADD COLUMN IF NOT EXISTS program TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE accrual.reward_rules DROP CONSTRAINT IF EXISTS reward_rules_pkey;
+ALTER TABLE accrual.reward_rules ADD PRIMARY KEY (program, match, customer_segment);
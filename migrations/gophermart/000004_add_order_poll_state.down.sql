@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS gophermart.idx_orders_parked;
+DROP INDEX IF EXISTS gophermart.idx_orders_due;
+
+ALTER TABLE gophermart.orders
+    DROP COLUMN IF EXISTS parked_at,
+    DROP COLUMN IF EXISTS last_poll_error,
+    DROP COLUMN IF EXISTS next_poll_at,
+    DROP COLUMN IF EXISTS poll_attempts;
@@ -0,0 +1,9 @@
+ALTER TABLE gophermart.orders
+    ADD COLUMN poll_attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN next_poll_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    ADD COLUMN last_poll_error TEXT,
+    ADD COLUMN parked_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_orders_due ON gophermart.orders(next_poll_at)
+    WHERE status IN ('NEW', 'PROCESSING') AND parked_at IS NULL;
+CREATE INDEX idx_orders_parked ON gophermart.orders(parked_at) WHERE parked_at IS NOT NULL;
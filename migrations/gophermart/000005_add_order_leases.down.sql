@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS gophermart.idx_orders_lease_owner;
+
+ALTER TABLE gophermart.orders
+    DROP COLUMN IF EXISTS lease_expires_at,
+    DROP COLUMN IF EXISTS lease_owner;
@@ -0,0 +1,5 @@
+ALTER TABLE gophermart.orders
+    ADD COLUMN lease_owner TEXT,
+    ADD COLUMN lease_expires_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_orders_lease_owner ON gophermart.orders(lease_owner) WHERE lease_owner IS NOT NULL;
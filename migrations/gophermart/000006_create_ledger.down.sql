@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS ledger_entries_append_only ON gophermart.ledger_entries;
+DROP FUNCTION IF EXISTS gophermart.ledger_entries_append_only();
+DROP INDEX IF EXISTS gophermart.idx_ledger_entries_order_number;
+DROP INDEX IF EXISTS gophermart.idx_ledger_entries_user_created;
+DROP INDEX IF EXISTS gophermart.idx_ledger_entries_posting;
+DROP TABLE IF EXISTS gophermart.ledger_entries;
+DROP SEQUENCE IF EXISTS gophermart.ledger_postings_seq;
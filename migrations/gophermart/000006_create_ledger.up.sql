@@ -0,0 +1,65 @@
+-- Every balance change is a posting: two or more entries whose amounts sum to zero.
+-- User accounts have user_id set; system accounts (accruals, redemptions) have it NULL.
+CREATE SEQUENCE gophermart.ledger_postings_seq;
+
+CREATE TABLE gophermart.ledger_entries (
+    id BIGSERIAL PRIMARY KEY,
+    posting_id BIGINT NOT NULL,
+    user_id BIGINT REFERENCES gophermart.users(id),
+    account VARCHAR(32) NOT NULL,
+    entry_type VARCHAR(32) NOT NULL,
+    amount BIGINT NOT NULL,
+    order_number VARCHAR(255),
+    withdrawal_id BIGINT REFERENCES gophermart.balance_transactions(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    CHECK ((account = 'user') = (user_id IS NOT NULL))
+);
+
+CREATE INDEX idx_ledger_entries_posting ON gophermart.ledger_entries(posting_id);
+CREATE INDEX idx_ledger_entries_user_created ON gophermart.ledger_entries(user_id, created_at DESC)
+    WHERE user_id IS NOT NULL;
+CREATE INDEX idx_ledger_entries_order_number ON gophermart.ledger_entries(order_number)
+    WHERE order_number IS NOT NULL;
+
+CREATE FUNCTION gophermart.ledger_entries_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'ledger entries are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER ledger_entries_append_only
+    BEFORE UPDATE OR DELETE ON gophermart.ledger_entries
+    FOR EACH ROW EXECUTE FUNCTION gophermart.ledger_entries_append_only();
+
+-- Backfill: accruals of processed orders
+WITH postings AS (
+    SELECT nextval('gophermart.ledger_postings_seq') AS posting_id, user_id, number, accrual, uploaded_at
+    FROM gophermart.orders
+    WHERE status = 'PROCESSED' AND accrual > 0
+)
+INSERT INTO gophermart.ledger_entries (posting_id, user_id, account, entry_type, amount, order_number, created_at)
+SELECT posting_id, user_id, 'user', 'ACCRUAL', accrual, number, uploaded_at FROM postings
+UNION ALL
+SELECT posting_id, NULL, 'accruals', 'ACCRUAL', -accrual, number, uploaded_at FROM postings;
+
+-- Backfill: withdrawals
+WITH postings AS (
+    SELECT nextval('gophermart.ledger_postings_seq') AS posting_id, id, user_id, order_number, sum, processed_at
+    FROM gophermart.balance_transactions
+)
+INSERT INTO gophermart.ledger_entries (posting_id, user_id, account, entry_type, amount, order_number, withdrawal_id, created_at)
+SELECT posting_id, user_id, 'user', 'WITHDRAWAL', -sum, order_number, id, processed_at FROM postings
+UNION ALL
+SELECT posting_id, NULL, 'redemptions', 'WITHDRAWAL', sum, order_number, id, processed_at FROM postings;
+
+-- Backfill: an opening adjustment for credits that left no trace (direct balance updates)
+WITH postings AS (
+    SELECT nextval('gophermart.ledger_postings_seq') AS posting_id, u.id AS user_id, u.created_at,
+        u.balance - COALESCE((SELECT SUM(e.amount) FROM gophermart.ledger_entries e WHERE e.user_id = u.id), 0) AS diff
+    FROM gophermart.users u
+    WHERE u.balance <> COALESCE((SELECT SUM(e.amount) FROM gophermart.ledger_entries e WHERE e.user_id = u.id), 0)
+)
+INSERT INTO gophermart.ledger_entries (posting_id, user_id, account, entry_type, amount, created_at)
+SELECT posting_id, user_id, 'user', 'ADJUSTMENT', diff, created_at FROM postings
+UNION ALL
+SELECT posting_id, NULL, 'accruals', 'ADJUSTMENT', -diff, created_at FROM postings;
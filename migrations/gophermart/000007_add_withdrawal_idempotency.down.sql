@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS gophermart.idempotency_keys;
+DROP INDEX IF EXISTS gophermart.idx_balance_transactions_user_order;
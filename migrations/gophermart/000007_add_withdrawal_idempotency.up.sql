@@ -0,0 +1,48 @@
+-- Retried requests could withdraw twice for the same order. The earliest withdrawal of an order
+-- stays; every later one is credited back with a REVERSAL posting. The ledger still references
+-- the duplicates, so instead of being deleted they give up the order number.
+WITH postings AS (
+    SELECT nextval('gophermart.ledger_postings_seq') AS posting_id, id, user_id, order_number, sum
+    FROM (
+        SELECT id, user_id, order_number, sum,
+            ROW_NUMBER() OVER (PARTITION BY user_id, order_number ORDER BY processed_at, id) AS n
+        FROM gophermart.balance_transactions
+    ) withdrawals
+    WHERE n > 1
+)
+INSERT INTO gophermart.ledger_entries (posting_id, user_id, account, entry_type, amount, order_number, withdrawal_id)
+SELECT posting_id, user_id, 'user', 'REVERSAL', sum, order_number, id FROM postings
+UNION ALL
+SELECT posting_id, NULL, 'redemptions', 'REVERSAL', -sum, order_number, id FROM postings;
+
+UPDATE gophermart.users u
+SET balance = u.balance + d.total, withdrawn = u.withdrawn - d.total
+FROM (
+    SELECT user_id, SUM(amount) AS total
+    FROM gophermart.ledger_entries
+    WHERE entry_type = 'REVERSAL' AND account = 'user'
+    GROUP BY user_id
+) d
+WHERE u.id = d.user_id;
+
+UPDATE gophermart.balance_transactions w
+SET order_number = w.order_number || '-duplicate-' || w.id
+FROM gophermart.ledger_entries e
+WHERE e.withdrawal_id = w.id AND e.entry_type = 'REVERSAL' AND e.account = 'user';
+
+-- One withdrawal per order number per user
+CREATE UNIQUE INDEX idx_balance_transactions_user_order
+    ON gophermart.balance_transactions(user_id, order_number);
+
+-- Responses to requests sent with an Idempotency-Key header. status_code is NULL while
+-- the first request is still being handled.
+CREATE TABLE gophermart.idempotency_keys (
+    user_id BIGINT NOT NULL REFERENCES gophermart.users(id) ON DELETE CASCADE,
+    key VARCHAR(255) NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL,
+    status_code INTEGER,
+    content_type VARCHAR(255),
+    response_body BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    PRIMARY KEY (user_id, key)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE gophermart.balance_transactions
+    DROP COLUMN IF EXISTS reversal_reason,
+    DROP COLUMN IF EXISTS reversed_at;
@@ -0,0 +1,9 @@
+ALTER TABLE gophermart.balance_transactions
+    ADD COLUMN reversed_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN reversal_reason VARCHAR(255);
+
+-- Duplicate withdrawals credited back by 000007 are reversed ones
+UPDATE gophermart.balance_transactions w
+SET reversed_at = e.created_at, reversal_reason = 'duplicate withdrawal'
+FROM gophermart.ledger_entries e
+WHERE e.withdrawal_id = w.id AND e.entry_type = 'REVERSAL' AND e.account = 'user';
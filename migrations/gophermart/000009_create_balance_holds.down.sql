@@ -0,0 +1 @@
+DROP TABLE IF EXISTS gophermart.balance_holds;
@@ -0,0 +1,18 @@
+-- Points reserved for a checkout. An active hold (HELD, not yet expired) reduces the available
+-- balance without touching the ledger; capturing it turns it into an ordinary withdrawal.
+CREATE TABLE gophermart.balance_holds (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES gophermart.users(id) ON DELETE CASCADE,
+    order_number VARCHAR(255) NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    status VARCHAR(32) NOT NULL DEFAULT 'HELD',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    withdrawal_id BIGINT REFERENCES gophermart.balance_transactions(id)
+);
+
+CREATE UNIQUE INDEX idx_balance_holds_user_order_held ON gophermart.balance_holds(user_id, order_number)
+    WHERE status = 'HELD';
+CREATE INDEX idx_balance_holds_expires_held ON gophermart.balance_holds(expires_at)
+    WHERE status = 'HELD';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS gophermart.lot_consumptions;
+DROP TABLE IF EXISTS gophermart.points_lots;
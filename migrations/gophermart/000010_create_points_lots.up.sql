@@ -0,0 +1,42 @@
+-- Credited points are tracked in lots so they can expire a fixed time after they were earned.
+-- Debits consume the oldest lots first; lot_consumptions records which lots a withdrawal used,
+-- so a reversal gives the points back to the same lots. The remaining amounts of a user's lots
+-- sum to the user's ledger balance.
+CREATE TABLE gophermart.points_lots (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES gophermart.users(id) ON DELETE CASCADE,
+    amount BIGINT NOT NULL,
+    remaining BIGINT NOT NULL CHECK (remaining >= 0),
+    earned_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    order_number VARCHAR(255)
+);
+
+CREATE INDEX idx_points_lots_user_open ON gophermart.points_lots(user_id, earned_at, id)
+    WHERE remaining > 0;
+
+CREATE TABLE gophermart.lot_consumptions (
+    lot_id BIGINT NOT NULL REFERENCES gophermart.points_lots(id) ON DELETE CASCADE,
+    withdrawal_id BIGINT NOT NULL REFERENCES gophermart.balance_transactions(id) ON DELETE CASCADE,
+    amount BIGINT NOT NULL,
+    PRIMARY KEY (lot_id, withdrawal_id)
+);
+
+CREATE INDEX idx_lot_consumptions_withdrawal ON gophermart.lot_consumptions(withdrawal_id);
+
+-- Backfill: the current balance is what is left of the newest credits, since older points were spent first
+WITH balances AS (
+    SELECT user_id, SUM(amount) AS balance
+    FROM gophermart.ledger_entries
+    WHERE user_id IS NOT NULL
+    GROUP BY user_id
+), credits AS (
+    SELECT user_id, amount, created_at, order_number,
+        SUM(amount) OVER (PARTITION BY user_id ORDER BY created_at DESC, id DESC) AS credited_since
+    FROM gophermart.ledger_entries
+    WHERE user_id IS NOT NULL AND amount > 0
+)
+INSERT INTO gophermart.points_lots (user_id, amount, remaining, earned_at, order_number)
+SELECT c.user_id, c.amount, LEAST(c.amount, b.balance - (c.credited_since - c.amount)), c.created_at, c.order_number
+FROM credits c
+JOIN balances b ON b.user_id = c.user_id
+WHERE b.balance - (c.credited_since - c.amount) > 0;
@@ -0,0 +1,3 @@
+ALTER TABLE gophermart.ledger_entries
+    DROP COLUMN IF EXISTS transfer_id;
+DROP TABLE IF EXISTS gophermart.transfers;
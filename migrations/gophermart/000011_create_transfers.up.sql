@@ -0,0 +1,15 @@
+-- Points moved from one user to another; a transfer is posted to the ledger as a debit of the
+-- sender and a credit of the recipient
+CREATE TABLE gophermart.transfers (
+    id BIGSERIAL PRIMARY KEY,
+    sender_id BIGINT NOT NULL REFERENCES gophermart.users(id) ON DELETE CASCADE,
+    recipient_id BIGINT NOT NULL REFERENCES gophermart.users(id) ON DELETE CASCADE,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW() NOT NULL,
+    CHECK (sender_id <> recipient_id)
+);
+
+CREATE INDEX idx_transfers_sender_created ON gophermart.transfers(sender_id, created_at);
+
+ALTER TABLE gophermart.ledger_entries
+    ADD COLUMN transfer_id BIGINT REFERENCES gophermart.transfers(id);
@@ -0,0 +1,5 @@
+ALTER TABLE gophermart.users
+    DROP COLUMN IF EXISTS debt;
+DROP INDEX IF EXISTS gophermart.idx_orders_recheck;
+ALTER TABLE gophermart.orders
+    DROP COLUMN IF EXISTS processed_at;
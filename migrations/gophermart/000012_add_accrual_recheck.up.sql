@@ -0,0 +1,13 @@
+-- Processed orders are polled again for a while, so accruals lowered or reversed after
+-- returns are clawed back. For a processed order next_poll_at is when accrual last answered.
+ALTER TABLE gophermart.orders
+    ADD COLUMN processed_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE gophermart.orders SET processed_at = uploaded_at WHERE status = 'PROCESSED';
+
+CREATE INDEX idx_orders_recheck ON gophermart.orders(next_poll_at)
+    WHERE status = 'PROCESSED' AND parked_at IS NULL;
+
+-- Clawed back points the balance could not cover; later credits pay it off first
+ALTER TABLE gophermart.users
+    ADD COLUMN debt BIGINT NOT NULL DEFAULT 0 CHECK (debt >= 0);
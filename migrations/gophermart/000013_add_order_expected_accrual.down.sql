@@ -0,0 +1,2 @@
+ALTER TABLE gophermart.orders
+    DROP COLUMN IF EXISTS expected_accrual;
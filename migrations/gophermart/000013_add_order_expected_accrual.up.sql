@@ -0,0 +1,4 @@
+-- Accrual an unfinished order is expected to bring, from the last intermediate answer of
+-- the accrual system; NULL while it is unknown
+ALTER TABLE gophermart.orders
+    ADD COLUMN expected_accrual BIGINT;